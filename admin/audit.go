@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/kataras/iris"
+	"github.com/kelseyhightower/confd/audit"
+	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/redact"
+)
+
+// recordAudit records e, done by the user of ctx unless e has an actor,
+// with its result err.
+func recordAudit(ctx *iris.Context, e audit.Entry, err error) {
+	if e.Actor == "" {
+		e.Actor = tokenUsername(ctx)
+	}
+	e.Success = err == nil
+	if err != nil {
+		e.Error = redact.Text(err.Error())
+	}
+	audit.Record(e)
+}
+
+// checksumOf returns the audit checksum of the current value of key.
+func (v *View) checksumOf(key string) string {
+	if !audit.Enabled() {
+		return ""
+	}
+	pairs, err := v.WebServer.TemplateConfig().StoreClient.GetValues(context.Background(), []string{key})
+	if err != nil {
+		return ""
+	}
+	value, ok := pairs[key]
+	return audit.Checksum([]byte(value), ok)
+}
+
+// setKey sets key to value on behalf of the user of ctx.
+func (v *View) setKey(ctx *iris.Context, key, value string) error {
+	old := v.checksumOf(key)
+	err := v.WebServer.TemplateConfig().StoreClient.Set(key, value)
+	recordAudit(ctx, audit.Entry{
+		Action:      audit.ActionSetKey,
+		Target:      key,
+		OldChecksum: old,
+		NewChecksum: audit.Checksum([]byte(value), true),
+	}, err)
+	return err
+}
+
+// removeKey removes key on behalf of the user of ctx.
+func (v *View) removeKey(ctx *iris.Context, key string) error {
+	old := v.checksumOf(key)
+	err := v.WebServer.TemplateConfig().StoreClient.Remove(key)
+	recordAudit(ctx, audit.Entry{Action: audit.ActionDeleteKey, Target: key, OldChecksum: old}, err)
+	return err
+}
+
+// GetAudit returns the audit log entries selected by the actor, action,
+// target (a prefix), since and until (RFC 3339 times) and limit query
+// parameters, oldest first.
+func (v *View) GetAudit(ctx *iris.Context) {
+	filter := audit.Filter{
+		Actor:  ctx.URLParam("actor"),
+		Action: ctx.URLParam("action"),
+		Target: ctx.URLParam("target"),
+	}
+	var err error
+	if s := ctx.URLParam("since"); s != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, s); err != nil {
+			ctx.JSON(iris.StatusBadRequest, iris.Map{"result": false, "msg": "invalid since: " + err.Error()})
+			return
+		}
+	}
+	if s := ctx.URLParam("until"); s != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, s); err != nil {
+			ctx.JSON(iris.StatusBadRequest, iris.Map{"result": false, "msg": "invalid until: " + err.Error()})
+			return
+		}
+	}
+	if s := ctx.URLParam("limit"); s != "" {
+		if filter.Limit, err = strconv.Atoi(s); err != nil {
+			ctx.JSON(iris.StatusBadRequest, iris.Map{"result": false, "msg": "invalid limit: " + err.Error()})
+			return
+		}
+	}
+	entries, err := audit.Query(filter)
+	if err != nil {
+		log.Error(err.Error())
+		ctx.JSON(iris.StatusInternalServerError, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(iris.StatusOK, entries)
+}
@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/kataras/iris"
+)
+
+// Roles carried in the "role" claim of admin tokens.
+const (
+	// RoleReadOnly may only use view endpoints.
+	RoleReadOnly = "ro"
+	// RoleReadWrite may also use endpoints mutating keys or files.
+	RoleReadWrite = "rw"
+)
+
+// randomSecretKey returns a random key for signing tokens, used when no
+// secret key is configured.
+func randomSecretKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// authenticate returns the role of the user identified by username and
+// password, or false if the credentials are incorrect.
+func (w *WebServer) authenticate(username, password string) (string, bool) {
+	// Both credentials are always compared, so that timing does not reveal a
+	// valid username.
+	user, pass := secretEqual(username, w.setting.Username), secretEqual(password, w.setting.Password)
+	if user && pass {
+		return RoleReadWrite, true
+	}
+	if w.setting.ViewerUsername != "" {
+		user, pass = secretEqual(username, w.setting.ViewerUsername), secretEqual(password, w.setting.ViewerPassword)
+		if user && pass {
+			return RoleReadOnly, true
+		}
+	}
+	return "", false
+}
+
+// secretEqual compares a and b in constant time, so that response times do
+// not reveal how much of a credential is right.
+func secretEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// tokenClaim returns the string claim name of the token validated by the
+// jwt middleware.
+func tokenClaim(ctx *iris.Context, name string) string {
+	token, ok := ctx.Get("jwt").(*jwt.Token)
+	if !ok {
+		return ""
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	value, _ := claims[name].(string)
+	return value
+}
+
+// tokenRole returns the role claim of the token validated by the jwt
+// middleware.
+func tokenRole(ctx *iris.Context) string {
+	return tokenClaim(ctx, "role")
+}
+
+// tokenUsername returns the username claim of the token validated by the
+// jwt middleware.
+func tokenUsername(ctx *iris.Context) string {
+	return tokenClaim(ctx, "username")
+}
+
+// requireWrite rejects requests whose token does not carry the read-write
+// role. It must run after the jwt middleware.
+func requireWrite(ctx *iris.Context) {
+	if tokenRole(ctx) != RoleReadWrite {
+		ctx.JSON(iris.StatusForbidden, iris.Map{"result": false, "msg": "read-write role required"})
+		return
+	}
+	ctx.Next()
+}
@@ -0,0 +1,76 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kataras/iris"
+	"github.com/kelseyhightower/confd/resource/template"
+)
+
+// keepaliveInterval is how often an idle event stream gets a comment, so
+// that proxies do not close it.
+const keepaliveInterval = 15 * time.Second
+
+// GetEvents returns the latest backend change events selected by the key
+// (a prefix), resource and limit query parameters, oldest first.
+func (v *View) GetEvents(ctx *iris.Context) {
+	filter := template.EventFilter{
+		Key:      ctx.URLParam("key"),
+		Resource: ctx.URLParam("resource"),
+	}
+	if s := ctx.URLParam("limit"); s != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(s); err != nil {
+			ctx.JSON(iris.StatusBadRequest, iris.Map{"result": false, "msg": "invalid limit: " + err.Error()})
+			return
+		}
+	}
+	ctx.JSON(iris.StatusOK, template.ChangeEvents(filter))
+}
+
+// StreamEvents sends the backend change events as server-sent events as
+// they are recorded. With a Last-Event-ID header, the recorded events after
+// that one are sent first.
+func (v *View) StreamEvents(ctx *iris.Context) {
+	last, _ := strconv.ParseUint(ctx.RequestHeader("Last-Event-ID"), 10, 64)
+	events, cancel := template.SubscribeEvents()
+	ctx.SetContentType("text/event-stream")
+	ctx.SetHeader("Cache-Control", "no-cache")
+	ctx.SetHeader("X-Accel-Buffering", "no")
+	ctx.StreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		if last > 0 {
+			for _, e := range template.ChangeEvents(template.EventFilter{}) {
+				if e.ID > last {
+					writeEvent(w, e)
+				}
+			}
+		}
+		ticker := time.NewTicker(keepaliveInterval)
+		defer ticker.Stop()
+		for {
+			// Flush fails once the client went away.
+			if err := w.Flush(); err != nil {
+				return
+			}
+			select {
+			case e := <-events:
+				writeEvent(w, e)
+			case <-ticker.C:
+				w.WriteString(": keepalive\n\n")
+			}
+		}
+	})
+}
+
+func writeEvent(w *bufio.Writer, e template.ChangeEvent) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: change\ndata: %s\n\n", e.ID, data)
+}
@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"time"
+
+	"github.com/kataras/iris"
+	"github.com/kelseyhightower/confd/backends"
+	"github.com/kelseyhightower/confd/resource/template"
+)
+
+// health collects the state reported by /healthz and /readyz. It is
+// degraded, rather than failing, while template resources are rendered from
+// their snapshots because the backend is down.
+func (v *View) health() (result iris.Map, ok, degraded bool) {
+	ok = true
+	result = iris.Map{}
+
+	names := make([]string, 0)
+	for _, s := range template.Statuses() {
+		if s.Degraded {
+			names = append(names, s.Name)
+		}
+	}
+	result["degraded"] = names
+	degraded = len(names) > 0
+
+	if err := backends.Ping(v.WebServer.TemplateConfig().StoreClient); err != nil {
+		ok = degraded
+		result["backend"] = err.Error()
+	} else {
+		result["backend"] = "ok"
+	}
+
+	if last := template.LastSuccessfulRun(); !last.IsZero() {
+		result["last_success"] = last
+		result["seconds_since_last_success"] = int(time.Since(last).Seconds())
+	}
+	return result, ok, degraded
+}
+
+// Healthz reports whether confd can reach its backend.
+func (v *View) Healthz(ctx *iris.Context) {
+	result, ok, degraded := v.health()
+	if !ok {
+		result["status"] = "unhealthy"
+		ctx.JSON(iris.StatusServiceUnavailable, result)
+		return
+	}
+	result["status"] = "ok"
+	if degraded {
+		result["status"] = "degraded"
+	}
+	ctx.JSON(iris.StatusOK, result)
+}
+
+// Readyz additionally reports template resources that were not synced
+// successfully within their stale threshold.
+func (v *View) Readyz(ctx *iris.Context) {
+	result, ok, degraded := v.health()
+
+	now := time.Now()
+	stale := make([]string, 0)
+	for _, s := range template.Statuses() {
+		if s.Stale(now) {
+			stale = append(stale, s.Name)
+		}
+	}
+	result["stale"] = stale
+	if len(stale) > 0 {
+		ok = false
+	}
+
+	if !ok {
+		result["status"] = "not ready"
+		ctx.JSON(iris.StatusServiceUnavailable, result)
+		return
+	}
+	result["status"] = "ready"
+	if degraded {
+		result["status"] = "degraded"
+	}
+	ctx.JSON(iris.StatusOK, result)
+}
+
+// Status returns the status of every template resource processed so far.
+func (v *View) Status(ctx *iris.Context) {
+	ctx.JSON(iris.StatusOK, template.Statuses())
+}
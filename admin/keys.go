@@ -0,0 +1,84 @@
+package admin
+
+import (
+	"context"
+	"path"
+	"sort"
+
+	"github.com/kataras/iris"
+	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/redact"
+)
+
+type keyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// keyParam returns the absolute key addressed by the *key route parameter.
+func keyParam(ctx *iris.Context) string {
+	return path.Join("/", iris.DecodeURL(ctx.Param("key")))
+}
+
+// ListKeys returns every key below the prefix query parameter.
+func (v *View) ListKeys(ctx *iris.Context) {
+	prefix := path.Join("/", ctx.URLParam("prefix"))
+	pairs, err := v.WebServer.TemplateConfig().StoreClient.GetValues(context.Background(), []string{prefix})
+	if err != nil {
+		log.Error(err.Error())
+		ctx.JSON(iris.StatusInternalServerError, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	kvs := make([]keyValue, 0, len(pairs))
+	for k, val := range pairs {
+		kvs = append(kvs, keyValue{Key: k, Value: redact.Value(k, val)})
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	ctx.JSON(iris.StatusOK, kvs)
+}
+
+// GetKey returns the value of a single key.
+func (v *View) GetKey(ctx *iris.Context) {
+	key := keyParam(ctx)
+	pairs, err := v.WebServer.TemplateConfig().StoreClient.GetValues(context.Background(), []string{key})
+	if err != nil {
+		log.Error(err.Error())
+		ctx.JSON(iris.StatusInternalServerError, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	value, ok := pairs[key]
+	if !ok {
+		ctx.JSON(iris.StatusNotFound, iris.Map{"result": false, "msg": "key not found: " + key})
+		return
+	}
+	ctx.JSON(iris.StatusOK, keyValue{Key: key, Value: redact.Value(key, value)})
+}
+
+// PutKey sets a key from a JSON body of the form {"value": "..."}.
+func (v *View) PutKey(ctx *iris.Context) {
+	key := keyParam(ctx)
+	var body keyValue
+	if err := ctx.ReadJSON(&body); err != nil {
+		ctx.JSON(iris.StatusBadRequest, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	log.Debug("set key: %s", key)
+	if err := v.setKey(ctx, key, body.Value); err != nil {
+		log.Error(err.Error())
+		ctx.JSON(iris.StatusInternalServerError, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(iris.StatusOK, iris.Map{"result": true})
+}
+
+// DeleteKey removes a key.
+func (v *View) DeleteKey(ctx *iris.Context) {
+	key := keyParam(ctx)
+	log.Debug("remove key: %s", key)
+	if err := v.removeKey(ctx, key); err != nil {
+		log.Error(err.Error())
+		ctx.JSON(iris.StatusInternalServerError, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(iris.StatusOK, iris.Map{"result": true})
+}
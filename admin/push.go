@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"path"
+
+	jwtmiddleware "github.com/iris-contrib/middleware/jwt"
+	"github.com/kataras/iris"
+	"github.com/kelseyhightower/confd/audit"
+	"github.com/kelseyhightower/confd/backends"
+	"github.com/kelseyhightower/confd/log"
+)
+
+// pushActor is the audit actor of the pushes authenticated by the push
+// token.
+const pushActor = "push-token"
+
+// pushRequest is the body of a push: the keys to set and those to remove.
+type pushRequest struct {
+	Set    map[string]string `json:"set"`
+	Remove []string          `json:"remove"`
+}
+
+// pushAuth authenticates pushes with the push token as a bearer token, or
+// else like the other write endpoints, with a read-write token.
+func (w *WebServer) pushAuth(jwtMDW *jwtmiddleware.Middleware) iris.HandlerFunc {
+	return func(ctx *iris.Context) {
+		if token := w.setting.PushToken; token != "" {
+			header := ctx.RequestHeader("Authorization")
+			if subtle.ConstantTimeCompare([]byte(header), []byte("Bearer "+token)) == 1 {
+				ctx.Set("actor", pushActor)
+				ctx.Next()
+				return
+			}
+		}
+		if err := jwtMDW.CheckJWT(ctx); err != nil {
+			return
+		}
+		requireWrite(ctx)
+	}
+}
+
+// Push sets and removes keys of the push backends at once, from a JSON body
+// of the form {"set": {"/key": "value"}, "remove": ["/key"]}. The template
+// resources are processed right away.
+func (v *View) Push(ctx *iris.Context) {
+	var body pushRequest
+	if err := ctx.ReadJSON(&body); err != nil {
+		ctx.JSON(iris.StatusBadRequest, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	set := make(map[string]string, len(body.Set))
+	for k, value := range body.Set {
+		set[path.Join("/", k)] = value
+	}
+	remove := make([]string, len(body.Remove))
+	for i, k := range body.Remove {
+		remove[i] = path.Join("/", k)
+	}
+	actor, _ := ctx.Get("actor").(string)
+	entries := make([]audit.Entry, 0, len(set)+len(remove))
+	for _, k := range remove {
+		entries = append(entries, audit.Entry{Actor: actor, Action: audit.ActionDeleteKey, Target: k, OldChecksum: v.checksumOf(k)})
+	}
+	for k, value := range set {
+		entries = append(entries, audit.Entry{
+			Actor:       actor,
+			Action:      audit.ActionSetKey,
+			Target:      k,
+			OldChecksum: v.checksumOf(k),
+			NewChecksum: audit.Checksum([]byte(value), true),
+		})
+	}
+	log.Debug("push: %d key(s) set, %d removed", len(set), len(remove))
+	err := backends.Push(set, remove)
+	for _, e := range entries {
+		recordAudit(ctx, e, err)
+	}
+	if err == backends.ErrNoPushBackend {
+		ctx.JSON(iris.StatusNotFound, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Error(err.Error())
+		ctx.JSON(iris.StatusInternalServerError, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(iris.StatusOK, iris.Map{"result": true})
+}
@@ -0,0 +1,214 @@
+package admin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kataras/iris"
+	"github.com/kelseyhightower/confd/audit"
+	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/resource/template"
+)
+
+// syncRequestTimeout bounds how long Sync waits for the processor to accept
+// a request.
+const syncRequestTimeout = 30 * time.Second
+
+// ListTemplates returns the status of every template resource: last sync
+// and result, last errors, reload_cmd exit status, dest checksum, keys and
+// recent history.
+func (v *View) ListTemplates(ctx *iris.Context) {
+	ts, err := template.GetTemplateResources(v.WebServer.TemplateConfig())
+	if err != nil {
+		log.Error(err.Error())
+		ctx.JSON(iris.StatusInternalServerError, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	statuses := make([]template.ResourceStatus, 0, len(ts))
+	for _, t := range ts {
+		statuses = append(statuses, t.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	ctx.JSON(iris.StatusOK, statuses)
+}
+
+// GetTemplate returns the status of one template resource, like
+// ListTemplates.
+func (v *View) GetTemplate(ctx *iris.Context) {
+	t, err := template.FindTemplateResource(v.WebServer.TemplateConfig(), ctx.Param("name"))
+	if err != nil {
+		ctx.JSON(iris.StatusNotFound, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(iris.StatusOK, t.Status())
+}
+
+// RenderTemplate renders a template resource against the current backend
+// values and returns a diff against its destination. Only dry runs are
+// supported: the destination is never written and reload_cmd never runs.
+func (v *View) RenderTemplate(ctx *iris.Context) {
+	if ctx.URLParam("dry-run") != "true" {
+		ctx.JSON(iris.StatusBadRequest, iris.Map{"result": false, "msg": "only dry-run=true is supported"})
+		return
+	}
+	t, err := template.FindTemplateResource(v.WebServer.TemplateConfig(), ctx.Param("name"))
+	if err != nil {
+		ctx.JSON(iris.StatusNotFound, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	result, err := t.DryRun()
+	if err != nil {
+		log.Error(err.Error())
+		ctx.JSON(iris.StatusInternalServerError, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(iris.StatusOK, result)
+}
+
+// templateSource is the body of PreviewTemplate and SaveTemplateSource.
+type templateSource struct {
+	Source string `json:"source"`
+}
+
+// sourceErrorResponse returns the response of an invalid edited template.
+func sourceErrorResponse(err error) iris.Map {
+	m := iris.Map{"result": false, "msg": err.Error()}
+	if e, ok := err.(*template.SourceError); ok && e.Line > 0 {
+		m["line"] = e.Line
+	}
+	return m
+}
+
+// GetTemplateSource returns the src template of a template resource, from
+// its file or backend key.
+func (v *View) GetTemplateSource(ctx *iris.Context) {
+	t, err := template.FindTemplateResource(v.WebServer.TemplateConfig(), ctx.Param("name"))
+	if err != nil {
+		ctx.JSON(iris.StatusNotFound, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	source, err := t.Source()
+	if err != nil {
+		log.Error(err.Error())
+		ctx.JSON(iris.StatusInternalServerError, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(iris.StatusOK, iris.Map{"result": true, "src": t.Src, "source": source})
+}
+
+// PreviewTemplate renders the posted source in place of the src template of
+// a template resource against the current backend values, and returns the
+// result and its diff against the destination. Nothing is saved or written.
+// Parse and execution errors are returned with their line.
+func (v *View) PreviewTemplate(ctx *iris.Context) {
+	var body templateSource
+	if err := ctx.ReadJSON(&body); err != nil {
+		ctx.JSON(iris.StatusBadRequest, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	t, err := template.FindTemplateResource(v.WebServer.TemplateConfig(), ctx.Param("name"))
+	if err != nil {
+		ctx.JSON(iris.StatusNotFound, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	result, err := t.Preview(body.Source)
+	if _, ok := err.(*template.SourceError); ok {
+		ctx.JSON(iris.StatusBadRequest, sourceErrorResponse(err))
+		return
+	}
+	if err != nil {
+		log.Error(err.Error())
+		ctx.JSON(iris.StatusInternalServerError, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(iris.StatusOK, iris.Map{"result": true, "preview": result})
+}
+
+// SaveTemplateSource replaces the src template of a template resource with
+// the posted source, in its file or backend key, once it rendered without
+// error. The next pass over the resource uses it.
+func (v *View) SaveTemplateSource(ctx *iris.Context) {
+	var body templateSource
+	if err := ctx.ReadJSON(&body); err != nil {
+		ctx.JSON(iris.StatusBadRequest, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	t, err := template.FindTemplateResource(v.WebServer.TemplateConfig(), ctx.Param("name"))
+	if err != nil {
+		ctx.JSON(iris.StatusNotFound, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	old, oldErr := t.Source()
+	err = t.SaveSource(body.Source)
+	if _, ok := err.(*template.SourceError); ok {
+		ctx.JSON(iris.StatusBadRequest, sourceErrorResponse(err))
+		return
+	}
+	recordAudit(ctx, audit.Entry{
+		Action:      audit.ActionEditTemplate,
+		Target:      t.Name,
+		OldChecksum: audit.Checksum([]byte(old), oldErr == nil),
+		NewChecksum: audit.Checksum([]byte(body.Source), true),
+	}, err)
+	if err != nil {
+		log.Error(err.Error())
+		ctx.JSON(iris.StatusInternalServerError, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	log.Info("Template %s of %s saved by %s", t.Src, t.Name, tokenUsername(ctx))
+	ctx.JSON(iris.StatusOK, iris.Map{"result": true})
+}
+
+// Sync asks the running processor for an immediate processing pass of the
+// resource named by the resource parameter, or of every resource, and
+// returns the result of each.
+func (v *View) Sync(ctx *iris.Context) {
+	name := ctx.URLParam("resource")
+	if name == "" {
+		name = ctx.PostValue("resource")
+	}
+	req := template.NewSyncRequest(name)
+	req.Actor = tokenUsername(ctx)
+	select {
+	case v.WebServer.TemplateConfig().SyncChan <- req:
+	case <-time.After(syncRequestTimeout):
+		ctx.JSON(iris.StatusServiceUnavailable, iris.Map{"result": false, "msg": "processor is busy"})
+		return
+	}
+	results := <-req.Done
+	if name != "" && len(results) == 0 {
+		ctx.JSON(iris.StatusNotFound, iris.Map{"result": false, "msg": "template resource " + name + " not found"})
+		return
+	}
+	ok := true
+	var failed []string
+	for _, r := range results {
+		if r.Error != "" {
+			ok = false
+			failed = append(failed, r.Name)
+		}
+	}
+	var err error
+	if !ok {
+		err = fmt.Errorf("sync failed for %s", strings.Join(failed, ", "))
+	}
+	recordAudit(ctx, audit.Entry{Action: audit.ActionSync, Target: name}, err)
+	ctx.JSON(iris.StatusOK, iris.Map{"result": ok, "resources": results})
+}
+
+// Reload re-reads the confd configuration and template resources and
+// restarts the processor with them.
+func (v *View) Reload(ctx *iris.Context) {
+	if v.WebServer.setting.Reload == nil {
+		ctx.JSON(iris.StatusNotImplemented, iris.Map{"result": false, "msg": "reload is not supported"})
+		return
+	}
+	if err := v.WebServer.setting.Reload(); err != nil {
+		log.Error(err.Error())
+		ctx.JSON(iris.StatusInternalServerError, iris.Map{"result": false, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(iris.StatusOK, iris.Map{"result": true})
+}
@@ -0,0 +1,38 @@
+package admin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+)
+
+// tlsListener returns a listener on addr serving TLS with the configured
+// certificate, requiring clients to present a certificate signed by the
+// configured client CA.
+func (w *WebServer) tlsListener(addr string) (net.Listener, error) {
+	cert, err := tls.LoadX509KeyPair(w.setting.TLSCert, w.setting.TLSKey)
+	if err != nil {
+		return nil, err
+	}
+	caCert, err := ioutil.ReadFile(w.setting.ClientCA)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("no certificates found in " + w.setting.ClientCA)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(ln, tlsConfig), nil
+}
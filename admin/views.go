@@ -2,6 +2,7 @@ package admin
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -13,7 +14,9 @@ import (
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/kataras/iris"
+	"github.com/kelseyhightower/confd/audit"
 	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/redact"
 	"github.com/kelseyhightower/confd/resource/template"
 )
 
@@ -24,7 +27,9 @@ type View struct {
 func (v *View) Execute(ctx *iris.Context) {
 	projectName := ctx.PostValue("projectName")
 	log.Debug("projectName:" + projectName)
-	if err := template.Process(v.WebServer.templateConfig); err != nil {
+	err := template.Process(v.WebServer.TemplateConfig())
+	recordAudit(ctx, audit.Entry{Action: audit.ActionSync}, err)
+	if err == nil {
 		ctx.JSON(iris.StatusOK, iris.Map{"result": true})
 	} else {
 		log.Error(err.Error())
@@ -96,7 +101,7 @@ func (v *View) ServeStatic(ctx *iris.Context) {
 
 func (v *View) Home(ctx *iris.Context) {
 
-	ctx.WriteString(fmt.Sprintf("Hello, configDir: %s", v.WebServer.templateConfig.ConfDir)) //.Render("index.html")
+	ctx.WriteString(fmt.Sprintf("Hello, configDir: %s", v.WebServer.TemplateConfig().ConfDir)) //.Render("index.html")
 }
 
 type User struct {
@@ -108,19 +113,19 @@ func (v *View) Login(ctx *iris.Context) {
 
 	username := ctx.PostValue("username")
 	password := ctx.PostValue("password")
-	log.Debug(username + ", pwd:" + password)
-	log.Debug("config username:" + v.WebServer.setting.Username)
+	log.Debug("login username:" + username)
 
-	if username == v.WebServer.setting.Username && password == v.WebServer.setting.Password {
+	if role, ok := v.WebServer.authenticate(username, password); ok {
 
 		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 			"username": username,
-			"exp":      time.Now().Add(time.Hour * 24).Unix(),
+			"role":     role,
+			"exp":      time.Now().Add(v.WebServer.setting.TokenExpiry).Unix(),
 		})
 
 		// Sign and get the complete encoded token as a string using the secret
 		if tokenString, err := token.SignedString([]byte(v.WebServer.setting.SecretKey)); err == nil {
-			ctx.JSON(iris.StatusOK, iris.Map{"result": true, "token": tokenString})
+			ctx.JSON(iris.StatusOK, iris.Map{"result": true, "token": tokenString, "role": role})
 		} else {
 			ctx.JSON(iris.StatusOK, iris.Map{"result": false, "msg": err.Error()})
 		}
@@ -131,7 +136,7 @@ func (v *View) Login(ctx *iris.Context) {
 }
 
 func (v *View) GetProjects(ctx *iris.Context) {
-	if projects, err := template.LoadProjects(v.WebServer.templateConfig.ConfDir); err == nil {
+	if projects, err := template.LoadProjects(v.WebServer.TemplateConfig().ConfDir); err == nil {
 		ctx.JSON(iris.StatusOK, projects)
 	} else {
 		log.Error(err.Error())
@@ -140,7 +145,7 @@ func (v *View) GetProjects(ctx *iris.Context) {
 }
 
 func getProject(v *View, projectName string) (*template.Project, error) {
-	if projects, err := template.LoadProjects(v.WebServer.templateConfig.ConfDir); err == nil {
+	if projects, err := template.LoadProjects(v.WebServer.TemplateConfig().ConfDir); err == nil {
 		for _, proj := range projects {
 			if proj.Name == projectName {
 				return proj, nil
@@ -155,7 +160,7 @@ func getProject(v *View, projectName string) (*template.Project, error) {
 
 // key must contains prefix of resource
 func getFullKey(v *View, projectName string, key string) (string, error) {
-	confdPrefix := v.WebServer.templateConfig.Prefix
+	confdPrefix := v.WebServer.TemplateConfig().Prefix
 	if proj, err := getProject(v, projectName); err == nil {
 
 		if key == "" {
@@ -176,7 +181,7 @@ func (v *View) GetProject(ctx *iris.Context) {
 			ctx.JSON(iris.StatusNotFound, iris.Map{})
 			return
 		}
-		tmpResources, err := template.GetTemplateResourceByProject(proj, v.WebServer.templateConfig)
+		tmpResources, err := template.GetTemplateResourceByProject(proj, v.WebServer.TemplateConfig())
 		if err == nil {
 			ctx.JSON(iris.StatusOK,
 				iris.Map{"project": proj, "resources": tmpResources})
@@ -192,7 +197,7 @@ func (v *View) GetTemplates(ctx *iris.Context) {
 
 	proj, err := getProject(v, ctx.Param("projectName"))
 	filepath := iris.DecodeURL(ctx.Param("filepath"))
-	tmpResources, err := template.GetTemplateResourceByProject(proj, v.WebServer.templateConfig)
+	tmpResources, err := template.GetTemplateResourceByProject(proj, v.WebServer.TemplateConfig())
 	if err == nil {
 		for _, tr := range tmpResources {
 			if tr.Src == filepath {
@@ -224,10 +229,10 @@ func (v *View) GetItems(ctx *iris.Context) {
 			ctx.JSON(iris.StatusNotFound, iris.Map{})
 			return
 		}
-		if tmpResources, err := template.GetTemplateResourceByProject(proj, v.WebServer.templateConfig); err == nil {
+		if tmpResources, err := template.GetTemplateResourceByProject(proj, v.WebServer.TemplateConfig()); err == nil {
 			for _, rs := range tmpResources {
 				keys := rs.GetAllKeys()
-				if pairsNew, err := v.WebServer.templateConfig.StoreClient.GetValues(keys); err == nil {
+				if pairsNew, err := v.WebServer.TemplateConfig().StoreClient.GetValues(context.Background(), keys); err == nil {
 					for _, k := range keys {
 						pairs[k] = pairsNew[k]
 					}
@@ -236,7 +241,7 @@ func (v *View) GetItems(ctx *iris.Context) {
 				}
 			}
 
-			ctx.JSON(iris.StatusOK, pairs)
+			ctx.JSON(iris.StatusOK, redact.Map(pairs))
 		} else {
 			log.Error(err.Error())
 		}
@@ -250,12 +255,12 @@ func (v *View) SetItem(ctx *iris.Context) {
 	// key should contains prefix of resource
 	key := ctx.PostValue("key")
 	value := ctx.PostValue("value")
-	log.Debug("set k: %s, v: %s", key, value)
+	log.Debug("set k: %s, v: %s", key, redact.Value(key, value))
 	if key == "" {
 		ctx.JSON(iris.StatusOK, iris.Map{"result": false, "msg": "key is empty"})
 		return
 	}
-	if redisErr := v.WebServer.templateConfig.StoreClient.Set(key, value); redisErr == nil {
+	if redisErr := v.setKey(ctx, key, value); redisErr == nil {
 		ctx.JSON(iris.StatusOK, iris.Map{"result": true})
 	} else {
 		log.Error(redisErr.Error())
@@ -277,8 +282,8 @@ func (v *View) GetItem(ctx *iris.Context) {
 		}
 		key = iris.DecodeURL(key)
 		keys := []string{key}
-		if pairs, err := v.WebServer.templateConfig.StoreClient.GetValues(keys); err == nil {
-			ctx.JSON(iris.StatusOK, pairs)
+		if pairs, err := v.WebServer.TemplateConfig().StoreClient.GetValues(context.Background(), keys); err == nil {
+			ctx.JSON(iris.StatusOK, redact.Map(pairs))
 		} else {
 			log.Error(err.Error())
 			ctx.JSON(iris.StatusInternalServerError, nil)
@@ -296,7 +301,7 @@ func (v *View) DeleteItem(ctx *iris.Context) {
 		ctx.JSON(iris.StatusOK, iris.Map{"result": false, "msg": "key is empty"})
 	} else {
 		key = iris.DecodeURL(key)
-		if err := v.WebServer.templateConfig.StoreClient.Remove(key); err == nil {
+		if err := v.removeKey(ctx, key); err == nil {
 			ctx.JSON(iris.StatusOK, iris.Map{"result": true})
 		} else {
 			ctx.JSON(iris.StatusOK, iris.Map{"result": false, "msg": err.Error()})
@@ -2,27 +2,56 @@ package admin
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/iris-contrib/middleware/cors"
 	jwtmiddleware "github.com/iris-contrib/middleware/jwt"
 	"github.com/kataras/iris"
+	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/metrics"
 	"github.com/kelseyhightower/confd/resource/template"
 )
 
 type WebServer struct {
+	mu             sync.RWMutex
 	templateConfig template.Config
 	setting        Setting
 }
 
 type Setting struct {
-	Port      int    `web port`
-	Username  string `admin username`
-	Password  string `admin password`
-	SecretKey string `jwt secretKey`
+	Port           int           `web port`
+	Username       string        `admin username`
+	Password       string        `admin password`
+	ViewerUsername string        `read-only username`
+	ViewerPassword string        `read-only password`
+	SecretKey      string        `jwt secretKey`
+	TokenExpiry    time.Duration `jwt expiry`
+	TLSCert        string        `https certificate`
+	TLSKey         string        `https key`
+	ClientCA       string        `client certificate CA`
+	PushToken      string        `push bearer token`
+	EnableDebug    bool          `pprof and expvar endpoints`
+	// Reload re-reads the confd configuration and template resources.
+	Reload func() error
 }
 
 func New(templateConfig template.Config, config Setting) *WebServer {
+	if config.SecretKey == "" {
+		key, err := randomSecretKey()
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		log.Warning("No admin secret key configured, tokens will not survive a restart")
+		config.SecretKey = key
+	}
+	if config.TokenExpiry <= 0 {
+		config.TokenExpiry = 24 * time.Hour
+	}
+	if config.Username == "admin" && config.Password == "admin" {
+		log.Warning("The admin server accepts the default admin/admin credentials, set admin_password")
+	}
 	return &WebServer{
 		templateConfig: templateConfig,
 		setting:        config,
@@ -30,6 +59,20 @@ func New(templateConfig template.Config, config Setting) *WebServer {
 
 }
 
+// TemplateConfig returns the configuration of the running processor.
+func (w *WebServer) TemplateConfig() template.Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.templateConfig
+}
+
+// SetTemplateConfig replaces the configuration after a reload.
+func (w *WebServer) SetTemplateConfig(templateConfig template.Config) {
+	w.mu.Lock()
+	w.templateConfig = templateConfig
+	w.mu.Unlock()
+}
+
 func (w *WebServer) Start() {
 
 	// cross domain
@@ -49,6 +92,15 @@ func (w *WebServer) Start() {
 		},
 		SigningMethod: jwt.SigningMethodHS256,
 	})
+	// EventSource and go tool pprof cannot set headers: the event stream
+	// and the debug endpoints also take the token as a query parameter.
+	streamMDW := jwtmiddleware.New(jwtmiddleware.Config{
+		ValidationKeyGetter: func(token *jwt.Token) (interface{}, error) {
+			return []byte(w.setting.SecretKey), nil
+		},
+		SigningMethod: jwt.SigningMethodHS256,
+		Extractor:     jwtmiddleware.FromFirst(jwtmiddleware.FromAuthHeader, jwtmiddleware.FromParameter("token")),
+	})
 
 	config := iris.Configuration{Charset: "UTF-8", Gzip: true, DisablePathEscape: true}
 	app := iris.New(config)
@@ -63,19 +115,61 @@ func (w *WebServer) Start() {
 	app.Get("/static/*file", view.ServeStatic)
 	app.Get("/view/*file", view.ServeStatic)
 
+	// prometheus
+	app.Get("/metrics", iris.ToHandler(metrics.Handler()))
+	app.Get("/healthz", view.Healthz)
+	app.Get("/readyz", view.Readyz)
+	app.Get("/api/status", jwtMDW.Serve, view.Status)
+	app.Get("/api/audit", jwtMDW.Serve, view.GetAudit)
+	app.Get("/api/events", jwtMDW.Serve, view.GetEvents)
+	app.Get("/api/events/stream", streamMDW.Serve, view.StreamEvents)
+	if w.setting.EnableDebug {
+		// Profiles and /debug/pprof/cmdline may reveal secrets: they
+		// require the read-write role.
+		debug := iris.ToHandler(debugHandler())
+		app.Get("/debug/pprof/*name", streamMDW.Serve, requireWrite, debug)
+		app.Post("/debug/pprof/*name", streamMDW.Serve, requireWrite, debug)
+		app.Get("/debug/vars", streamMDW.Serve, requireWrite, debug)
+	}
+
 	//login
 	app.Post("/api/login", view.Login)
-	app.Post("/api/exec", jwtMDW.Serve, view.Execute)
+	app.Post("/api/exec", jwtMDW.Serve, requireWrite, view.Execute)
+	app.Post("/api/sync", jwtMDW.Serve, requireWrite, view.Sync)
+	app.Post("/api/reload", jwtMDW.Serve, requireWrite, view.Reload)
 	app.Get("/api/projects", jwtMDW.Serve, view.GetProjects)
 	app.Get("/api/project/:projectName", jwtMDW.Serve, view.GetProject)
 	app.Get("/api/project/:projectName/item/:key", jwtMDW.Serve, view.GetItem)
-	app.Delete("/api/project/:projectName/item/:key", jwtMDW.Serve, view.DeleteItem)
+	app.Delete("/api/project/:projectName/item/:key", jwtMDW.Serve, requireWrite, view.DeleteItem)
 	app.Get("/api/project/:projectName/items", jwtMDW.Serve, view.GetItems)
-	app.Post("/api/project/:projectName/items", jwtMDW.Serve, view.SetItem)
+	app.Post("/api/project/:projectName/items", jwtMDW.Serve, requireWrite, view.SetItem)
+	//keys
+	app.Get("/api/keys", jwtMDW.Serve, view.ListKeys)
+	app.Get("/api/keys/*key", jwtMDW.Serve, view.GetKey)
+	app.Put("/api/keys/*key", jwtMDW.Serve, requireWrite, view.PutKey)
+	app.Delete("/api/keys/*key", jwtMDW.Serve, requireWrite, view.DeleteKey)
+	app.Post("/api/push", w.pushAuth(jwtMDW), view.Push)
 	//tmpl
 	app.Get("/api/project/:projectName/tmpl/:filepath", jwtMDW.Serve, view.GetTemplates)
+	app.Get("/api/templates", jwtMDW.Serve, view.ListTemplates)
+	app.Get("/api/templates/:name", jwtMDW.Serve, view.GetTemplate)
+	app.Post("/api/templates/:name/render", jwtMDW.Serve, view.RenderTemplate)
+	app.Get("/api/templates/:name/source", jwtMDW.Serve, view.GetTemplateSource)
+	app.Post("/api/templates/:name/preview", jwtMDW.Serve, requireWrite, view.PreviewTemplate)
+	app.Put("/api/templates/:name/source", jwtMDW.Serve, requireWrite, view.SaveTemplateSource)
 	app.Websocket.OnConnection(view.WebSocketHandle)
 
-	app.Listen(fmt.Sprintf(":%d", w.setting.Port))
-	//iris.ListenTLSAuto(fmt.Sprintf(":%d", port))
+	addr := fmt.Sprintf(":%d", w.setting.Port)
+	switch {
+	case w.setting.ClientCA != "":
+		ln, err := w.tlsListener(addr)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		app.Serve(ln)
+	case w.setting.TLSCert != "":
+		app.ListenTLS(addr, w.setting.TLSCert, w.setting.TLSKey)
+	default:
+		app.Listen(addr)
+	}
 }
@@ -79,6 +79,18 @@ var http = {
     })
   },
 
+  put (path, data, successCallback, errorCallback) {
+    Vue.http.headers.common['Authorization'] = 'Bearer ' + auth.getToken()
+    Vue.http.put(path, data).then(function (response) {
+      successCallback(response)
+    }, function (response) {
+      if (errorCallback) {
+        errorCallback(response)
+      }
+      httpErrorHandle(response)
+    })
+  },
+
   delete (path, successCallback, errorCallback) {
     Vue.http.headers.common['Authorization'] = 'Bearer ' + auth.getToken()
     Vue.http.delete(path).then(function (response) {
@@ -5,6 +5,8 @@ import Home from './components/Home.vue'
 import Login from './components/Login.vue'
 import About from './components/About.vue'
 import Dashboard from './components/Dashboard.vue'
+import TemplateEditor from './components/TemplateEditor.vue'
+import Events from './components/Events.vue'
 
 function requireAuth (to, from, next) {
   if (!auth.loggedIn()) {
@@ -29,6 +31,8 @@ var router = new VueRouter({
       beforeEnter: requireAuth
     },
     { path: '/view/project/:name', component: Project, beforeEnter: requireAuth },
+    { path: '/view/template/:name', component: TemplateEditor, beforeEnter: requireAuth },
+    { path: '/view/events', component: Events, beforeEnter: requireAuth },
     {
       path: '/view/logout',
       beforeEnter (to, from, next) {
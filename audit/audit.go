@@ -0,0 +1,149 @@
+// Package audit keeps an append-only log of the configuration changes made
+// through the admin API and of the template updates they or the backend
+// caused.
+package audit
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kelseyhightower/confd/log"
+)
+
+// Actions of an Entry.
+const (
+	ActionSetKey    = "set_key"
+	ActionDeleteKey = "delete_key"
+	ActionSync      = "sync"
+	ActionUpdate    = "update"
+	// ActionEditTemplate saves the src template of a template resource
+	// edited in the admin UI.
+	ActionEditTemplate = "edit_template"
+)
+
+// ActorConfd is the actor of the template updates confd makes on its own,
+// after a watch event or an interval.
+const ActorConfd = "confd"
+
+// Entry records one action.
+type Entry struct {
+	Time time.Time `json:"timestamp"`
+	// Actor is the admin user who asked for the action, or ActorConfd.
+	Actor  string `json:"actor"`
+	Action string `json:"action"`
+	// Target is the key or the template resource acted upon. It is empty
+	// for a sync of every resource.
+	Target string `json:"target"`
+	// OldChecksum and NewChecksum are the checksums of the value or the
+	// destination file before and after the action, empty when missing.
+	OldChecksum string `json:"old_checksum,omitempty"`
+	NewChecksum string `json:"new_checksum,omitempty"`
+	Success     bool   `json:"success"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Filter selects entries. Zero fields match every entry.
+type Filter struct {
+	Actor  string
+	Action string
+	// Target matches the entries whose target starts with it.
+	Target string
+	Since  time.Time
+	Until  time.Time
+	// Limit keeps only the latest Limit entries.
+	Limit int
+}
+
+// Match reports whether e is selected by f, ignoring Limit.
+func (f Filter) Match(e Entry) bool {
+	switch {
+	case f.Actor != "" && e.Actor != f.Actor:
+		return false
+	case f.Action != "" && e.Action != f.Action:
+		return false
+	case f.Target != "" && !strings.HasPrefix(e.Target, f.Target):
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && e.Time.After(f.Until):
+		return false
+	}
+	return true
+}
+
+// A Store appends entries and returns them in the order they were
+// appended.
+type Store interface {
+	Append(e Entry) error
+	Entries() ([]Entry, error)
+}
+
+var (
+	mu    sync.RWMutex
+	store Store
+)
+
+// SetStore makes store, or nil to disable auditing, receive the entries.
+func SetStore(s Store) {
+	mu.Lock()
+	defer mu.Unlock()
+	store = s
+}
+
+// Enabled reports whether a store is set.
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return store != nil
+}
+
+// Record appends e, timestamped now, to the store. Failures are logged,
+// they never fail the action.
+func Record(e Entry) {
+	mu.RLock()
+	s := store
+	mu.RUnlock()
+	if s == nil {
+		return
+	}
+	e.Time = time.Now()
+	if err := s.Append(e); err != nil {
+		log.Error("Cannot append audit entry for %s %s: %s", e.Action, e.Target, err.Error())
+	}
+}
+
+// Query returns the entries selected by f, oldest first.
+func Query(f Filter) ([]Entry, error) {
+	mu.RLock()
+	s := store
+	mu.RUnlock()
+	if s == nil {
+		return []Entry{}, nil
+	}
+	entries, err := s.Entries()
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		if f.Match(e) {
+			selected = append(selected, e)
+		}
+	}
+	if f.Limit > 0 && len(selected) > f.Limit {
+		selected = selected[len(selected)-f.Limit:]
+	}
+	return selected, nil
+}
+
+// Checksum returns the SHA-256 checksum of data, or an empty string when
+// exists is false.
+func Checksum(data []byte, exists bool) string {
+	if !exists {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type mapKV map[string]string
+
+func (m mapKV) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for k, v := range m {
+		for _, key := range keys {
+			if strings.HasPrefix(k, key) {
+				vars[k] = v
+			}
+		}
+	}
+	return vars, nil
+}
+
+func (m mapKV) Set(key, value string) error {
+	m[key] = value
+	return nil
+}
+
+func testStore(t *testing.T, s Store) {
+	SetStore(s)
+	defer SetStore(nil)
+	Record(Entry{Actor: "admin", Action: ActionSetKey, Target: "/app/db", NewChecksum: Checksum([]byte("x"), true), Success: true})
+	Record(Entry{Actor: ActorConfd, Action: ActionUpdate, Target: "nginx", Success: true})
+	Record(Entry{Actor: "admin", Action: ActionDeleteKey, Target: "/app/port", Success: true})
+
+	entries, err := Query(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].Target != "/app/db" || entries[2].Target != "/app/port" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	entries, err = Query(Filter{Actor: "admin", Target: "/app/", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Action != ActionDeleteKey {
+		t.Fatalf("unexpected filtered entries %+v", entries)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "confd-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	testStore(t, NewFileStore(filepath.Join(dir, "audit.log")))
+}
+
+func TestBackendStore(t *testing.T) {
+	testStore(t, NewBackendStore(mapKV{}, "/confd/audit"))
+}
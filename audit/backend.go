@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// KV is the part of a backend client a BackendStore needs.
+type KV interface {
+	GetValues(ctx context.Context, keys []string) (map[string]string, error)
+	Set(key string, value string) error
+}
+
+// BackendStore writes each entry as JSON to its own key below a prefix of
+// the backend. Keys are timestamps, so that they sort in append order.
+type BackendStore struct {
+	mu     sync.Mutex
+	client KV
+	prefix string
+	last   int64
+}
+
+// NewBackendStore returns a BackendStore writing below prefix with client.
+func NewBackendStore(client KV, prefix string) *BackendStore {
+	return &BackendStore{client: client, prefix: path.Join("/", prefix)}
+}
+
+// nextKey returns a key later than the previous ones.
+func (s *BackendStore) nextKey(t time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := t.UnixNano()
+	if n <= s.last {
+		n = s.last + 1
+	}
+	s.last = n
+	return path.Join(s.prefix, fmt.Sprintf("%020d", n))
+}
+
+func (s *BackendStore) Append(e Entry) error {
+	value, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(s.nextKey(e.Time), string(value))
+}
+
+func (s *BackendStore) Entries() ([]Entry, error) {
+	values, err := s.client.GetValues(context.Background(), []string{s.prefix})
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		if path.Dir(k) == s.prefix {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	entries := make([]Entry, 0, len(keys))
+	for _, k := range keys {
+		var e Entry
+		if err := json.Unmarshal([]byte(values[k]), &e); err != nil {
+			return nil, fmt.Errorf("audit entry %s: %s", k, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
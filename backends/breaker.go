@@ -0,0 +1,61 @@
+package backends
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the backend while its
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("backend circuit breaker is open")
+
+// circuitBreaker stops requests to a backend for cooldown once threshold
+// requests in a row failed. A threshold of 0 disables it.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func newCircuitBreaker(config Config) *circuitBreaker {
+	cooldown := 30 * time.Second
+	if config.BreakerCooldown > 0 {
+		cooldown = time.Duration(config.BreakerCooldown) * time.Second
+	}
+	return &circuitBreaker{threshold: config.BreakerThreshold, cooldown: cooldown}
+}
+
+// allow returns ErrCircuitOpen while the breaker is open. Once the cooldown
+// is over a request is let through to probe the backend.
+func (b *circuitBreaker) allow() error {
+	if b == nil || b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if time.Now().Before(b.openUntil) {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of a request.
+func (b *circuitBreaker) record(err error) {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
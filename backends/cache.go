@@ -0,0 +1,155 @@
+package backends
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kelseyhightower/confd/log"
+)
+
+// cacheEntry holds the values fetched for one requested key.
+type cacheEntry struct {
+	values  map[string]string
+	fetched time.Time
+}
+
+// cachingClient caches the values of the wrapped StoreClient by requested
+// key, and may serve expired values while the backend fails.
+type cachingClient struct {
+	StoreClient
+	ttl        time.Duration
+	ttls       map[string]time.Duration
+	serveStale bool
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// newCachingClient wraps client with the cache settings of config, or
+// returns it unchanged when caching is disabled.
+func newCachingClient(client StoreClient, config Config) StoreClient {
+	if config.CacheTTL <= 0 && len(config.CacheTTLs) == 0 {
+		return client
+	}
+	ttls := make(map[string]time.Duration, len(config.CacheTTLs))
+	for prefix, ttl := range config.CacheTTLs {
+		ttls[prefix] = time.Duration(ttl) * time.Second
+	}
+	return &cachingClient{
+		StoreClient: client,
+		ttl:         time.Duration(config.CacheTTL) * time.Second,
+		ttls:        ttls,
+		serveStale:  config.CacheServeStale,
+		entries:     make(map[string]cacheEntry),
+	}
+}
+
+// ttlOf returns the TTL of key, that of the longest matching cache_ttls
+// prefix or else the default one.
+func (c *cachingClient) ttlOf(key string) time.Duration {
+	ttl, matchLen := c.ttl, -1
+	for prefix, t := range c.ttls {
+		if hasPathPrefix(key, prefix) && len(prefix) > matchLen {
+			ttl, matchLen = t, len(prefix)
+		}
+	}
+	return ttl
+}
+
+func (c *cachingClient) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
+	now := time.Now()
+	vars := make(map[string]string)
+	var missing []string
+	c.mu.Lock()
+	for _, key := range keys {
+		e, ok := c.entries[key]
+		if ok && now.Sub(e.fetched) < c.ttlOf(key) {
+			for k, v := range e.values {
+				vars[k] = v
+			}
+			continue
+		}
+		missing = append(missing, key)
+	}
+	c.mu.Unlock()
+	if len(missing) == 0 {
+		return vars, nil
+	}
+
+	values, err := c.StoreClient.GetValues(ctx, missing)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		if !c.serveStale {
+			return values, err
+		}
+		for _, key := range missing {
+			e, ok := c.entries[key]
+			if !ok {
+				return values, err
+			}
+			for k, v := range e.values {
+				vars[k] = v
+			}
+		}
+		log.Warning("Serving cached values, backend failed: %s", err.Error())
+		return vars, nil
+	}
+	for _, key := range missing {
+		e := cacheEntry{values: make(map[string]string), fetched: now}
+		for k, v := range values {
+			if hasPathPrefix(k, key) {
+				e.values[k] = v
+			}
+		}
+		c.entries[key] = e
+	}
+	for k, v := range values {
+		vars[k] = v
+	}
+	return vars, nil
+}
+
+// invalidate drops the entries key may be part of, and those below it.
+func (c *cachingClient) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for prefix := range c.entries {
+		if hasPathPrefix(key, prefix) || hasPathPrefix(prefix, key) {
+			delete(c.entries, prefix)
+		}
+	}
+}
+
+func (c *cachingClient) Set(key string, value string) error {
+	defer c.invalidate(key)
+	return c.StoreClient.Set(key, value)
+}
+
+func (c *cachingClient) Remove(key string) error {
+	defer c.invalidate(key)
+	return c.StoreClient.Remove(key)
+}
+
+// WatchPrefix drops the cached values of prefix once it changed, so the
+// pass it triggers reads the new ones.
+func (c *cachingClient) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+	index, err := c.StoreClient.WatchPrefix(ctx, prefix, keys, waitIndex, stopChan)
+	if err == nil {
+		c.invalidate(prefix)
+	}
+	return index, err
+}
+
+func (c *cachingClient) RetryPolicy() RetryPolicy {
+	return RetryPolicyOf(c.StoreClient)
+}
+
+func (c *cachingClient) Ping() error {
+	return Ping(c.StoreClient)
+}
+
+func (c *cachingClient) Close() error {
+	return Close(c.StoreClient)
+}
@@ -0,0 +1,79 @@
+package backends
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type countingClient struct {
+	StoreClient
+	values map[string]string
+	err    error
+	calls  int
+}
+
+func (c *countingClient) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.values, nil
+}
+
+func TestCachingClient(t *testing.T) {
+	backend := &countingClient{values: map[string]string{"/app/port": "80"}}
+	c := newCachingClient(backend, Config{CacheTTL: 60, CacheServeStale: true}).(*cachingClient)
+
+	for i := 0; i < 2; i++ {
+		vars, err := c.GetValues(context.Background(), []string{"/app"})
+		if err != nil || vars["/app/port"] != "80" {
+			t.Fatalf("GetValues = %v, %v", vars, err)
+		}
+	}
+	if backend.calls != 1 {
+		t.Errorf("backend called %d times, want 1", backend.calls)
+	}
+
+	// Expire the entry and fail the backend: the stale value is served.
+	c.ttl = 0
+	backend.err = errors.New("unavailable")
+	vars, err := c.GetValues(context.Background(), []string{"/app"})
+	if err != nil || vars["/app/port"] != "80" {
+		t.Errorf("stale GetValues = %v, %v", vars, err)
+	}
+	if _, err := c.GetValues(context.Background(), []string{"/other"}); err == nil {
+		t.Error("expected an error for a key never cached")
+	}
+}
+
+func TestCachingClientSiblingPrefixes(t *testing.T) {
+	backend := &countingClient{values: map[string]string{"/app/port": "80", "/application/port": "81"}}
+	c := newCachingClient(backend, Config{CacheTTL: 30, CacheTTLs: map[string]int{"/app": 60}}).(*cachingClient)
+
+	if got := c.ttlOf("/app/port"); got != 60*time.Second {
+		t.Errorf("ttlOf(/app/port) = %s, want 1m0s", got)
+	}
+	if got := c.ttlOf("/application"); got != 30*time.Second {
+		t.Errorf("ttlOf(/application) = %s, want 30s", got)
+	}
+
+	if _, err := c.GetValues(context.Background(), []string{"/app", "/application"}); err != nil {
+		t.Fatal(err)
+	}
+	if values := c.entries["/app"].values; len(values) != 1 || values["/app/port"] != "80" {
+		t.Errorf("entry of /app = %v, want only /app/port", values)
+	}
+	c.invalidate("/application/port")
+	if _, ok := c.entries["/app"]; !ok {
+		t.Error("entry of /app dropped on a change below /application")
+	}
+	if _, ok := c.entries["/application"]; ok {
+		t.Error("entry of /application kept on a change below it")
+	}
+	c.invalidate("/app")
+	if _, ok := c.entries["/app"]; ok {
+		t.Error("entry of /app kept on a change of /app")
+	}
+}
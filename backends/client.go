@@ -1,6 +1,7 @@
 package backends
 
 import (
+	"context"
 	"errors"
 	"strings"
 
@@ -8,6 +9,8 @@ import (
 	"github.com/kelseyhightower/confd/backends/dynamodb"
 	"github.com/kelseyhightower/confd/backends/env"
 	"github.com/kelseyhightower/confd/backends/etcd"
+	"github.com/kelseyhightower/confd/backends/memory"
+	"github.com/kelseyhightower/confd/backends/plugin"
 	"github.com/kelseyhightower/confd/backends/rancher"
 	"github.com/kelseyhightower/confd/backends/redis"
 	"github.com/kelseyhightower/confd/backends/stackengine"
@@ -17,19 +20,83 @@ import (
 )
 
 // The StoreClient interface is implemented by objects that can retrieve
-// key/value pairs from a backend store.
+// key/value pairs from a backend store. Their requests give up once ctx is
+// done, at its deadline or when confd stops or reloads.
 type StoreClient interface {
-	GetValues(keys []string) (map[string]string, error)
+	GetValues(ctx context.Context, keys []string) (map[string]string, error)
 	Set(key string, value string) error
 	Remove(key string) error
-	WatchPrefix(prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error)
+	WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error)
+}
+
+// The Pinger interface is implemented by store clients that can check
+// whether their backend is reachable.
+type Pinger interface {
+	Ping() error
+}
+
+// Ping checks whether the backend of client is reachable. Clients that do
+// not implement Pinger are assumed to be reachable.
+func Ping(client StoreClient) error {
+	if p, ok := client.(Pinger); ok {
+		return p.Ping()
+	}
+	return nil
+}
+
+// The Closer interface is implemented by store clients holding connections
+// or processes that must be released once the client is no longer used.
+type Closer interface {
+	Close() error
+}
+
+// Close releases the resources of client. Clients that do not implement
+// Closer hold none.
+func Close(client StoreClient) error {
+	if c, ok := client.(Closer); ok {
+		return c.Close()
+	}
+	return nil
 }
 
 // New is used to create a storage client based on our configuration.
 func New(config Config) (StoreClient, error) {
+	switch config.WatchResume {
+	case "", WatchResumeNow, WatchResumeOldest:
+	default:
+		return nil, errors.New("Invalid watch_resume " + config.WatchResume)
+	}
+	if config.SeedFile != "" {
+		return newSeedClient(config)
+	}
+	if len(config.Backends) > 0 {
+		return newCompositeClient(config)
+	}
 	if config.Backend == "" {
 		config.Backend = "etcd"
 	}
+	client, err := newClientFromSRV(config)
+	if err != nil {
+		return client, err
+	}
+	name := config.Name
+	if name == "" {
+		name = config.Backend
+	}
+	client = &instrumentedClient{
+		StoreClient: client,
+		backend:     name,
+		breaker:     newCircuitBreaker(config),
+		policy:      config.RetryPolicy(),
+	}
+	// Pushed keys do not go through the client: a cache would hide them.
+	if config.Backend != "push" {
+		client = newCachingClient(client, config)
+	}
+	return newEncryptingClient(client, config)
+}
+
+func newClient(config Config) (StoreClient, error) {
 	backendNodes := config.BackendNodes
 	log.Info("Backend nodes set to " + strings.Join(backendNodes, ", "))
 	switch config.Backend {
@@ -46,9 +113,18 @@ func New(config Config) (StoreClient, error) {
 	case "rancher":
 		return rancher.NewRancherClient(backendNodes)
 	case "redis":
-		return redis.NewRedisClient(backendNodes, config.ClientKey)
+		// The redis password used to be read from client_key.
+		password := config.Password
+		if password == "" {
+			password = config.ClientKey
+		}
+		return redis.NewRedisClient(backendNodes, config.Replicas, password, config.Separator)
 	case "env":
 		return env.NewEnvClient()
+	case "memory":
+		return memory.NewMemoryClient(nil), nil
+	case "push":
+		return newPushClient(), nil
 	case "vault":
 		vaultConfig := map[string]string{
 			"app-id":   config.AppID,
@@ -68,5 +144,9 @@ func New(config Config) (StoreClient, error) {
 	case "stackengine":
 		return stackengine.NewStackEngineClient(backendNodes, config.Scheme, config.ClientCert, config.ClientKey, config.ClientCaKeys, config.AuthToken)
 	}
+	if path, ok := config.Plugins[config.Backend]; ok {
+		log.Info("Loading backend plugin " + path)
+		return plugin.New(path)
+	}
 	return nil, errors.New("Invalid backend")
 }
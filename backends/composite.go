@@ -0,0 +1,299 @@
+package backends
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/kelseyhightower/confd/log"
+)
+
+const (
+	// ModeRoute sends every key to the child backend with the longest
+	// matching prefix, and to the children whose prefix lies below it.
+	ModeRoute = "route"
+	// ModeOverlay queries every child backend; values from later backends
+	// override those of earlier ones.
+	ModeOverlay = "overlay"
+)
+
+type childClient struct {
+	name   string
+	prefix string
+	client StoreClient
+}
+
+// compositeClient is a StoreClient made of several child backends.
+type compositeClient struct {
+	mode     string
+	children []childClient
+	policy   RetryPolicy
+
+	mu      sync.Mutex
+	index   uint64
+	indexes map[string][]uint64
+}
+
+func newCompositeClient(config Config) (StoreClient, error) {
+	mode := config.Mode
+	if mode == "" {
+		mode = ModeRoute
+	}
+	if mode != ModeRoute && mode != ModeOverlay {
+		return nil, fmt.Errorf("Invalid backend mode %s", mode)
+	}
+	c := &compositeClient{mode: mode, policy: config.RetryPolicy(), indexes: make(map[string][]uint64)}
+	for i, childConfig := range config.Backends {
+		childConfig.Plugins = config.Plugins
+		if childConfig.EncryptionKeyFile == "" && childConfig.EncryptionKeyCmd == "" {
+			childConfig.EncryptionKeyFile = config.EncryptionKeyFile
+			childConfig.EncryptionKeyCmd = config.EncryptionKeyCmd
+		}
+		client, err := New(childConfig)
+		if err != nil {
+			return nil, err
+		}
+		name := childName(childConfig, i)
+		prefix := ""
+		if childConfig.Prefix != "" {
+			prefix = path.Join("/", childConfig.Prefix)
+		}
+		log.Info(fmt.Sprintf("Backend %s handles prefix %q", name, prefix))
+		c.children = append(c.children, childClient{name: name, prefix: prefix, client: client})
+	}
+	return c, nil
+}
+
+// Children returns the child backends of a client made of several backends,
+// by name. It is empty for other clients.
+func Children(client StoreClient) map[string]StoreClient {
+	children := make(map[string]StoreClient)
+	if c, ok := client.(*compositeClient); ok {
+		for _, child := range c.children {
+			children[child.name] = child.client
+		}
+	}
+	return children
+}
+
+// childName returns the name of the i-th child backend of a composite
+// backend, config.
+func childName(config Config, i int) string {
+	if config.Name != "" {
+		return config.Name
+	}
+	return fmt.Sprintf("%s#%d", config.Backend, i)
+}
+
+// hasPathPrefix reports whether key is prefix or lies below it.
+func hasPathPrefix(key, prefix string) bool {
+	if prefix == "" || prefix == "/" {
+		return true
+	}
+	return key == prefix || strings.HasPrefix(key, prefix+"/")
+}
+
+// route returns the index of the child responsible for key, or -1.
+func (c *compositeClient) route(key string) int {
+	match, matchLen := -1, -1
+	for i, child := range c.children {
+		if hasPathPrefix(key, child.prefix) && len(child.prefix) > matchLen {
+			match, matchLen = i, len(child.prefix)
+		}
+	}
+	return match
+}
+
+// GetValues fans keys out to the child backends and merges the results.
+func (c *compositeClient) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
+	vars := make(map[string]string)
+	if c.mode == ModeOverlay {
+		for _, child := range c.children {
+			values, err := child.client.GetValues(ctx, keys)
+			if err != nil {
+				return vars, fmt.Errorf("backend %s: %s", child.name, err.Error())
+			}
+			for k, v := range values {
+				vars[k] = v
+			}
+		}
+		return vars, nil
+	}
+
+	routed := make([][]string, len(c.children))
+	for _, key := range keys {
+		found := false
+		if i := c.route(key); i >= 0 {
+			routed[i] = appendKey(routed[i], key)
+			found = true
+		}
+		// Children whose prefix lies below key hold part of its values.
+		for i, child := range c.children {
+			if child.prefix != "" && child.prefix != key && hasPathPrefix(child.prefix, key) {
+				routed[i] = appendKey(routed[i], child.prefix)
+				found = true
+			}
+		}
+		if !found {
+			return vars, errors.New("no backend configured for key " + key)
+		}
+	}
+	for i, childKeys := range routed {
+		if len(childKeys) == 0 {
+			continue
+		}
+		child := c.children[i]
+		values, err := child.client.GetValues(ctx, childKeys)
+		if err != nil {
+			return vars, fmt.Errorf("backend %s: %s", child.name, err.Error())
+		}
+		for k, v := range values {
+			// Keys below the prefix of another child belong to it.
+			if c.route(k) == i {
+				vars[k] = v
+			}
+		}
+	}
+	return vars, nil
+}
+
+func appendKey(keys []string, key string) []string {
+	for _, k := range keys {
+		if k == key {
+			return keys
+		}
+	}
+	return append(keys, key)
+}
+
+// RetryPolicy returns the retry policy of the composite backend itself,
+// not those of its children.
+func (c *compositeClient) RetryPolicy() RetryPolicy {
+	return c.policy
+}
+
+// Ping checks every child backend.
+func (c *compositeClient) Ping() error {
+	for _, child := range c.children {
+		if err := Ping(child.client); err != nil {
+			return fmt.Errorf("backend %s: %s", child.name, err.Error())
+		}
+	}
+	return nil
+}
+
+// Close closes every child backend.
+func (c *compositeClient) Close() error {
+	var err error
+	for _, child := range c.children {
+		if cerr := Close(child.client); cerr != nil && err == nil {
+			err = fmt.Errorf("backend %s: %s", child.name, cerr.Error())
+		}
+	}
+	return err
+}
+
+// writeClient returns the child that Set and Remove apply to.
+func (c *compositeClient) writeClient(key string) (StoreClient, error) {
+	if c.mode == ModeOverlay {
+		return c.children[len(c.children)-1].client, nil
+	}
+	i := c.route(key)
+	if i < 0 {
+		return nil, errors.New("no backend configured for key " + key)
+	}
+	return c.children[i].client, nil
+}
+
+func (c *compositeClient) Set(key string, value string) error {
+	client, err := c.writeClient(key)
+	if err != nil {
+		return err
+	}
+	return client.Set(key, value)
+}
+
+func (c *compositeClient) Remove(key string) error {
+	client, err := c.writeClient(key)
+	if err != nil {
+		return err
+	}
+	return client.Remove(key)
+}
+
+// watchChildren returns the indexes of the children that may hold keys
+// below prefix.
+func (c *compositeClient) watchChildren(prefix string) []int {
+	var children []int
+	for i, child := range c.children {
+		if c.mode == ModeOverlay || hasPathPrefix(prefix, child.prefix) || hasPathPrefix(child.prefix, prefix) {
+			children = append(children, i)
+		}
+	}
+	return children
+}
+
+type childWatchResponse struct {
+	child     int
+	waitIndex uint64
+	err       error
+}
+
+// WatchPrefix watches prefix on every relevant child and returns as soon as
+// one of them reports a change. The children's own wait indexes are kept
+// per prefix; the returned index is a counter local to the composite client.
+func (c *compositeClient) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+	// return something > 0 to trigger a key retrieval from the store
+	if waitIndex == 0 {
+		return c.nextIndex(), nil
+	}
+
+	children := c.watchChildren(prefix)
+	if len(children) == 0 {
+		<-stopChan
+		return waitIndex, nil
+	}
+
+	c.mu.Lock()
+	indexes, ok := c.indexes[prefix]
+	if !ok {
+		indexes = make([]uint64, len(c.children))
+		c.indexes[prefix] = indexes
+	}
+	childIndexes := make([]uint64, len(indexes))
+	copy(childIndexes, indexes)
+	c.mu.Unlock()
+
+	stop := make(chan bool)
+	defer close(stop)
+	respChan := make(chan childWatchResponse, len(children))
+	for _, i := range children {
+		go func(i int) {
+			index, err := c.children[i].client.WatchPrefix(ctx, prefix, keys, childIndexes[i], stop)
+			respChan <- childWatchResponse{i, index, err}
+		}(i)
+	}
+
+	select {
+	case <-stopChan:
+		return waitIndex, nil
+	case r := <-respChan:
+		if r.err != nil {
+			return waitIndex, fmt.Errorf("backend %s: %s", c.children[r.child].name, r.err.Error())
+		}
+		c.mu.Lock()
+		c.indexes[prefix][r.child] = r.waitIndex
+		c.mu.Unlock()
+		return c.nextIndex(), nil
+	}
+}
+
+func (c *compositeClient) nextIndex() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.index++
+	return c.index
+}
@@ -0,0 +1,109 @@
+package backends
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// memClient is a StoreClient keeping its values in a map, used to exercise
+// the composite client without a real backend.
+type memClient struct {
+	vars map[string]string
+}
+
+func (m *memClient) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, key := range keys {
+		for k, v := range m.vars {
+			if hasPathPrefix(k, key) {
+				vars[k] = v
+			}
+		}
+	}
+	return vars, nil
+}
+
+func (m *memClient) Set(key string, value string) error {
+	m.vars[key] = value
+	return nil
+}
+
+func (m *memClient) Remove(key string) error {
+	delete(m.vars, key)
+	return nil
+}
+
+func (m *memClient) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+	<-stopChan
+	return waitIndex, nil
+}
+
+func TestCompositeRoute(t *testing.T) {
+	secrets := &memClient{map[string]string{"/secrets/db": "hunter2", "/app/name": "ignored"}}
+	rest := &memClient{map[string]string{"/app/name": "confd", "/secrets/db": "ignored"}}
+	c := &compositeClient{
+		mode: ModeRoute,
+		children: []childClient{
+			{name: "rest", prefix: "", client: rest},
+			{name: "secrets", prefix: "/secrets", client: secrets},
+		},
+	}
+	vars, err := c.GetValues(context.Background(), []string{"/app", "/secrets"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := map[string]string{"/app/name": "confd", "/secrets/db": "hunter2"}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("GetValues() = %v, want %v", vars, want)
+	}
+
+	if err := c.Set("/secrets/api", "token"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if secrets.vars["/secrets/api"] != "token" {
+		t.Errorf("Set did not route /secrets/api to the secrets backend")
+	}
+}
+
+func TestCompositeRouteParentKey(t *testing.T) {
+	vault := &memClient{map[string]string{"/app/secrets/db": "hunter2"}}
+	rest := &memClient{map[string]string{"/app/name": "confd", "/app/secrets/db": "ignored"}}
+	c := &compositeClient{
+		mode: ModeRoute,
+		children: []childClient{
+			{name: "rest", prefix: "/app", client: rest},
+			{name: "vault", prefix: "/app/secrets", client: vault},
+		},
+	}
+	want := map[string]string{"/app/name": "confd", "/app/secrets/db": "hunter2"}
+	for _, keys := range [][]string{{"/"}, {"/app"}} {
+		vars, err := c.GetValues(context.Background(), keys)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !reflect.DeepEqual(vars, want) {
+			t.Errorf("GetValues(%v) = %v, want %v", keys, vars, want)
+		}
+	}
+}
+
+func TestCompositeOverlay(t *testing.T) {
+	base := &memClient{map[string]string{"/app/host": "localhost", "/app/port": "80"}}
+	override := &memClient{map[string]string{"/app/port": "8080"}}
+	c := &compositeClient{
+		mode: ModeOverlay,
+		children: []childClient{
+			{name: "base", client: base},
+			{name: "override", client: override},
+		},
+	}
+	vars, err := c.GetValues(context.Background(), []string{"/app"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := map[string]string{"/app/host": "localhost", "/app/port": "8080"}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("GetValues() = %v, want %v", vars, want)
+	}
+}
@@ -1,18 +1,91 @@
 package backends
 
 type Config struct {
-	AuthToken    string
-	AuthType     string
-	Backend      string
-	BasicAuth    bool
-	ClientCaKeys string
-	ClientCert   string
-	ClientKey    string
-	BackendNodes []string
-	Password     string
-	Scheme       string
-	Table        string
-	Username     string
-	AppID        string
-	UserID       string
+	AuthToken    string            `toml:"auth_token"`
+	AuthType     string            `toml:"auth_type"`
+	Backend      string            `toml:"backend"`
+	BasicAuth    bool              `toml:"basic_auth"`
+	ClientCaKeys string            `toml:"client_cakeys"`
+	ClientCert   string            `toml:"client_cert"`
+	ClientKey    string            `toml:"client_key"`
+	BackendNodes []string          `toml:"nodes"`
+	Password     string            `toml:"password"`
+	PasswordFile string            `toml:"password_file"`
+	PasswordEnv  string            `toml:"password_env"`
+	Scheme       string            `toml:"scheme"`
+	SRVRecord    string            `toml:"srv_record"`
+	SRVRefresh   int               `toml:"srv_refresh"`
+	Table        string            `toml:"table"`
+	Username     string            `toml:"username"`
+	AppID        string            `toml:"app_id"`
+	UserID       string            `toml:"user_id"`
+	Plugins      map[string]string `toml:"-"`
+	// Separator separates the elements of redis keys, such as ":" in
+	// app:prod:db:host. confd translates its / separated keys to it.
+	Separator string `toml:"separator"`
+	// Replicas are redis replicas serving reads, while writes go to
+	// BackendNodes.
+	Replicas []string `toml:"replicas"`
+	// EncryptionKeyFile holds the base64 encoded master key values are
+	// encrypted with, EncryptionKeyCmd prints it.
+	EncryptionKeyFile string `toml:"encryption_key_file"`
+	EncryptionKeyCmd  string `toml:"encryption_key_cmd"`
+	// SeedFile is a JSON or YAML file of keys and values served until the
+	// backend is reachable.
+	SeedFile string `toml:"seed_file"`
+	// WatchResume is where the watches of template resources start after a
+	// restart: WatchResumeNow, the default, or WatchResumeOldest.
+	WatchResume string `toml:"watch_resume"`
+
+	// RetryBase and RetryMax bound the delay in seconds between retries
+	// of a failing backend, RetryJitter randomizes it and MaxRetries, when
+	// set, makes confd give up.
+	RetryBase   int     `toml:"retry_base"`
+	RetryMax    int     `toml:"retry_max"`
+	RetryJitter float64 `toml:"retry_jitter"`
+	MaxRetries  int     `toml:"max_retries"`
+	// BreakerThreshold failed requests in a row stop requests to the
+	// backend for BreakerCooldown seconds.
+	BreakerThreshold int `toml:"breaker_threshold"`
+	BreakerCooldown  int `toml:"breaker_cooldown"`
+	// CacheTTL is the number of seconds values are cached for, CacheTTLs
+	// overrides it by key prefix and CacheServeStale serves expired
+	// values while the backend fails.
+	CacheTTL        int            `toml:"cache_ttl"`
+	CacheTTLs       map[string]int `toml:"cache_ttls"`
+	CacheServeStale bool           `toml:"cache_serve_stale"`
+
+	// Name identifies a child backend in logs.
+	Name string `toml:"name"`
+	// Prefix is the key prefix routed to a child backend in route mode.
+	Prefix string `toml:"prefix"`
+	// Backends, when set, composes several child backends into one
+	// StoreClient according to Mode ("route" or "overlay").
+	Backends []Config `toml:"-"`
+	Mode     string   `toml:"-"`
+}
+
+// The watch_resume settings.
+const (
+	// WatchResumeNow watches from the current index after a restart.
+	WatchResumeNow = "now"
+	// WatchResumeOldest watches from the index persisted by the last
+	// successful pass before the restart, so that the changes made since
+	// are seen by the watch.
+	WatchResumeOldest = "oldest"
+)
+
+// WatchResumes returns the WatchResume settings of config, by backend name:
+// that of config itself under the name of its backend, or those of its
+// Backends. The watches on several backends at once always resume now.
+func (config Config) WatchResumes() map[string]string {
+	resumes := make(map[string]string)
+	if len(config.Backends) == 0 {
+		resumes[config.Backend] = config.WatchResume
+		return resumes
+	}
+	for i, c := range config.Backends {
+		resumes[childName(c, i)] = c.WatchResume
+	}
+	return resumes
 }
@@ -1,6 +1,7 @@
 package consul
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
@@ -8,13 +9,19 @@ import (
 	"net/http"
 	"path"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/consul/api"
 )
 
 // Client provides a wrapper around the consulkv client
 type ConsulClient struct {
-	client *api.KV
+	client  *api.KV
+	session *api.Session
+	// mu guards lockSession, the session holding locks.
+	mu          sync.Mutex
+	lockSession string
 }
 
 // NewConsulClient returns a new client to Consul for the given address
@@ -53,13 +60,16 @@ func New(nodes []string, scheme, cert, key, caCert string) (*ConsulClient, error
 	if err != nil {
 		return nil, err
 	}
-	return &ConsulClient{client.KV()}, nil
+	return &ConsulClient{client: client.KV(), session: client.Session()}, nil
 }
 
 // GetValues queries Consul for keys
-func (c *ConsulClient) GetValues(keys []string) (map[string]string, error) {
+func (c *ConsulClient) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
 	vars := make(map[string]string)
 	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return vars, err
+		}
 		key := strings.TrimPrefix(key, "/")
 		pairs, _, err := c.client.List(key, nil)
 		if err != nil {
@@ -83,7 +93,7 @@ func (c *ConsulClient) Remove(key string) error {
 func (c *ConsulClient) Set(key string, value string) error {
 	return errors.New("function not supported")
 }
-func (c *ConsulClient) WatchPrefix(prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+func (c *ConsulClient) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
 	respChan := make(chan watchResponse)
 	go func() {
 		opts := api.QueryOptions{
@@ -100,8 +110,57 @@ func (c *ConsulClient) WatchPrefix(prefix string, keys []string, waitIndex uint6
 		select {
 		case <-stopChan:
 			return waitIndex, nil
+		case <-ctx.Done():
+			return waitIndex, ctx.Err()
 		case r := <-respChan:
 			return r.waitIndex, r.err
 		}
 	}
 }
+
+// Lock acquires key for id with a session expiring after ttl, renewing the
+// session when it exists already.
+func (c *ConsulClient) Lock(key, id string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lockSession != "" {
+		entry, _, err := c.session.Renew(c.lockSession, nil)
+		if err != nil {
+			return false, err
+		}
+		if entry == nil {
+			// The session expired meanwhile.
+			c.lockSession = ""
+		}
+	}
+	if c.lockSession == "" {
+		sessionID, _, err := c.session.Create(&api.SessionEntry{
+			Name:     "confd " + id,
+			TTL:      ttl.String(),
+			Behavior: api.SessionBehaviorDelete,
+		}, nil)
+		if err != nil {
+			return false, err
+		}
+		c.lockSession = sessionID
+	}
+	pair := &api.KVPair{Key: strings.TrimPrefix(key, "/"), Value: []byte(id), Session: c.lockSession}
+	ok, _, err := c.client.Acquire(pair, nil)
+	return ok, err
+}
+
+// Unlock releases key and destroys the session holding it.
+func (c *ConsulClient) Unlock(key, id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lockSession == "" {
+		return nil
+	}
+	pair := &api.KVPair{Key: strings.TrimPrefix(key, "/"), Value: []byte(id), Session: c.lockSession}
+	if _, _, err := c.client.Release(pair, nil); err != nil {
+		return err
+	}
+	_, err := c.session.Destroy(c.lockSession, nil)
+	c.lockSession = ""
+	return err
+}
@@ -0,0 +1,61 @@
+package backends
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// credentialsCheckInterval is how often password_file is checked for a new
+// password.
+var credentialsCheckInterval = 10 * time.Second
+
+// password returns the password of config: the content of PasswordFile,
+// else the value of the PasswordEnv environment variable, else Password.
+func (config Config) password() (string, error) {
+	switch {
+	case config.PasswordFile != "":
+		data, err := ioutil.ReadFile(config.PasswordFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case config.PasswordEnv != "":
+		password, ok := os.LookupEnv(config.PasswordEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", config.PasswordEnv)
+		}
+		return password, nil
+	}
+	return config.Password, nil
+}
+
+// newClientWithCredentials creates the client of config with its resolved
+// password. With a password_file, the client is recreated whenever the
+// password in the file changes.
+func newClientWithCredentials(config Config) (StoreClient, error) {
+	password, err := config.password()
+	if err != nil {
+		return nil, err
+	}
+	config.Password = password
+	client, err := newClient(config)
+	if err != nil || config.PasswordFile == "" {
+		return client, err
+	}
+	return &reconnectingClient{
+		client:   client,
+		config:   config,
+		interval: credentialsCheckInterval,
+		checked:  time.Now(),
+		what:     "password in " + config.PasswordFile,
+		update: func(config Config) (Config, error) {
+			password, err := config.password()
+			config.Password = password
+			return config, err
+		},
+		newClient: newClient,
+	}, nil
+}
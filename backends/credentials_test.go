@@ -0,0 +1,165 @@
+package backends
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPasswordSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "confd-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(file, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("CONFD_TEST_PASSWORD", "from-env")
+	defer os.Unsetenv("CONFD_TEST_PASSWORD")
+
+	tests := []struct {
+		config Config
+		want   string
+	}{
+		{Config{Password: "flat"}, "flat"},
+		{Config{Password: "flat", PasswordEnv: "CONFD_TEST_PASSWORD"}, "from-env"},
+		{Config{PasswordFile: file, PasswordEnv: "CONFD_TEST_PASSWORD"}, "from-file"},
+	}
+	for _, tt := range tests {
+		got, err := tt.config.password()
+		if err != nil || got != tt.want {
+			t.Errorf("password() = %q, %v, want %q", got, err, tt.want)
+		}
+	}
+	if _, err := (Config{PasswordEnv: "CONFD_TEST_UNSET"}).password(); err == nil {
+		t.Error("expected an error for an unset environment variable")
+	}
+}
+
+func TestRotatingClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "confd-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(file, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := newClientWithCredentials(Config{Backend: "memory", PasswordFile: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := client.(*reconnectingClient)
+	c.checked = time.Time{}
+	first := c.current(true)
+	c.checked = time.Time{}
+	if c.current(true) != first {
+		t.Fatal("client recreated although the password did not change")
+	}
+	if err := ioutil.WriteFile(file, []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c.checked = time.Time{}
+	if c.current(true) == first {
+		t.Fatal("client not recreated after the password changed")
+	}
+	if c.config.Password != "new" {
+		t.Errorf("password = %q, want %q", c.config.Password, "new")
+	}
+}
+
+type closeRecordingClient struct {
+	StoreClient
+	closed bool
+}
+
+func (c *closeRecordingClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestRotatingClientClosesPrevious(t *testing.T) {
+	dir, err := ioutil.TempDir("", "confd-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(file, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := newClientWithCredentials(Config{Backend: "memory", PasswordFile: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := client.(*reconnectingClient)
+	previous := &closeRecordingClient{StoreClient: c.client}
+	c.client = previous
+	if err := ioutil.WriteFile(file, []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c.checked = time.Time{}
+	if c.current(true) == previous {
+		t.Fatal("client not recreated after the password changed")
+	}
+	if !previous.closed {
+		t.Error("previous client not closed after the password changed")
+	}
+}
+
+// TestRedisPassword connects to the redis server at CONFD_TEST_REDIS,
+// 127.0.0.1:6379 by default, requiring the password in
+// CONFD_TEST_REDIS_PASSWORD.
+func TestRedisPassword(t *testing.T) {
+	password := os.Getenv("CONFD_TEST_REDIS_PASSWORD")
+	if password == "" {
+		t.Skip("CONFD_TEST_REDIS_PASSWORD is not set")
+	}
+	addr := os.Getenv("CONFD_TEST_REDIS")
+	if addr == "" {
+		addr = "127.0.0.1:6379"
+	}
+	dir, err := ioutil.TempDir("", "confd-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(file, []byte(password+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("CONFD_TEST_PASSWORD", password)
+	defer os.Unsetenv("CONFD_TEST_PASSWORD")
+
+	configs := map[string]Config{
+		"password_file": {PasswordFile: file},
+		"password_env":  {PasswordEnv: "CONFD_TEST_PASSWORD"},
+		"client_key":    {ClientKey: password},
+	}
+	for desc, config := range configs {
+		config.Backend = "redis"
+		config.BackendNodes = []string{addr}
+		client, err := newClientWithCredentials(config)
+		if err != nil {
+			t.Errorf("%s: %s", desc, err)
+			continue
+		}
+		if err := Ping(client); err != nil {
+			t.Errorf("%s: %s", desc, err)
+		}
+		Close(client)
+	}
+
+	wrong := Config{Backend: "redis", BackendNodes: []string{addr}, Password: "wrong"}
+	if client, err := newClientWithCredentials(wrong); err == nil {
+		if Ping(client) == nil {
+			t.Error("connected with a wrong password")
+		}
+		Close(client)
+	}
+}
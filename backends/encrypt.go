@@ -0,0 +1,199 @@
+package backends
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+)
+
+// encryptedPrefix starts the values written by an encryptingClient.
+const encryptedPrefix = "enc:v1:"
+
+// dataKeySize is the size of the AES-256 key generated for each value.
+const dataKeySize = 32
+
+// encryptingClient stores the values of the wrapped StoreClient as envelopes:
+// each value is sealed with AES-GCM under a random data key, itself sealed
+// under the master key. Both are authenticated with the key of the value,
+// so that an envelope copied to another key does not decrypt. Values without encryptedPrefix are read as they
+// are, so that a key space can be encrypted gradually.
+type encryptingClient struct {
+	StoreClient
+	master cipher.AEAD
+}
+
+// newEncryptingClient wraps client with the master key of config, or
+// returns it unchanged when no key is configured.
+func newEncryptingClient(client StoreClient, config Config) (StoreClient, error) {
+	if config.EncryptionKeyFile == "" && config.EncryptionKeyCmd == "" {
+		return client, nil
+	}
+	key, err := config.encryptionKey()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load the encryption key: %s", err.Error())
+	}
+	master, err := newGCM(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %s", err.Error())
+	}
+	return &encryptingClient{StoreClient: client, master: master}, nil
+}
+
+// encryptionKey returns the master key of config: the base64 encoded
+// content of EncryptionKeyFile, else the output of EncryptionKeyCmd, such
+// as a KMS decrypt command.
+func (config Config) encryptionKey() ([]byte, error) {
+	var encoded []byte
+	var err error
+	if config.EncryptionKeyFile != "" {
+		encoded, err = ioutil.ReadFile(config.EncryptionKeyFile)
+	} else {
+		var stderr bytes.Buffer
+		cmd := exec.Command("/bin/sh", "-c", config.EncryptionKeyCmd)
+		cmd.Stderr = &stderr
+		encoded, err = cmd.Output()
+		if err != nil {
+			err = fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(stderr.String()))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with aead, authenticating additionalData as well,
+// and prefixes it with a random nonce.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts what seal returned for the same additionalData.
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	n := aead.NonceSize()
+	return aead.Open(nil, sealed[:n], sealed[n:], additionalData)
+}
+
+// encrypt returns the envelope of the value of key: encryptedPrefix, the
+// sealed data key and the sealed value, base64 encoded and separated by ":".
+func (c *encryptingClient) encrypt(key, value string) (string, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealedValue, err := seal(aead, []byte(value), []byte(key))
+	if err != nil {
+		return "", err
+	}
+	sealedKey, err := seal(c.master, dataKey, []byte(key))
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealedKey) + ":" +
+		base64.StdEncoding.EncodeToString(sealedValue), nil
+}
+
+// decrypt returns the value of key held in envelope, or value itself when
+// it is not an envelope.
+func (c *encryptingClient) decrypt(key, value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 2 {
+		return "", fmt.Errorf("cannot decrypt %s: malformed envelope", key)
+	}
+	sealedKey, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt %s: %s", key, err.Error())
+	}
+	sealedValue, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt %s: %s", key, err.Error())
+	}
+	dataKey, err := open(c.master, sealedKey, []byte(key))
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt %s, wrong encryption key or envelope of another key? %s", key, err.Error())
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt %s: %s", key, err.Error())
+	}
+	plaintext, err := open(aead, sealedValue, []byte(key))
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt %s: %s", key, err.Error())
+	}
+	return string(plaintext), nil
+}
+
+func (c *encryptingClient) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
+	values, err := c.StoreClient.GetValues(ctx, keys)
+	if err != nil {
+		return values, err
+	}
+	vars := make(map[string]string, len(values))
+	for k, v := range values {
+		if vars[k], err = c.decrypt(k, v); err != nil {
+			return nil, err
+		}
+	}
+	return vars, nil
+}
+
+func (c *encryptingClient) StreamValues(ctx context.Context, keys []string, fn func(key, value string) error) error {
+	return StreamValues(ctx, c.StoreClient, keys, func(key, value string) error {
+		value, err := c.decrypt(key, value)
+		if err != nil {
+			return err
+		}
+		return fn(key, value)
+	})
+}
+
+func (c *encryptingClient) Set(key string, value string) error {
+	envelope, err := c.encrypt(key, value)
+	if err != nil {
+		return err
+	}
+	return c.StoreClient.Set(key, envelope)
+}
+
+func (c *encryptingClient) RetryPolicy() RetryPolicy {
+	return RetryPolicyOf(c.StoreClient)
+}
+
+func (c *encryptingClient) Ping() error {
+	return Ping(c.StoreClient)
+}
+
+func (c *encryptingClient) Close() error {
+	return Close(c.StoreClient)
+}
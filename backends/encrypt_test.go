@@ -0,0 +1,66 @@
+package backends
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+type mapClient struct {
+	countingClient
+}
+
+func (c *mapClient) Set(key, value string) error {
+	c.values[key] = value
+	return nil
+}
+
+func TestEncryptingClient(t *testing.T) {
+	f, err := ioutil.TempFile("", "confd-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))) + "\n")
+	f.Close()
+
+	backend := &mapClient{countingClient{values: map[string]string{"/app/port": "80"}}}
+	client, err := newEncryptingClient(backend, Config{EncryptionKeyFile: f.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Set("/app/password", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	stored := backend.values["/app/password"]
+	if !strings.HasPrefix(stored, encryptedPrefix) || strings.Contains(stored, "s3cret") {
+		t.Fatalf("stored %q, want an envelope", stored)
+	}
+
+	vars, err := client.GetValues(context.Background(), []string{"/app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["/app/password"] != "s3cret" || vars["/app/port"] != "80" {
+		t.Errorf("GetValues = %v", vars)
+	}
+
+	// The envelope does not decrypt under another key.
+	backend.values["/app/user"] = stored
+	if _, err := client.GetValues(context.Background(), []string{"/app/user"}); err == nil {
+		t.Error("GetValues succeeded with an envelope moved to another key")
+	}
+	delete(backend.values, "/app/user")
+
+	// Another master key cannot open the envelope.
+	other := &encryptingClient{StoreClient: backend}
+	if other.master, err = newGCM([]byte(strings.Repeat("x", 32))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.GetValues(context.Background(), []string{"/app"}); err == nil {
+		t.Error("GetValues succeeded with the wrong key")
+	}
+}
@@ -1,12 +1,14 @@
 package env
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/redact"
 )
 
 var replacer = strings.NewReplacer("/", "_")
@@ -20,7 +22,7 @@ func NewEnvClient() (*Client, error) {
 }
 
 // GetValues queries the environment for keys
-func (c *Client) GetValues(keys []string) (map[string]string, error) {
+func (c *Client) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
 	allEnvVars := os.Environ()
 	envMap := make(map[string]string)
 	for _, e := range allEnvVars {
@@ -37,7 +39,7 @@ func (c *Client) GetValues(keys []string) (map[string]string, error) {
 		}
 	}
 
-	log.Debug(fmt.Sprintf("Key Map: %#v", vars))
+	log.Debug(fmt.Sprintf("Key Map: %#v", redact.Map(vars)))
 
 	return vars, nil
 }
@@ -60,7 +62,7 @@ func (c *Client) Set(key string, value string) error {
 func (c *Client) Remove(key string) error {
 	return errors.New("function not supported")
 }
-func (c *Client) WatchPrefix(prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+func (c *Client) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
 	<-stopChan
 	return 0, nil
 }
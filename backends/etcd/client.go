@@ -1,6 +1,7 @@
 package etcd
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
@@ -11,7 +12,6 @@ import (
 	"time"
 
 	"github.com/coreos/etcd/client"
-	"golang.org/x/net/context"
 )
 
 // Client is a wrapper around the etcd client
@@ -82,10 +82,10 @@ func NewEtcdClient(machines []string, cert, key, caCert string, basicAuth bool,
 }
 
 // GetValues queries etcd for keys prefixed by prefix.
-func (c *Client) GetValues(keys []string) (map[string]string, error) {
+func (c *Client) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
 	vars := make(map[string]string)
 	for _, key := range keys {
-		resp, err := c.client.Get(context.Background(), key, &client.GetOptions{
+		resp, err := c.client.Get(ctx, key, &client.GetOptions{
 			Recursive: true,
 			Sort:      true,
 			Quorum:    true,
@@ -122,18 +122,53 @@ func (c *Client) Set(key string, value string) error {
 func (c *Client) Remove(key string) error {
 	return errors.New("function not supported")
 }
-func (c *Client) WatchPrefix(prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+
+// Lock creates key holding id with a ttl unless it exists, or refreshes
+// its ttl when it holds id already.
+func (c *Client) Lock(key, id string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := c.client.Set(ctx, key, id, &client.SetOptions{PrevExist: client.PrevNoExist, TTL: ttl})
+	if err == nil {
+		return true, nil
+	}
+	if e, ok := err.(client.Error); !ok || e.Code != client.ErrorCodeNodeExist {
+		return false, err
+	}
+	_, err = c.client.Set(ctx, key, id, &client.SetOptions{PrevValue: id, TTL: ttl})
+	if e, ok := err.(client.Error); ok && e.Code == client.ErrorCodeTestFailed {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Unlock deletes key if it holds id.
+func (c *Client) Unlock(key, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := c.client.Delete(ctx, key, &client.DeleteOptions{PrevValue: id})
+	return err
+}
+
+func (c *Client) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
 	// return something > 0 to trigger a key retrieval from the store
 	if waitIndex == 0 {
 		return 1, nil
 	}
 
-	for {
+	// Watch the events after waitIndex, the index of the last change seen,
+	// so that none made in between is missed. Once etcd cleared it from its
+	// history, the 401 below makes the caller read every key again.
+	afterIndex := waitIndex
+	if afterIndex == 1 {
 		// Setting AfterIndex to 0 (default) means that the Watcher
 		// should start watching for events starting at the current
 		// index, whatever that may be.
-		watcher := c.client.Watcher(prefix, &client.WatcherOptions{AfterIndex: uint64(0), Recursive: true})
-		ctx, cancel := context.WithCancel(context.Background())
+		afterIndex = 0
+	}
+	for {
+		watcher := c.client.Watcher(prefix, &client.WatcherOptions{AfterIndex: afterIndex, Recursive: true})
+		ctx, cancel := context.WithCancel(ctx)
 		cancelRoutine := make(chan bool)
 		defer close(cancelRoutine)
 
@@ -166,5 +201,6 @@ func (c *Client) WatchPrefix(prefix string, keys []string, waitIndex uint64, sto
 				return resp.Node.ModifiedIndex, err
 			}
 		}
+		afterIndex = resp.Node.ModifiedIndex
 	}
 }
@@ -0,0 +1,106 @@
+package backends
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrLockUnsupported is returned by Lock for backends that cannot hold
+// locks.
+var ErrLockUnsupported = errors.New("backend does not support locks")
+
+// The Locker interface is implemented by store clients that can hold a lock
+// on a key which expires unless renewed, used for leader election.
+type Locker interface {
+	// Lock acquires key for id, or renews it if id holds it already, for
+	// ttl. It returns false while another id holds it.
+	Lock(key, id string, ttl time.Duration) (bool, error)
+	// Unlock releases key if id holds it.
+	Unlock(key, id string) error
+}
+
+// Lock acquires or renews key for id with client.
+func Lock(client StoreClient, key, id string, ttl time.Duration) (bool, error) {
+	if l, ok := client.(Locker); ok {
+		return l.Lock(key, id, ttl)
+	}
+	return false, ErrLockUnsupported
+}
+
+// Unlock releases key if id holds it.
+func Unlock(client StoreClient, key, id string) error {
+	if l, ok := client.(Locker); ok {
+		return l.Unlock(key, id)
+	}
+	return ErrLockUnsupported
+}
+
+func (c *instrumentedClient) Lock(key, id string, ttl time.Duration) (bool, error) {
+	return Lock(c.StoreClient, key, id, ttl)
+}
+
+func (c *instrumentedClient) Unlock(key, id string) error {
+	return Unlock(c.StoreClient, key, id)
+}
+
+func (c *cachingClient) Lock(key, id string, ttl time.Duration) (bool, error) {
+	return Lock(c.StoreClient, key, id, ttl)
+}
+
+func (c *cachingClient) Unlock(key, id string) error {
+	return Unlock(c.StoreClient, key, id)
+}
+
+func (c *reconnectingClient) Lock(key, id string, ttl time.Duration) (bool, error) {
+	var ok bool
+	err := c.do(func(client StoreClient) (err error) {
+		ok, err = Lock(client, key, id, ttl)
+		return err
+	})
+	return ok, err
+}
+
+func (c *reconnectingClient) Unlock(key, id string) error {
+	return c.do(func(client StoreClient) error {
+		return Unlock(client, key, id)
+	})
+}
+
+// Lock locks key with the backend Set would write it to.
+func (c *compositeClient) Lock(key, id string, ttl time.Duration) (bool, error) {
+	client, err := c.writeClient(key)
+	if err != nil {
+		return false, err
+	}
+	return Lock(client, key, id, ttl)
+}
+
+func (c *compositeClient) Unlock(key, id string) error {
+	client, err := c.writeClient(key)
+	if err != nil {
+		return err
+	}
+	return Unlock(client, key, id)
+}
+
+func (c *encryptingClient) Lock(key, id string, ttl time.Duration) (bool, error) {
+	return Lock(c.StoreClient, key, id, ttl)
+}
+
+func (c *encryptingClient) Unlock(key, id string) error {
+	return Unlock(c.StoreClient, key, id)
+}
+
+func (c *seedClient) Lock(key, id string, ttl time.Duration) (bool, error) {
+	if client, live := c.backend(); live {
+		return Lock(client, key, id, ttl)
+	}
+	return false, errSeeding
+}
+
+func (c *seedClient) Unlock(key, id string) error {
+	if client, live := c.backend(); live {
+		return Unlock(client, key, id)
+	}
+	return errSeeding
+}
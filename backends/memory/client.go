@@ -0,0 +1,127 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client keeps keys in memory, for tests and template fixtures. Watches
+// return when any key is set or removed.
+type Client struct {
+	mu      sync.Mutex
+	values  map[string]string
+	index   uint64
+	changed chan struct{}
+	locks   map[string]memoryLock
+}
+
+// memoryLock is a lock held by id until expires.
+type memoryLock struct {
+	id      string
+	expires time.Time
+}
+
+// NewMemoryClient returns a client holding a copy of values.
+func NewMemoryClient(values map[string]string) *Client {
+	c := &Client{values: make(map[string]string, len(values)), index: 1, changed: make(chan struct{}), locks: make(map[string]memoryLock)}
+	for k, v := range values {
+		c.values[k] = v
+	}
+	return c
+}
+
+// GetValues returns the keys starting with one of keys.
+func (c *Client) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	vars := make(map[string]string)
+	for _, key := range keys {
+		for k, v := range c.values {
+			if strings.HasPrefix(k, key) {
+				vars[k] = v
+			}
+		}
+	}
+	return vars, nil
+}
+
+func (c *Client) Set(key string, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = value
+	c.notify()
+	return nil
+}
+
+func (c *Client) Remove(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.values, key)
+	c.notify()
+	return nil
+}
+
+// Update sets the keys of set and removes those of remove at once, waking
+// up the watches a single time.
+func (c *Client) Update(set map[string]string, remove []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range remove {
+		delete(c.values, k)
+	}
+	for k, v := range set {
+		c.values[k] = v
+	}
+	c.notify()
+}
+
+// notify wakes up the watches. c.mu must be held.
+func (c *Client) notify() {
+	c.index++
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// WatchPrefix returns once a key changed after waitIndex, or when stopChan
+// is closed. The first watch returns at once.
+func (c *Client) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+	for {
+		c.mu.Lock()
+		index, changed := c.index, c.changed
+		c.mu.Unlock()
+		if index > waitIndex {
+			return index, nil
+		}
+		select {
+		case <-stopChan:
+			return waitIndex, nil
+		case <-ctx.Done():
+			return waitIndex, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Lock acquires key for id until ttl elapsed, unless another id holds it.
+func (c *Client) Lock(key, id string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if l, ok := c.locks[key]; ok && l.id != id && now.Before(l.expires) {
+		return false, nil
+	}
+	c.locks[key] = memoryLock{id: id, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// Unlock releases key if id holds it.
+func (c *Client) Unlock(key, id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if l, ok := c.locks[key]; ok && l.id == id {
+		delete(c.locks, key)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWatchPrefix(t *testing.T) {
+	c := NewMemoryClient(map[string]string{"/app/port": "80"})
+	stop := make(chan bool)
+	index, err := c.WatchPrefix(context.Background(), "/app", nil, 0, stop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan uint64)
+	go func() {
+		i, _ := c.WatchPrefix(context.Background(), "/app", nil, index, stop)
+		done <- i
+	}()
+	c.Set("/app/port", "8080")
+	select {
+	case i := <-done:
+		if i <= index {
+			t.Errorf("index %d, want more than %d", i, index)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watch did not return after Set")
+	}
+	vars, _ := c.GetValues(context.Background(), []string{"/app"})
+	if vars["/app/port"] != "8080" {
+		t.Errorf("GetValues = %v", vars)
+	}
+}
+
+func TestWatchPrefixCanceled(t *testing.T) {
+	c := NewMemoryClient(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	index, err := c.WatchPrefix(ctx, "/app", nil, 1, make(chan bool))
+	if err != context.DeadlineExceeded || index != 1 {
+		t.Errorf("WatchPrefix = %d, %v, want 1, %v", index, err, context.DeadlineExceeded)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	c := NewMemoryClient(map[string]string{"/app/port": "80", "/app/old": "x"})
+	index, _ := c.WatchPrefix(context.Background(), "/app", nil, 0, nil)
+	c.Update(map[string]string{"/app/port": "8080", "/app/host": "h"}, []string{"/app/old"})
+	if i, _ := c.WatchPrefix(context.Background(), "/app", nil, 0, nil); i != index+1 {
+		t.Errorf("index %d, want %d", i, index+1)
+	}
+	vars, _ := c.GetValues(context.Background(), []string{"/app"})
+	if len(vars) != 2 || vars["/app/port"] != "8080" || vars["/app/host"] != "h" {
+		t.Errorf("GetValues = %v", vars)
+	}
+}
@@ -0,0 +1,80 @@
+package backends
+
+import (
+	"context"
+	"time"
+
+	"github.com/kelseyhightower/confd/metrics"
+)
+
+// instrumentedClient records request metrics for the wrapped StoreClient
+// and guards it with a circuit breaker.
+type instrumentedClient struct {
+	StoreClient
+	backend string
+	breaker *circuitBreaker
+	policy  RetryPolicy
+}
+
+func (c *instrumentedClient) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	vars, err := c.StoreClient.GetValues(ctx, keys)
+	metrics.ObserveBackendRequest(c.backend, "get_values", start, err)
+	c.record(ctx, err)
+	return vars, err
+}
+
+func (c *instrumentedClient) Set(key string, value string) error {
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := c.StoreClient.Set(key, value)
+	metrics.ObserveBackendRequest(c.backend, "set", start, err)
+	c.breaker.record(err)
+	return err
+}
+
+func (c *instrumentedClient) Remove(key string) error {
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := c.StoreClient.Remove(key)
+	metrics.ObserveBackendRequest(c.backend, "remove", start, err)
+	c.breaker.record(err)
+	return err
+}
+
+func (c *instrumentedClient) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+	if err := c.breaker.allow(); err != nil {
+		return waitIndex, err
+	}
+	index, err := c.StoreClient.WatchPrefix(ctx, prefix, keys, waitIndex, stopChan)
+	c.record(ctx, err)
+	return index, err
+}
+
+// record records err with the breaker, unless the request was canceled,
+// which says nothing about the health of the backend.
+func (c *instrumentedClient) record(ctx context.Context, err error) {
+	if err != nil && ctx.Err() == context.Canceled {
+		return
+	}
+	c.breaker.record(err)
+}
+
+func (c *instrumentedClient) RetryPolicy() RetryPolicy {
+	return c.policy
+}
+
+func (c *instrumentedClient) Ping() error {
+	return Ping(c.StoreClient)
+}
+
+func (c *instrumentedClient) Close() error {
+	return Close(c.StoreClient)
+}
@@ -0,0 +1,108 @@
+/*
+Package plugin lets confd use backends implemented as external binaries.
+
+A backend plugin is a standalone program that implements the StoreClient
+interface and hands it to Serve. confd launches the binary declared in the
+[plugins] table of the config file and talks to it over RPC:
+
+	backend = "mystore"
+
+	[plugins]
+	mystore = "/usr/local/lib/confd/confd-backend-mystore"
+*/
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/rpc"
+	"os/exec"
+
+	goplugin "github.com/hashicorp/go-plugin"
+)
+
+// StoreClient is the interface a plugin binary must implement. It mirrors
+// backends.StoreClient.
+type StoreClient interface {
+	GetValues(ctx context.Context, keys []string) (map[string]string, error)
+	Set(key string, value string) error
+	Remove(key string) error
+	WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error)
+}
+
+// pluginName is the name the store client is dispensed under.
+const pluginName = "store"
+
+// Handshake is used to verify that a launched binary really is a confd
+// backend plugin speaking a compatible protocol version.
+var Handshake = goplugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "CONFD_BACKEND_PLUGIN",
+	MagicCookieValue: "7d4e8a2c5f1b4b0e9c3a6d2f8e1b5c7a",
+}
+
+// storePlugin implements goplugin.Plugin for the StoreClient interface.
+type storePlugin struct {
+	impl StoreClient
+}
+
+func (p *storePlugin) Server(*goplugin.MuxBroker) (interface{}, error) {
+	return newRPCServer(p.impl), nil
+}
+
+func (p *storePlugin) Client(b *goplugin.MuxBroker, c *rpc.Client) (interface{}, error) {
+	return &Client{rpc: c}, nil
+}
+
+// Serve runs impl as a confd backend plugin. It must be called from the
+// main function of the plugin binary and blocks until confd disconnects.
+func Serve(impl StoreClient) {
+	goplugin.Serve(&goplugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		Plugins: map[string]goplugin.Plugin{
+			pluginName: &storePlugin{impl: impl},
+		},
+	})
+}
+
+// New launches the plugin binary at path and returns a client connected to it.
+func New(path string) (*Client, error) {
+	if path == "" {
+		return nil, errors.New("empty plugin path")
+	}
+	client := goplugin.NewClient(&goplugin.ClientConfig{
+		HandshakeConfig: Handshake,
+		Plugins: map[string]goplugin.Plugin{
+			pluginName: &storePlugin{},
+		},
+		Cmd:     exec.Command(path),
+		Managed: true,
+	})
+	rpcClient, err := client.Client()
+	if err != nil {
+		client.Kill()
+		return nil, err
+	}
+	raw, err := rpcClient.Dispense(pluginName)
+	if err != nil {
+		client.Kill()
+		return nil, err
+	}
+	c := raw.(*Client)
+	c.kill = client.Kill
+	return c, nil
+}
+
+// Close kills the plugin process.
+func (c *Client) Close() error {
+	if c.kill != nil {
+		c.kill()
+	}
+	return nil
+}
+
+// Cleanup kills every plugin process launched by New. It should be called
+// before confd exits.
+func Cleanup() {
+	goplugin.CleanupClients()
+}
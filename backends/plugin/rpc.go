@@ -0,0 +1,155 @@
+package plugin
+
+import (
+	"context"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+)
+
+// GetValuesArgs and the other types below are the RPC payloads exchanged
+// between confd and a plugin.
+type GetValuesArgs struct {
+	Keys []string
+}
+
+type SetArgs struct {
+	Key   string
+	Value string
+}
+
+type WatchPrefixArgs struct {
+	ID        uint64
+	Prefix    string
+	Keys      []string
+	WaitIndex uint64
+}
+
+type StopWatchArgs struct {
+	ID uint64
+}
+
+// Client is a StoreClient backed by a plugin process.
+type Client struct {
+	rpc    *rpc.Client
+	nextID uint64
+	// kill ends the plugin process, nil when the client was not launched
+	// by New.
+	kill func()
+}
+
+// GetValues queries the plugin for keys. The reply to a call abandoned when
+// ctx is done is discarded.
+func (c *Client) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
+	var resp map[string]string
+	call := c.rpc.Go("Plugin.GetValues", &GetValuesArgs{Keys: keys}, &resp, nil)
+	select {
+	case <-call.Done:
+		return resp, call.Error
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Client) Set(key string, value string) error {
+	return c.rpc.Call("Plugin.Set", &SetArgs{Key: key, Value: value}, new(struct{}))
+}
+
+func (c *Client) Remove(key string) error {
+	return c.rpc.Call("Plugin.Remove", &SetArgs{Key: key}, new(struct{}))
+}
+
+// WatchPrefix forwards the watch to the plugin. Channels cannot cross the
+// process boundary, so closing stopChan, or ctx being done, is translated
+// into a StopWatch call for the watch identified by a client-side ID.
+func (c *Client) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+	id := atomic.AddUint64(&c.nextID, 1)
+	call := c.rpc.Go("Plugin.WatchPrefix", &WatchPrefixArgs{
+		ID:        id,
+		Prefix:    prefix,
+		Keys:      keys,
+		WaitIndex: waitIndex,
+	}, new(uint64), nil)
+	select {
+	case <-call.Done:
+		return *call.Reply.(*uint64), call.Error
+	case <-stopChan:
+		c.rpc.Call("Plugin.StopWatch", &StopWatchArgs{ID: id}, new(struct{}))
+		<-call.Done
+		return waitIndex, nil
+	case <-ctx.Done():
+		c.rpc.Call("Plugin.StopWatch", &StopWatchArgs{ID: id}, new(struct{}))
+		<-call.Done
+		return waitIndex, ctx.Err()
+	}
+}
+
+// rpcServer runs inside the plugin process and dispatches calls to the
+// plugin's StoreClient implementation.
+type rpcServer struct {
+	impl StoreClient
+
+	mu      sync.Mutex
+	watches map[uint64]chan bool
+	// stopped holds the IDs of the watches stopped before they started, as
+	// net/rpc may run StopWatch ahead of the WatchPrefix call it stops.
+	stopped map[uint64]bool
+}
+
+func newRPCServer(impl StoreClient) *rpcServer {
+	return &rpcServer{
+		impl:    impl,
+		watches: make(map[uint64]chan bool),
+		stopped: make(map[uint64]bool),
+	}
+}
+
+func (s *rpcServer) GetValues(args *GetValuesArgs, resp *map[string]string) error {
+	vars, err := s.impl.GetValues(context.Background(), args.Keys)
+	*resp = vars
+	return err
+}
+
+func (s *rpcServer) Set(args *SetArgs, _ *struct{}) error {
+	return s.impl.Set(args.Key, args.Value)
+}
+
+func (s *rpcServer) Remove(args *SetArgs, _ *struct{}) error {
+	return s.impl.Remove(args.Key)
+}
+
+func (s *rpcServer) WatchPrefix(args *WatchPrefixArgs, resp *uint64) error {
+	stopChan := make(chan bool)
+	s.mu.Lock()
+	if s.stopped[args.ID] {
+		delete(s.stopped, args.ID)
+		s.mu.Unlock()
+		*resp = args.WaitIndex
+		return nil
+	}
+	s.watches[args.ID] = stopChan
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watches, args.ID)
+		s.mu.Unlock()
+	}()
+
+	index, err := s.impl.WatchPrefix(context.Background(), args.Prefix, args.Keys, args.WaitIndex, stopChan)
+	*resp = index
+	return err
+}
+
+func (s *rpcServer) StopWatch(args *StopWatchArgs, _ *struct{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stopChan, ok := s.watches[args.ID]; ok {
+		close(stopChan)
+		delete(s.watches, args.ID)
+	} else {
+		// A stop racing with the end of its watch is remembered as well,
+		// at the cost of an entry.
+		s.stopped[args.ID] = true
+	}
+	return nil
+}
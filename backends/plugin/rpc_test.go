@@ -0,0 +1,137 @@
+package plugin
+
+import (
+	"context"
+	"net"
+	"net/rpc"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testStore is a StoreClient whose watches return once changed is closed.
+type testStore struct {
+	values  map[string]string
+	changed chan bool
+}
+
+func (s *testStore) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
+	return s.values, nil
+}
+
+func (s *testStore) Set(key string, value string) error {
+	s.values[key] = value
+	return nil
+}
+
+func (s *testStore) Remove(key string) error {
+	delete(s.values, key)
+	return nil
+}
+
+func (s *testStore) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+	select {
+	case <-s.changed:
+		return waitIndex + 1, nil
+	case <-stopChan:
+		return waitIndex, nil
+	}
+}
+
+// newTestClient returns a Client talking over an in-process connection to
+// the server of store, as dispensed by go-plugin.
+func newTestClient(t *testing.T, store StoreClient) (*Client, *rpcServer) {
+	server := rpc.NewServer()
+	s := newRPCServer(store)
+	if err := server.RegisterName("Plugin", s); err != nil {
+		t.Fatal(err)
+	}
+	clientConn, serverConn := net.Pipe()
+	go server.ServeConn(serverConn)
+	return &Client{rpc: rpc.NewClient(clientConn)}, s
+}
+
+// watch runs WatchPrefix and fails the test unless it returns within a
+// second.
+func watch(t *testing.T, c *Client, ctx context.Context, stopChan chan bool) (uint64, error) {
+	type result struct {
+		index uint64
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		index, err := c.WatchPrefix(ctx, "/app", []string{"/app"}, 5, stopChan)
+		done <- result{index, err}
+	}()
+	select {
+	case r := <-done:
+		return r.index, r.err
+	case <-time.After(time.Second):
+		t.Fatal("WatchPrefix did not return")
+		return 0, nil
+	}
+}
+
+func TestClientRoundTrip(t *testing.T) {
+	store := &testStore{values: map[string]string{"/app/name": "confd"}, changed: make(chan bool)}
+	c, _ := newTestClient(t, store)
+	defer c.rpc.Close()
+
+	if err := c.Set("/app/port", "80"); err != nil {
+		t.Fatal(err)
+	}
+	values, err := c.GetValues(context.Background(), []string{"/app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"/app/name": "confd", "/app/port": "80"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("GetValues() = %v, want %v", values, want)
+	}
+	if err := c.Remove("/app/port"); err != nil {
+		t.Fatal(err)
+	}
+
+	close(store.changed)
+	if index, err := watch(t, c, context.Background(), make(chan bool)); err != nil || index != 6 {
+		t.Errorf("WatchPrefix() after a change = %d, %v, want 6", index, err)
+	}
+}
+
+func TestClientStopWatch(t *testing.T) {
+	store := &testStore{values: map[string]string{}, changed: make(chan bool)}
+	c, s := newTestClient(t, store)
+	defer c.rpc.Close()
+
+	stopChan := make(chan bool)
+	close(stopChan)
+	if index, err := watch(t, c, context.Background(), stopChan); err != nil || index != 5 {
+		t.Errorf("WatchPrefix() stopped = %d, %v, want 5", index, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := watch(t, c, ctx, make(chan bool)); err != context.Canceled {
+		t.Errorf("WatchPrefix() with a done context = %v, want %v", err, context.Canceled)
+	}
+
+	// A stop the server runs ahead of its watch.
+	if err := s.StopWatch(&StopWatchArgs{ID: 100}, nil); err != nil {
+		t.Fatal(err)
+	}
+	var index uint64
+	done := make(chan error, 1)
+	go func() {
+		done <- s.WatchPrefix(&WatchPrefixArgs{ID: 100, Prefix: "/app", WaitIndex: 5}, &index)
+	}()
+	select {
+	case err := <-done:
+		if err != nil || index != 5 {
+			t.Errorf("WatchPrefix() stopped early = %d, %v, want 5", index, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WatchPrefix stopped before it started did not return")
+	}
+	if len(s.watches) != 0 || len(s.stopped) != 0 {
+		t.Errorf("Expected no watches left, got %v and %v", s.watches, s.stopped)
+	}
+}
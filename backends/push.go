@@ -0,0 +1,62 @@
+package backends
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/kelseyhightower/confd/backends/memory"
+)
+
+// ErrNoPushBackend is returned by Push when no push backend is configured.
+var ErrNoPushBackend = errors.New("no push backend configured")
+
+var (
+	// pushStore holds the keys pushed to the admin API. It is shared by the
+	// push backends and kept across reloads.
+	pushStore = memory.NewMemoryClient(nil)
+
+	pushMu      sync.Mutex
+	pushEnabled bool
+)
+
+// newPushClient returns the client of a push backend.
+func newPushClient() StoreClient {
+	pushMu.Lock()
+	pushEnabled = true
+	pushMu.Unlock()
+	return pushStore
+}
+
+// UsesPush reports whether config, or one of its backends, is a push
+// backend.
+func (config Config) UsesPush() bool {
+	if config.Backend == "push" {
+		return true
+	}
+	for _, c := range config.Backends {
+		if c.UsesPush() {
+			return true
+		}
+	}
+	return false
+}
+
+// Push sets the keys of set and removes those of remove in the push
+// backends at once, so that a single pass renders the whole update.
+func Push(set map[string]string, remove []string) error {
+	pushMu.Lock()
+	enabled := pushEnabled
+	pushMu.Unlock()
+	if !enabled {
+		return ErrNoPushBackend
+	}
+	pushStore.Update(set, remove)
+	return nil
+}
+
+// WatchPush returns once keys were pushed after waitIndex, or when stopChan
+// is closed. The first watch returns at once.
+func WatchPush(ctx context.Context, waitIndex uint64, stopChan chan bool) (uint64, error) {
+	return pushStore.WatchPrefix(ctx, "/", nil, waitIndex, stopChan)
+}
@@ -1,6 +1,7 @@
 package rancher
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -40,11 +41,11 @@ func NewRancherClient(backendNodes []string) (*Client, error) {
 
 }
 
-func (c *Client) GetValues(keys []string) (map[string]string, error) {
+func (c *Client) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
 	vars := map[string]string{}
 
 	for _, key := range keys {
-		body, err := c.makeMetaDataRequest(key)
+		body, err := c.makeMetaDataRequest(ctx, key)
 		if err != nil {
 			return vars, err
 		}
@@ -92,8 +93,9 @@ func treeWalk(root string, val interface{}, vars map[string]string) error {
 	return nil
 }
 
-func (c *Client) makeMetaDataRequest(path string) ([]byte, error) {
+func (c *Client) makeMetaDataRequest(ctx context.Context, path string) ([]byte, error) {
 	req, _ := http.NewRequest("GET", strings.Join([]string{c.url, path}, ""), nil)
+	req = req.WithContext(ctx)
 	req.Header.Set("Accept", "application/json")
 
 	resp, err := c.httpClient.Do(req)
@@ -110,7 +112,7 @@ func (c *Client) testConnection() error {
 	maxTime := 20 * time.Second
 
 	for i := 1 * time.Second; i < maxTime; i *= time.Duration(2) {
-		if _, err = c.makeMetaDataRequest("/"); err != nil {
+		if _, err = c.makeMetaDataRequest(context.Background(), "/"); err != nil {
 			time.Sleep(i)
 		} else {
 			return nil
@@ -125,7 +127,7 @@ func (c *Client) Set(key string, value string) error {
 func (c *Client) Remove(key string) error {
 	return errors.New("function not supported")
 }
-func (c *Client) WatchPrefix(prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+func (c *Client) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
 	// Watches are not implemented in Rancher Metadata Service
 	<-stopChan
 	return 0, nil
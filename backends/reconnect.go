@@ -0,0 +1,120 @@
+package backends
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/kelseyhightower/confd/log"
+)
+
+// minRecheckInterval bounds how often a failed request makes a
+// reconnectingClient update its configuration.
+const minRecheckInterval = time.Second
+
+// reconnectingClient recreates its client when the configuration returned
+// by update differs from the current one, such as after the password in
+// password_file was rotated or the SRV record lists other nodes. update is
+// called every interval, and at once after a failed request.
+type reconnectingClient struct {
+	mu       sync.Mutex
+	client   StoreClient
+	config   Config
+	interval time.Duration
+	checked  time.Time
+	// what names the source of the configuration in logs.
+	what      string
+	update    func(Config) (Config, error)
+	newClient func(Config) (StoreClient, error)
+}
+
+// current returns the client, recreated first if the configuration
+// changed. The configuration is updated every interval, or with force at
+// most every minRecheckInterval.
+func (c *reconnectingClient) current(force bool) StoreClient {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	since := time.Since(c.checked)
+	if since < minRecheckInterval || (!force && since < c.interval) {
+		return c.client
+	}
+	c.checked = time.Now()
+	config, err := c.update(c.config)
+	if err != nil {
+		log.Warning("Cannot update the %s of %s: %s", c.what, c.config.Backend, err.Error())
+		return c.client
+	}
+	if reflect.DeepEqual(config, c.config) {
+		return c.client
+	}
+	log.Info("The %s of %s changed, reconnecting", c.what, c.config.Backend)
+	client, err := c.newClient(config)
+	if err != nil {
+		log.Error("Cannot reconnect, keeping the previous client: %s", err.Error())
+		return c.client
+	}
+	previous := c.client
+	c.client, c.config = client, config
+	if err := Close(previous); err != nil {
+		log.Warning("Cannot close the previous client of %s: %s", c.config.Backend, err.Error())
+	}
+	return client
+}
+
+// do runs f with the current client, and once more with a new client when
+// f fails and the configuration changed meanwhile.
+func (c *reconnectingClient) do(f func(StoreClient) error) error {
+	client := c.current(false)
+	err := f(client)
+	if err == nil {
+		return nil
+	}
+	if updated := c.current(true); updated != client {
+		return f(updated)
+	}
+	return err
+}
+
+func (c *reconnectingClient) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
+	var vars map[string]string
+	err := c.do(func(client StoreClient) (err error) {
+		vars, err = client.GetValues(ctx, keys)
+		return err
+	})
+	return vars, err
+}
+
+func (c *reconnectingClient) Set(key string, value string) error {
+	return c.do(func(client StoreClient) error {
+		return client.Set(key, value)
+	})
+}
+
+func (c *reconnectingClient) Remove(key string) error {
+	return c.do(func(client StoreClient) error {
+		return client.Remove(key)
+	})
+}
+
+func (c *reconnectingClient) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+	index, err := c.current(false).WatchPrefix(ctx, prefix, keys, waitIndex, stopChan)
+	if err != nil {
+		c.current(true)
+	}
+	return index, err
+}
+
+func (c *reconnectingClient) RetryPolicy() RetryPolicy {
+	return RetryPolicyOf(c.current(false))
+}
+
+func (c *reconnectingClient) Ping() error {
+	return c.do(Ping)
+}
+
+func (c *reconnectingClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Close(c.client)
+}
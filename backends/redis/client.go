@@ -1,22 +1,37 @@
 package redis
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/garyburd/redigo/redis"
 	"github.com/kelseyhightower/confd/log"
 )
 
-// Client is a wrapper around the redis client
+// Client is a wrapper around the redis client. It is safe for concurrent
+// use, such as by the leader election and the reload semaphores while
+// templates are rendered.
 type Client struct {
+	// mu serializes the commands sent over the connections, which redigo
+	// does not allow concurrently, and guards the fields below.
+	mu       sync.Mutex
 	client   redis.Conn
 	machines []string
 	password string
+	// replica is the connection to one of replicas, serving reads unless
+	// they failed before replicaRetry.
+	replica      redis.Conn
+	replicas     []string
+	replicaRetry time.Time
+	// separator separates the elements of native redis keys, such as ":"
+	// in app:prod:db:host.
+	separator string
 }
 
 // Iterate through `machines`, trying to connect to each in turn.
@@ -35,7 +50,7 @@ func tryConnect(machines []string, password string) (redis.Conn, error) {
 		if len(arr) == 2 {
 			db, err := strconv.Atoi(arr[1])
 			if err != nil {
-				log.Fatal("invalid address: %s, error: %s", address, err.Error())
+				return nil, fmt.Errorf("invalid address: %s, error: %s", address, err.Error())
 			}
 			database = db
 		}
@@ -91,22 +106,26 @@ func (c *Client) connectedClient() (redis.Conn, error) {
 }
 
 // NewRedisClient returns an *redis.Client with a connection to named machines.
+// Reads are sent to one of replicas, when set, and writes to machines.
+// The keys of confd are translated to redis keys separated by separator,
+// unless it is empty or "/".
 // It returns an error if a connection to the cluster cannot be made.
-func NewRedisClient(machines []string, password string) (*Client, error) {
+func NewRedisClient(machines, replicas []string, password, separator string) (*Client, error) {
 	var err error
-	clientWrapper := &Client{machines: machines, password: password, client: nil}
+	if separator == "" {
+		separator = "/"
+	}
+	clientWrapper := &Client{machines: machines, replicas: replicas, password: password, separator: separator, client: nil}
 	clientWrapper.client, err = tryConnect(machines, password)
 	return clientWrapper, err
 }
 
 func (c *Client) Remove(key string) error {
-
-	// Ensure we have a connected redis client
-	rClient, err := c.connectedClient()
-	if err != nil && err != redis.ErrNil {
+	var result int
+	err := c.write(func(rClient redis.Conn) (err error) {
+		result, err = redis.Int(rClient.Do("DEL", c.nativeKey(key)))
 		return err
-	}
-	result, err := redis.Int(rClient.Do("DEL", key))
+	})
 	if err == nil {
 		if result > 0 {
 			return nil
@@ -119,58 +138,111 @@ func (c *Client) Remove(key string) error {
 }
 
 func (c *Client) Set(key string, value string) error {
-
-	// Ensure we have a connected redis client
-	rClient, err := c.connectedClient()
-	if err != nil && err != redis.ErrNil {
+	return c.write(func(rClient redis.Conn) error {
+		_, err := rClient.Do("SET", c.nativeKey(key), value)
 		return err
-	}
-	_, err = rClient.Do("SET", key, value)
-	return err
+	})
 }
 
 // GetValues queries redis for keys prefixed by prefix.
-func (c *Client) GetValues(keys []string) (map[string]string, error) {
-	// Ensure we have a connected redis client
-	rClient, err := c.connectedClient()
+func (c *Client) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
+	vars := make(map[string]string)
+	err := c.StreamValues(ctx, keys, func(key, value string) error {
+		vars[key] = value
+		return nil
+	})
+	return vars, err
+}
+
+// StreamValues calls fn with each key and value GetValues would return, as
+// SCAN finds them, so large key spaces are not held in memory at once.
+// A failing replica is given up for the primary. The mutex is only held for
+// each command, not while fn runs.
+func (c *Client) StreamValues(ctx context.Context, keys []string, fn func(key, value string) error) error {
+	c.mu.Lock()
+	rClient, replica, err := c.readClient()
+	c.mu.Unlock()
 	if err != nil && err != redis.ErrNil {
-		return nil, err
+		return err
+	}
+	// Tell the errors of fn from those of the replica.
+	var fnErr error
+	call := func(key, value string) error {
+		fnErr = fn(key, value)
+		return fnErr
 	}
+	err = c.streamValues(ctx, rClient, keys, call)
+	if err == nil || err == fnErr || err == ctx.Err() || !replica {
+		return err
+	}
+	c.mu.Lock()
+	c.replicaFailed(err)
+	rClient, err = c.connectedClient()
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return c.streamValues(ctx, rClient, keys, call)
+}
 
-	vars := make(map[string]string)
+// do sends a command over rClient, holding the mutex for it only, so that
+// the commands of other callers go between those of a long SCAN.
+func (c *Client) do(rClient redis.Conn, command string, args ...interface{}) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return rClient.Do(command, args...)
+}
+
+// streamValues is StreamValues with rClient. It gives up between two
+// commands once ctx is done.
+func (c *Client) streamValues(ctx context.Context, rClient redis.Conn, keys []string, fn func(key, value string) error) error {
 	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		key = strings.Replace(key, "/*", "", -1)
-		value, err := redis.String(rClient.Do("GET", key))
+		value, err := redis.String(c.do(rClient, "GET", c.nativeKey(key)))
 		if err == nil {
-			vars[key] = value
+			if err := fn(key, value); err != nil {
+				return err
+			}
 			continue
 		}
 
 		if err != redis.ErrNil {
-			return vars, err
+			return err
 		}
 
-		if key == "/" {
-			key = "/*"
-		} else {
-			key = fmt.Sprintf("%s/*", key)
+		match := c.nativeKey(key)
+		switch {
+		case c.separator == "/" && key == "/":
+			match = "/*"
+		case match == "":
+			match = "*"
+		default:
+			match += c.separator + "*"
 		}
 
 		idx := 0
 		for {
-			values, err := redis.Values(rClient.Do("SCAN", idx, "MATCH", key, "COUNT", "1000"))
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			values, err := redis.Values(c.do(rClient, "SCAN", idx, "MATCH", match, "COUNT", "1000"))
 			if err != nil && err != redis.ErrNil {
-				return vars, err
+				return err
 			}
 			idx, _ = redis.Int(values[0], nil)
 			items, _ := redis.Strings(values[1], nil)
 			for _, item := range items {
 				var newKey string
 				if newKey, err = redis.String(item, nil); err != nil {
-					return vars, err
+					return err
 				}
-				if value, err = redis.String(rClient.Do("GET", newKey)); err == nil {
-					vars[newKey] = value
+				if value, err = redis.String(c.do(rClient, "GET", newKey)); err == nil {
+					if err := fn(c.confdKey(newKey), value); err != nil {
+						return err
+					}
 				}
 			}
 			if idx == 0 {
@@ -178,11 +250,95 @@ func (c *Client) GetValues(keys []string) (map[string]string, error) {
 			}
 		}
 	}
-	return vars, nil
+	return nil
+}
+
+// nativeKey translates the confd key, such as /app/prod/db/host, to the
+// redis key, such as app:prod:db:host with the ":" separator. The root key
+// translates to "".
+func (c *Client) nativeKey(key string) string {
+	if c.separator == "/" {
+		return key
+	}
+	return strings.Replace(strings.Trim(key, "/"), "/", c.separator, -1)
+}
+
+// confdKey translates the redis key back to a confd key.
+func (c *Client) confdKey(key string) string {
+	if c.separator == "/" {
+		return key
+	}
+	return "/" + strings.Replace(key, c.separator, "/", -1)
+}
+
+// Ping checks that redis is reachable.
+func (c *Client) Ping() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rClient, err := c.connectedClient()
+	if err != nil {
+		return err
+	}
+	_, err = rClient.Do("PING")
+	return err
+}
+
+// Close closes the connections to redis.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	if c.replica != nil {
+		err = c.replica.Close()
+		c.replica = nil
+	}
+	if c.client != nil {
+		if cerr := c.client.Close(); cerr != nil {
+			err = cerr
+		}
+		c.client = nil
+	}
+	return err
 }
 
 // WatchPrefix is not yet implemented.
-func (c *Client) WatchPrefix(prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+func (c *Client) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
 	<-stopChan
 	return 0, nil
 }
+
+// renewScript extends the expiry of a lock held by the given id.
+var renewScript = redis.NewScript(1, `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`)
+
+// unlockScript deletes a lock held by the given id.
+var unlockScript = redis.NewScript(1, `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`)
+
+// Lock sets key to id unless it exists, expiring after ttl, or extends its
+// expiry when it holds id already.
+func (c *Client) Lock(key, id string, ttl time.Duration) (bool, error) {
+	ms := int64(ttl / time.Millisecond)
+	key = c.nativeKey(key)
+	locked := false
+	err := c.write(func(rClient redis.Conn) error {
+		_, err := redis.String(rClient.Do("SET", key, id, "NX", "PX", ms))
+		if err == nil {
+			locked = true
+			return nil
+		}
+		if err != redis.ErrNil {
+			return err
+		}
+		renewed, err := redis.Int(renewScript.Do(rClient, key, id, ms))
+		locked = renewed == 1
+		return err
+	})
+	return locked, err
+}
+
+// Unlock deletes key if it holds id.
+func (c *Client) Unlock(key, id string) error {
+	return c.write(func(rClient redis.Conn) error {
+		_, err := unlockScript.Do(rClient, c.nativeKey(key), id)
+		return err
+	})
+}
@@ -0,0 +1,87 @@
+package redis
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestStreamValuesCallback connects to the redis server at
+// CONFD_TEST_REDIS, and sends commands from the callback of StreamValues.
+func TestStreamValuesCallback(t *testing.T) {
+	addr := os.Getenv("CONFD_TEST_REDIS")
+	if addr == "" {
+		t.Skip("CONFD_TEST_REDIS is not set")
+	}
+	c, err := NewRedisClient([]string{addr}, nil, os.Getenv("CONFD_TEST_REDIS_PASSWORD"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{"/stream/a", "/stream/b"}
+	for _, key := range keys {
+		if err := c.Set(key, "value"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.StreamValues(context.Background(), []string{"/stream"}, func(key, value string) error {
+			return c.Set(key, value+"-copy")
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		// Leave the client as is, its mutex may be held for good.
+		t.Fatal("StreamValues did not return while its callback sent commands")
+	}
+	defer c.Close()
+	for _, key := range keys {
+		defer c.Remove(key)
+	}
+	values, err := c.GetValues(context.Background(), []string{"/stream"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range values {
+		if value != "value-copy" {
+			t.Errorf("%s = %q, want %q", key, value, "value-copy")
+		}
+	}
+}
+
+func TestKeyTranslation(t *testing.T) {
+	tests := []struct {
+		separator string
+		key       string
+		native    string
+		confd     string
+	}{
+		{"/", "/app/prod/db/host", "/app/prod/db/host", "/app/prod/db/host"},
+		{"/", "/", "/", "/"},
+		{"/", "/app/", "/app/", "/app/"},
+		{":", "/app/prod/db/host", "app:prod:db:host", "/app/prod/db/host"},
+		{":", "/", "", "/"},
+		{":", "", "", "/"},
+		{":", "/app/", "app", "/app"},
+		{":", "app/prod", "app:prod", "/app/prod"},
+		// A separator within an element splits it on the way back.
+		{":", "/app/a:b", "app:a:b", "/app/a/b"},
+		{".", "/app/v1.2", "app.v1.2", "/app/v1/2"},
+	}
+	for _, tt := range tests {
+		c := &Client{separator: tt.separator}
+		native := c.nativeKey(tt.key)
+		if native != tt.native {
+			t.Errorf("nativeKey(%q) with %q = %q, want %q", tt.key, tt.separator, native, tt.native)
+		}
+		if got := c.confdKey(native); got != tt.confd {
+			t.Errorf("confdKey(%q) with %q = %q, want %q", native, tt.separator, got, tt.confd)
+		}
+	}
+}
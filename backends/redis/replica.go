@@ -0,0 +1,98 @@
+package redis
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/kelseyhightower/confd/log"
+)
+
+// replicaRetryInterval is how long reads go to the primary after the
+// replicas failed.
+const replicaRetryInterval = 30 * time.Second
+
+// readClient returns the connection reads are sent to, and whether it is a
+// replica: one of the replicas unless none is set or they failed within
+// replicaRetryInterval, the primary otherwise.
+func (c *Client) readClient() (redis.Conn, bool, error) {
+	if len(c.replicas) > 0 && c.replica == nil && !time.Now().Before(c.replicaRetry) {
+		conn, err := tryConnect(c.replicas, c.password)
+		if err != nil {
+			c.replicaFailed(err)
+		} else {
+			c.replica = conn
+		}
+	}
+	if c.replica != nil {
+		return c.replica, true, nil
+	}
+	conn, err := c.connectedClient()
+	return conn, false, err
+}
+
+// replicaFailed drops the replica connection, sending reads to the primary
+// for replicaRetryInterval.
+func (c *Client) replicaFailed(err error) {
+	log.Warning("Redis replicas failed, reading from the primary for %s: %s", replicaRetryInterval, err.Error())
+	if c.replica != nil {
+		c.replica.Close()
+		c.replica = nil
+	}
+	c.replicaRetry = time.Now().Add(replicaRetryInterval)
+}
+
+// write runs fn with the primary. When it turns out to have been demoted
+// to a replica, fn runs again with the new primary.
+func (c *Client) write(fn func(rClient redis.Conn) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Ensure we have a connected redis client
+	rClient, err := c.connectedClient()
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+	err = fn(rClient)
+	if !isReadOnly(err) {
+		return err
+	}
+	log.Warning("Redis primary was demoted, searching the new one: %s", err.Error())
+	primary, perr := c.findPrimary()
+	if perr != nil {
+		log.Error("Cannot find the redis primary: %s", perr.Error())
+		return err
+	}
+	c.client.Close()
+	c.client = primary
+	return fn(primary)
+}
+
+// isReadOnly reports whether err is the error of a replica refusing a
+// write.
+func isReadOnly(err error) bool {
+	e, ok := err.(redis.Error)
+	return ok && strings.HasPrefix(string(e), "READONLY")
+}
+
+// findPrimary connects to the first of the machines and replicas whose ROLE
+// is master.
+func (c *Client) findPrimary() (redis.Conn, error) {
+	lastErr := errors.New("no node has the master role")
+	for _, address := range append(append([]string{}, c.machines...), c.replicas...) {
+		conn, err := tryConnect([]string{address}, c.password)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		role, err := redis.Values(conn.Do("ROLE"))
+		if err == nil && len(role) > 0 {
+			if r, _ := redis.String(role[0], nil); r == "master" {
+				log.Info("Found the redis primary at %s", address)
+				return conn, nil
+			}
+		}
+		conn.Close()
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,99 @@
+package backends
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy configures how requests to a failing backend are retried:
+// the delay starts at Base and doubles up to Max, Jitter randomly shortens
+// each delay by up to that fraction, and after MaxRetries consecutive
+// failures the retries are exhausted. A MaxRetries of 0 retries forever.
+type RetryPolicy struct {
+	Base       time.Duration
+	Max        time.Duration
+	Jitter     float64
+	MaxRetries int
+}
+
+// DefaultRetryPolicy is used by backends without retry settings.
+var DefaultRetryPolicy = RetryPolicy{Base: time.Second, Max: time.Minute, Jitter: 0.2}
+
+// RetriesExhaustedError is returned once a backend failed more often in a
+// row than its retry policy allows.
+type RetriesExhaustedError struct {
+	Retries int
+	Err     error
+}
+
+func (e *RetriesExhaustedError) Error() string {
+	return fmt.Sprintf("backend still failing after %d retries: %s", e.Retries, e.Err.Error())
+}
+
+// Backoff computes the delays between the retries of a RetryPolicy.
+type Backoff struct {
+	policy  RetryPolicy
+	attempt int
+}
+
+// NewBackoff returns a Backoff following p.
+func (p RetryPolicy) NewBackoff() *Backoff {
+	return &Backoff{policy: p}
+}
+
+// Next returns how long to wait before retrying after err, or a
+// RetriesExhaustedError if no retry is left.
+func (b *Backoff) Next(err error) (time.Duration, error) {
+	b.attempt++
+	if b.policy.MaxRetries > 0 && b.attempt > b.policy.MaxRetries {
+		return 0, &RetriesExhaustedError{Retries: b.policy.MaxRetries, Err: err}
+	}
+	d := b.policy.Base
+	for i := 1; i < b.attempt && d < b.policy.Max; i++ {
+		d *= 2
+	}
+	if d > b.policy.Max {
+		d = b.policy.Max
+	}
+	if b.policy.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * b.policy.Jitter * float64(d))
+	}
+	return d, nil
+}
+
+// Reset starts over after a successful request.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
+
+// RetryPolicy returns the retry policy set in config.
+func (config Config) RetryPolicy() RetryPolicy {
+	p := DefaultRetryPolicy
+	if config.RetryBase > 0 {
+		p.Base = time.Duration(config.RetryBase) * time.Second
+	}
+	if config.RetryMax > 0 {
+		p.Max = time.Duration(config.RetryMax) * time.Second
+	}
+	if p.Max < p.Base {
+		p.Max = p.Base
+	}
+	if config.RetryJitter > 0 {
+		p.Jitter = config.RetryJitter
+	}
+	p.MaxRetries = config.MaxRetries
+	return p
+}
+
+type retrier interface {
+	RetryPolicy() RetryPolicy
+}
+
+// RetryPolicyOf returns the retry policy of client.
+func RetryPolicyOf(client StoreClient) RetryPolicy {
+	if r, ok := client.(retrier); ok {
+		return r.RetryPolicy()
+	}
+	return DefaultRetryPolicy
+}
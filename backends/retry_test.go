@@ -0,0 +1,29 @@
+package backends
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b := RetryPolicy{Base: time.Second, Max: 5 * time.Second, MaxRetries: 5}.NewBackoff()
+	errFailed := errors.New("failed")
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		d, err := b.Next(errFailed)
+		if err != nil {
+			t.Fatalf("retry %d: unexpected error %v", i, err)
+		}
+		if d != w {
+			t.Errorf("retry %d: delay %s, want %s", i, d, w)
+		}
+	}
+	if _, err := b.Next(errFailed); err == nil {
+		t.Error("expected retries to be exhausted")
+	}
+	b.Reset()
+	if d, _ := b.Next(errFailed); d != time.Second {
+		t.Errorf("after reset: delay %s, want %s", d, time.Second)
+	}
+}
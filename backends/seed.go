@@ -0,0 +1,297 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kelseyhightower/confd/backends/memory"
+	"github.com/kelseyhightower/confd/log"
+	"gopkg.in/yaml.v2"
+)
+
+// errSeeding is returned by the writes of a seedClient, and its reads
+// without a seed file, until the backend is reachable.
+var errSeeding = errors.New("backend not reachable yet")
+
+// seedIndex is the index returned by the watches answered from the seed
+// file, which no backend returns.
+const seedIndex = ^uint64(0)
+
+// seedClient serves the values of a seed file, if any, until the backend is
+// reachable, and then forwards every request to it.
+type seedClient struct {
+	config Config
+	file   string
+	seed   *memory.Client
+	// switched is closed once client answered a read.
+	switched chan struct{}
+
+	mu     sync.Mutex
+	client StoreClient
+	keys   map[string]bool
+	live   bool
+	closed bool
+}
+
+// newSeedClient returns the client of config serving the values of its
+// SeedFile, keyed by backend key, until the backend answers.
+func newSeedClient(config Config) (StoreClient, error) {
+	values, err := readSeedFile(config.SeedFile)
+	if err != nil {
+		return nil, err
+	}
+	file := config.SeedFile
+	config.SeedFile = ""
+	c := &seedClient{
+		config:   config,
+		file:     file,
+		seed:     memory.NewMemoryClient(values),
+		switched: make(chan struct{}),
+		keys:     make(map[string]bool),
+	}
+	if c.client, err = New(config); err != nil {
+		log.Warning("Cannot create backend client, rendering from seed file %s: %s", file, err.Error())
+	}
+	go c.connect()
+	return c, nil
+}
+
+// NewPending returns the client of config like New. When the backend cannot
+// be reached yet, it returns a client failing requests with errSeeding
+// until it can instead of an error, so that confd can start meanwhile.
+func NewPending(config Config) StoreClient {
+	client, err := New(config)
+	if err == nil {
+		return client
+	}
+	log.Warning("Cannot create backend client, retrying in the background: %s", err.Error())
+	c := &seedClient{
+		config:   config,
+		switched: make(chan struct{}),
+		keys:     make(map[string]bool),
+	}
+	go c.connect()
+	return c
+}
+
+// readSeedFile reads the flat map of keys to values of file, YAML when its
+// name ends in .yaml or .yml and JSON otherwise.
+func readSeedFile(file string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	if strings.HasSuffix(file, ".yaml") || strings.HasSuffix(file, ".yml") {
+		err = yaml.Unmarshal(data, &values)
+	} else {
+		err = json.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse seed file %s: %s", file, err.Error())
+	}
+	return values, nil
+}
+
+// Seeded returns a channel closed once a client serving a seed file, or
+// returned by NewPending, switched to its backend, or nil for other clients.
+func Seeded(client StoreClient) <-chan struct{} {
+	if c, ok := client.(*seedClient); ok {
+		return c.switched
+	}
+	return nil
+}
+
+// connect creates the backend client if needed, and reads the keys asked
+// for so far until it answers, backing off between attempts.
+func (c *seedClient) connect() {
+	backoff := c.config.RetryPolicy().NewBackoff()
+	for {
+		err := c.probe()
+		if err == nil {
+			return
+		}
+		d, _ := backoff.Next(err)
+		if d <= 0 {
+			d = time.Second
+		}
+		log.Debug("Backend not reachable yet, retrying in %s: %s", d, err.Error())
+		time.Sleep(d)
+	}
+}
+
+// probe returns nil once the client is live.
+func (c *seedClient) probe() error {
+	c.mu.Lock()
+	client, live := c.client, c.live
+	keys := make([]string, 0, len(c.keys))
+	for k := range c.keys {
+		keys = append(keys, k)
+	}
+	closed := c.closed
+	c.mu.Unlock()
+	if live || closed {
+		return nil
+	}
+	if client == nil {
+		var err error
+		if client, err = New(c.config); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return Close(client)
+		}
+		c.client = client
+		c.mu.Unlock()
+	}
+	if len(keys) == 0 {
+		// Nothing was read from the seed file yet: the first pass asks the
+		// backend itself.
+		return errors.New("no key read yet")
+	}
+	if _, err := client.GetValues(context.Background(), keys); err != nil {
+		return err
+	}
+	c.switchOver()
+	return nil
+}
+
+// switchOver makes the backend client serve every request from now on.
+func (c *seedClient) switchOver() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.live {
+		return
+	}
+	c.live = true
+	c.keys = nil
+	if c.seed != nil {
+		log.Info("Backend reachable, no longer serving the seed file %s", c.file)
+	} else {
+		log.Info("Backend reachable")
+	}
+	close(c.switched)
+}
+
+// backend returns the backend client and whether it is live.
+func (c *seedClient) backend() (StoreClient, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.client, c.live
+}
+
+// GetValues reads keys from the backend, and from the seed file while the
+// backend fails and never answered.
+func (c *seedClient) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
+	client, live := c.backend()
+	if live {
+		return client.GetValues(ctx, keys)
+	}
+	err := errSeeding
+	if client != nil {
+		var values map[string]string
+		if values, err = client.GetValues(ctx, keys); err == nil {
+			c.switchOver()
+			return values, nil
+		}
+		if ctx.Err() != nil {
+			return values, err
+		}
+	}
+	c.mu.Lock()
+	for _, k := range keys {
+		c.keys[k] = true
+	}
+	c.mu.Unlock()
+	if c.seed == nil {
+		return nil, err
+	}
+	if client != nil {
+		log.Warning("Backend not reachable, reading %s from the seed file: %s", strings.Join(keys, ", "), err.Error())
+	}
+	return c.seed.GetValues(ctx, keys)
+}
+
+func (c *seedClient) StreamValues(ctx context.Context, keys []string, fn func(key, value string) error) error {
+	if client, live := c.backend(); live {
+		return StreamValues(ctx, client, keys, fn)
+	}
+	vars, err := c.GetValues(ctx, keys)
+	if err != nil {
+		return err
+	}
+	for k, v := range vars {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *seedClient) Set(key string, value string) error {
+	if client, live := c.backend(); live {
+		return client.Set(key, value)
+	}
+	return errSeeding
+}
+
+func (c *seedClient) Remove(key string) error {
+	if client, live := c.backend(); live {
+		return client.Remove(key)
+	}
+	return errSeeding
+}
+
+// WatchPrefix returns at once for the first watch, so that the resource is
+// rendered from the seed file or a snapshot, and then waits until the
+// backend is reachable, watching it from its current index.
+func (c *seedClient) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+	if client, live := c.backend(); live {
+		if waitIndex == seedIndex {
+			waitIndex = 0
+		}
+		return client.WatchPrefix(ctx, prefix, keys, waitIndex, stopChan)
+	}
+	if waitIndex != seedIndex {
+		return seedIndex, nil
+	}
+	select {
+	case <-c.switched:
+	case <-stopChan:
+		return waitIndex, nil
+	case <-ctx.Done():
+		return waitIndex, ctx.Err()
+	}
+	client, _ := c.backend()
+	return client.WatchPrefix(ctx, prefix, keys, 0, stopChan)
+}
+
+func (c *seedClient) RetryPolicy() RetryPolicy {
+	return c.config.RetryPolicy()
+}
+
+func (c *seedClient) Ping() error {
+	if client, live := c.backend(); live {
+		return Ping(client)
+	}
+	return errSeeding
+}
+
+// Close closes the backend client and stops connecting to it.
+func (c *seedClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.client == nil {
+		return nil
+	}
+	return Close(c.client)
+}
@@ -0,0 +1,58 @@
+package backends
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kelseyhightower/confd/backends/memory"
+)
+
+func TestSeedClient(t *testing.T) {
+	f, err := ioutil.TempFile("", "confd-seed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"/app/port": "80", "/other": "x"}`)
+	f.Close()
+	values, err := readSeedFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend := &countingClient{values: map[string]string{"/app/port": "8080"}, err: errors.New("unreachable")}
+	c := &seedClient{
+		seed:     memory.NewMemoryClient(values),
+		switched: make(chan struct{}),
+		keys:     make(map[string]bool),
+		client:   backend,
+	}
+	vars, err := c.GetValues(context.Background(), []string{"/app"})
+	if err != nil || len(vars) != 1 || vars["/app/port"] != "80" {
+		t.Fatalf("GetValues = %v, %v, want the seed value", vars, err)
+	}
+	if err := c.Set("/app/port", "81"); err != errSeeding {
+		t.Errorf("Set = %v, want errSeeding", err)
+	}
+	if index, err := c.WatchPrefix(context.Background(), "/app", nil, 0, nil); index != seedIndex || err != nil {
+		t.Errorf("first WatchPrefix = %d, %v", index, err)
+	}
+
+	// The backend answers: its values are served from now on.
+	backend.err = nil
+	if err := c.probe(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-Seeded(c):
+	default:
+		t.Fatal("not switched to the backend")
+	}
+	vars, err = c.GetValues(context.Background(), []string{"/app"})
+	if err != nil || vars["/app/port"] != "8080" {
+		t.Errorf("GetValues = %v, %v, want the backend value", vars, err)
+	}
+}
@@ -0,0 +1,86 @@
+package backends
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kelseyhightower/confd/log"
+)
+
+// LookupSRV returns the nodes listed by the SRV record, as scheme://host:port
+// URLs.
+func LookupSRV(record, scheme string) ([]string, error) {
+	nodes := make([]string, 0)
+
+	// Ignore the CNAME as we don't need it.
+	_, addrs, err := net.LookupSRV("", "", record)
+	if err != nil {
+		return nodes, err
+	}
+	for _, srv := range addrs {
+		host := strings.TrimRight(srv.Target, ".")
+		port := strconv.FormatUint(uint64(srv.Port), 10)
+		nodes = append(nodes, fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, port)))
+	}
+	return nodes, nil
+}
+
+// sameNodes reports whether a and b hold the same nodes, in any order.
+func sameNodes(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// newClientFromSRV creates the client of config with the nodes of its SRV
+// record instead of BackendNodes, when it has one. With SRVRefresh set, the
+// record is resolved again every SRVRefresh seconds and the client
+// recreated whenever the nodes changed.
+func newClientFromSRV(config Config) (StoreClient, error) {
+	if config.SRVRecord == "" {
+		return newClientWithCredentials(config)
+	}
+	log.Info("SRV record set to " + config.SRVRecord)
+	nodes, err := LookupSRV(config.SRVRecord, config.Scheme)
+	if err != nil {
+		return nil, errors.New("Cannot get nodes from SRV records " + err.Error())
+	}
+	config.BackendNodes = nodes
+	client, err := newClientWithCredentials(config)
+	if err != nil || config.SRVRefresh <= 0 {
+		return client, err
+	}
+	return &reconnectingClient{
+		client:   client,
+		config:   config,
+		interval: time.Duration(config.SRVRefresh) * time.Second,
+		checked:  time.Now(),
+		what:     "nodes in SRV record " + config.SRVRecord,
+		update: func(config Config) (Config, error) {
+			nodes, err := LookupSRV(config.SRVRecord, config.Scheme)
+			if err != nil {
+				return config, err
+			}
+			if len(nodes) > 0 && !sameNodes(nodes, config.BackendNodes) {
+				config.BackendNodes = nodes
+			}
+			return config, nil
+		},
+		newClient: newClientWithCredentials,
+	}, nil
+}
@@ -1,6 +1,7 @@
 package stackengine
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
@@ -75,7 +76,7 @@ type KVPair struct {
 }
 
 // GetValues queries StackEngine for keys prefixed by prefix.
-func (c *Client) GetValues(keys []string) (map[string]string, error) {
+func (c *Client) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
 	vars := make(map[string]string)
 	var pairs []KVPair
 
@@ -84,6 +85,7 @@ func (c *Client) GetValues(keys []string) (map[string]string, error) {
 
 		uri := c.base + "/v1/kv/" + key + "?recurse"
 		req, err := http.NewRequest("GET", uri, nil)
+		req = req.WithContext(ctx)
 
 		bearer := "Bearer " + c.token
 
@@ -131,7 +133,7 @@ func (c *Client) Set(key string, value string) error {
 func (c *Client) Remove(key string) error {
 	return errors.New("function not supported")
 }
-func (c *Client) WatchPrefix(prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+func (c *Client) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
 	<-stopChan
 	return 0, nil
 }
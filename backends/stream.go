@@ -0,0 +1,54 @@
+package backends
+
+import (
+	"context"
+	"time"
+
+	"github.com/kelseyhightower/confd/metrics"
+)
+
+// The Streamer interface is implemented by store clients that can hand out
+// the values GetValues would return one at a time as they are read, so that
+// large key spaces are not built into a map first.
+type Streamer interface {
+	// StreamValues calls fn with each key and its value, stopping at the
+	// first error fn returns. fn may see a key again when the read is
+	// retried.
+	StreamValues(ctx context.Context, keys []string, fn func(key, value string) error) error
+}
+
+// StreamValues streams the values of keys to fn with client, reading them
+// all with GetValues first when it is no Streamer. Caching and composite
+// clients need the whole result and always do so.
+func StreamValues(ctx context.Context, client StoreClient, keys []string, fn func(key, value string) error) error {
+	if s, ok := client.(Streamer); ok {
+		return s.StreamValues(ctx, keys, fn)
+	}
+	vars, err := client.GetValues(ctx, keys)
+	if err != nil {
+		return err
+	}
+	for k, v := range vars {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *instrumentedClient) StreamValues(ctx context.Context, keys []string, fn func(key, value string) error) error {
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := StreamValues(ctx, c.StoreClient, keys, fn)
+	metrics.ObserveBackendRequest(c.backend, "get_values", start, err)
+	c.record(ctx, err)
+	return err
+}
+
+func (c *reconnectingClient) StreamValues(ctx context.Context, keys []string, fn func(key, value string) error) error {
+	return c.do(func(client StoreClient) error {
+		return StreamValues(ctx, client, keys, fn)
+	})
+}
@@ -0,0 +1,48 @@
+package backends
+
+import (
+	"context"
+	"testing"
+)
+
+type streamingClient struct {
+	countingClient
+	streamed int
+}
+
+func (c *streamingClient) StreamValues(ctx context.Context, keys []string, fn func(key, value string) error) error {
+	for k, v := range c.values {
+		c.streamed++
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestStreamValues(t *testing.T) {
+	values := map[string]string{"/app/port": "80", "/app/host": "db"}
+	collect := func(client StoreClient) map[string]string {
+		vars := make(map[string]string)
+		err := StreamValues(context.Background(), client, []string{"/app"}, func(k, v string) error {
+			vars[k] = v
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return vars
+	}
+
+	// Clients without StreamValues are read with GetValues.
+	plain := &countingClient{values: values}
+	if vars := collect(plain); len(vars) != 2 || plain.calls != 1 {
+		t.Errorf("streamed %v with %d GetValues calls", vars, plain.calls)
+	}
+
+	streaming := &streamingClient{countingClient: countingClient{values: values}}
+	client := &instrumentedClient{StoreClient: streaming, backend: "test", breaker: newCircuitBreaker(Config{})}
+	if vars := collect(client); len(vars) != 2 || streaming.streamed != 2 || streaming.calls != 0 {
+		t.Errorf("streamed %v, %d values and %d GetValues calls", vars, streaming.streamed, streaming.calls)
+	}
+}
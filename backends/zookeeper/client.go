@@ -1,6 +1,7 @@
 package zookeeper
 
 import (
+	"context"
 	"errors"
 	"path/filepath"
 	"strings"
@@ -57,9 +58,12 @@ func nodeWalk(prefix string, c *Client, vars map[string]string) error {
 	return nil
 }
 
-func (c *Client) GetValues(keys []string) (map[string]string, error) {
+func (c *Client) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
 	vars := make(map[string]string)
 	for _, v := range keys {
+		if err := ctx.Err(); err != nil {
+			return vars, err
+		}
 		v = strings.Replace(v, "/*", "", -1)
 		_, _, err := c.client.Exists(v)
 		if err != nil {
@@ -115,14 +119,14 @@ func (c *Client) Set(key string, value string) error {
 func (c *Client) Remove(key string) error {
 	return errors.New("function not supported")
 }
-func (c *Client) WatchPrefix(prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+func (c *Client) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
 	// return something > 0 to trigger a key retrieval from the store
 	if waitIndex == 0 {
 		return 1, nil
 	}
 
 	// List the childrens first
-	entries, err := c.GetValues([]string{prefix})
+	entries, err := c.GetValues(ctx, []string{prefix})
 	if err != nil {
 		return 0, err
 	}
@@ -163,6 +167,8 @@ func (c *Client) WatchPrefix(prefix string, keys []string, waitIndex uint64, sto
 		select {
 		case <-stopChan:
 			return waitIndex, nil
+		case <-ctx.Done():
+			return waitIndex, ctx.Err()
 		case r := <-respChan:
 			return r.waitIndex, r.err
 		}
@@ -0,0 +1,157 @@
+/*
+Package child runs and supervises a child process whose environment is
+rendered from backend keys.
+*/
+package child
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kelseyhightower/confd/log"
+)
+
+// Config configures a child process.
+type Config struct {
+	// Command is run through /bin/sh -c, or cmd /C on Windows.
+	Command string
+	// ReloadSignal is sent to the child when its environment changes. When
+	// nil the child is restarted instead, which is the only way for it to
+	// see the new values.
+	ReloadSignal os.Signal
+	// KillTimeout is how long to wait for the child to exit after SIGTERM
+	// before it is killed. Windows children are killed at once.
+	KillTimeout time.Duration
+}
+
+// Child is a running child process.
+type Child struct {
+	config Config
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	done   chan int
+	exitCh chan int
+}
+
+// New returns a Child which is not started yet.
+func New(config Config) *Child {
+	if config.KillTimeout == 0 {
+		config.KillTimeout = 5 * time.Second
+	}
+	return &Child{config: config, exitCh: make(chan int, 1)}
+}
+
+// ExitCh receives the exit code of the child when it exits on its own.
+func (c *Child) ExitCh() <-chan int {
+	return c.exitCh
+}
+
+// Start launches the child with env as its environment.
+func (c *Child) Start(env []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.start(env)
+}
+
+func (c *Child) start(env []string) error {
+	if c.config.Command == "" {
+		return errors.New("empty exec command")
+	}
+	log.Info("Starting child process: " + c.config.Command)
+	cmd := shellCommand(c.config.Command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan int, 1)
+	c.cmd = cmd
+	c.done = done
+	go func() {
+		code := exitCode(cmd.Wait())
+		done <- code
+		c.mu.Lock()
+		current := c.cmd == cmd
+		if current {
+			c.cmd = nil
+		}
+		c.mu.Unlock()
+		// Only report exits that were not caused by Restart or Stop.
+		if current {
+			log.Info(fmt.Sprintf("Child process exited with code %d", code))
+			c.exitCh <- code
+		}
+	}()
+	return nil
+}
+
+// Reload makes the child pick up env, either by sending it the reload signal
+// or by restarting it.
+func (c *Child) Reload(env []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cmd == nil {
+		return errors.New("child process is not running")
+	}
+	if c.config.ReloadSignal != nil {
+		log.Info(fmt.Sprintf("Sending %v to child process", c.config.ReloadSignal))
+		return c.cmd.Process.Signal(c.config.ReloadSignal)
+	}
+	log.Info("Restarting child process")
+	c.stop()
+	return c.start(env)
+}
+
+// Stop terminates the child and returns its exit code.
+func (c *Child) Stop() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stop()
+}
+
+func (c *Child) stop() int {
+	if c.cmd == nil {
+		// The child exited on its own meanwhile.
+		select {
+		case code := <-c.exitCh:
+			return code
+		default:
+			return 0
+		}
+	}
+	cmd, done := c.cmd, c.done
+	c.cmd = nil
+	terminate(cmd.Process)
+	select {
+	case code := <-done:
+		return code
+	case <-time.After(c.config.KillTimeout):
+		log.Warning("Child process did not exit in time, killing it")
+		cmd.Process.Kill()
+		return <-done
+	}
+}
+
+// exitCode converts the result of exec.Cmd.Wait to a process exit code.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			if status.Signaled() {
+				return 128 + int(status.Signal())
+			}
+			return status.ExitStatus()
+		}
+	}
+	return 1
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+// +build !windows
+
+package child
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// shellCommand returns the command running command with /bin/sh.
+func shellCommand(command string) *exec.Cmd {
+	return exec.Command("/bin/sh", "-c", command)
+}
+
+// terminate asks p to exit with SIGTERM.
+func terminate(p *os.Process) {
+	p.Signal(syscall.SIGTERM)
+}
+
+var signals = map[string]os.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// ParseSignal returns the signal named name, e.g. "SIGHUP" or "HUP".
+func ParseSignal(name string) (os.Signal, error) {
+	sig, ok := signals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return nil, errors.New("unknown signal " + name)
+	}
+	return sig, nil
+}
@@ -0,0 +1,22 @@
+package child
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// shellCommand returns the command running command with cmd.exe.
+func shellCommand(command string) *exec.Cmd {
+	return exec.Command("cmd", "/C", command)
+}
+
+// terminate kills p: Windows processes cannot be sent SIGTERM.
+func terminate(p *os.Process) {
+	p.Kill()
+}
+
+// ParseSignal fails: Windows processes cannot be sent signals.
+func ParseSignal(name string) (os.Signal, error) {
+	return nil, errors.New("exec_reload_signal is not supported on Windows")
+}
@@ -0,0 +1,158 @@
+package child
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kelseyhightower/confd/backends"
+	"github.com/kelseyhightower/confd/log"
+)
+
+// Supervisor keeps a Child running with environment variables rendered
+// from backend keys, reloading it whenever the values change.
+type Supervisor struct {
+	child       *Child
+	storeClient backends.StoreClient
+	prefix      string
+	keys        []string
+	interval    int
+	watch       bool
+	env         map[string]string
+}
+
+// NewSupervisor returns a Supervisor for child. keys are relative to prefix.
+// The backend is watched when watch is set and polled every interval
+// seconds otherwise.
+func NewSupervisor(child *Child, storeClient backends.StoreClient, prefix string, keys []string, interval int, watch bool) *Supervisor {
+	fullKeys := make([]string, len(keys))
+	for i, k := range keys {
+		fullKeys[i] = path.Join("/", prefix, k)
+	}
+	return &Supervisor{
+		child:       child,
+		storeClient: storeClient,
+		prefix:      path.Join("/", prefix),
+		keys:        fullKeys,
+		interval:    interval,
+		watch:       watch,
+	}
+}
+
+// Run starts the child and supervises it until it exits or stopChan is
+// closed, which cancels its backend requests and stops the child. It returns
+// the exit code of the child, which confd should exit with.
+func (s *Supervisor) Run(stopChan chan bool, errChan chan error) int {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env, err := s.render(ctx)
+	if err != nil {
+		log.Error("Cannot render child environment: " + err.Error())
+		return 1
+	}
+	s.env = env
+	if err := s.child.Start(environ(env)); err != nil {
+		log.Error("Cannot start child process: " + err.Error())
+		return 1
+	}
+
+	changed := make(chan bool)
+	go s.poll(ctx, changed, stopChan, errChan)
+	for {
+		select {
+		case code := <-s.child.ExitCh():
+			return code
+		case <-stopChan:
+			cancel()
+			return s.child.Stop()
+		case <-changed:
+			env, err := s.render(ctx)
+			if err != nil {
+				errChan <- err
+				continue
+			}
+			if reflect.DeepEqual(env, s.env) {
+				continue
+			}
+			s.env = env
+			if err := s.child.Reload(environ(env)); err != nil {
+				errChan <- err
+			}
+		}
+	}
+}
+
+// poll signals changed whenever the backend reports a change or, without
+// watch support, every interval.
+func (s *Supervisor) poll(ctx context.Context, changed, stopChan chan bool, errChan chan error) {
+	var index uint64 = 1
+	for {
+		if s.watch {
+			var err error
+			index, err = s.storeClient.WatchPrefix(ctx, s.prefix, s.keys, index, stopChan)
+			if err != nil {
+				select {
+				case <-stopChan:
+					return
+				case errChan <- err:
+				}
+				// Prevent backend errors from consuming all resources.
+				select {
+				case <-stopChan:
+					return
+				case <-time.After(time.Second * 2):
+				}
+				continue
+			}
+		} else {
+			select {
+			case <-stopChan:
+			case <-time.After(time.Duration(s.interval) * time.Second):
+			}
+		}
+		select {
+		case <-stopChan:
+			return
+		case changed <- true:
+		}
+	}
+}
+
+// render fetches the keys and converts them to environment variables named
+// after the key relative to the prefix, e.g. /myapp/db/host -> DB_HOST.
+func (s *Supervisor) render(ctx context.Context) (map[string]string, error) {
+	values, err := s.storeClient.GetValues(ctx, s.keys)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string, len(values))
+	for k, v := range values {
+		env[envName(strings.TrimPrefix(k, s.prefix))] = v
+	}
+	return env, nil
+}
+
+var envReplacer = strings.NewReplacer("/", "_", "-", "_", ".", "_")
+
+func envName(key string) string {
+	return strings.ToUpper(envReplacer.Replace(strings.Trim(key, "/")))
+}
+
+// environ merges env into the environment of confd.
+func environ(env map[string]string) []string {
+	vars := os.Environ()
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		vars = append(vars, fmt.Sprintf("%s=%s", name, env[name]))
+	}
+	return vars
+}
@@ -0,0 +1,114 @@
+//go:build !windows
+// +build !windows
+
+package child
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/kelseyhightower/confd/backends/memory"
+)
+
+// loop keeps a child running and exits with 7 on SIGTERM.
+const loop = `trap 'exit 7' TERM; while :; do sleep 0.1; done`
+
+func startSupervisor(config Config, client *memory.Client) (chan bool, chan int) {
+	config.KillTimeout = 2 * time.Second
+	s := NewSupervisor(New(config), client, "/app", []string{"/name"}, 0, true)
+	stopChan := make(chan bool)
+	exitChan := make(chan int, 1)
+	errChan := make(chan error, 10)
+	go func() {
+		exitChan <- s.Run(stopChan, errChan)
+	}()
+	return stopChan, exitChan
+}
+
+// waitForLines waits until file holds want.
+func waitForLines(t *testing.T, file string, want ...string) {
+	deadline := time.Now().Add(5 * time.Second)
+	var got string
+	for time.Now().Before(deadline) {
+		data, _ := ioutil.ReadFile(file)
+		got = string(data)
+		if got == strings.Join(want, "\n")+"\n" {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("%s = %q, want %q", file, got, want)
+}
+
+func tempFile(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "child")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return filepath.Join(dir, "out"), func() { os.RemoveAll(dir) }
+}
+
+func TestSupervisorExitCode(t *testing.T) {
+	_, exitChan := startSupervisor(Config{Command: "exit 3"}, memory.NewMemoryClient(nil))
+	select {
+	case code := <-exitChan:
+		if code != 3 {
+			t.Errorf("Run() = %d, want 3", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("child did not exit")
+	}
+}
+
+func TestSupervisorStopExitCode(t *testing.T) {
+	file, cleanup := tempFile(t)
+	defer cleanup()
+	stopChan, exitChan := startSupervisor(Config{Command: "echo started >> " + file + "; " + loop}, memory.NewMemoryClient(nil))
+	waitForLines(t, file, "started")
+	close(stopChan)
+	select {
+	case code := <-exitChan:
+		if code != 7 {
+			t.Errorf("Run() = %d after stop, want the exit code of the child, 7", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("child did not stop")
+	}
+}
+
+func TestSupervisorRestart(t *testing.T) {
+	file, cleanup := tempFile(t)
+	defer cleanup()
+	client := memory.NewMemoryClient(map[string]string{"/app/name": "one"})
+	stopChan, exitChan := startSupervisor(Config{Command: `echo "$NAME" >> ` + file + "; " + loop}, client)
+	defer func() {
+		close(stopChan)
+		<-exitChan
+	}()
+	waitForLines(t, file, "one")
+	client.Set("/app/name", "two")
+	waitForLines(t, file, "one", "two")
+}
+
+func TestSupervisorReloadSignal(t *testing.T) {
+	file, cleanup := tempFile(t)
+	defer cleanup()
+	client := memory.NewMemoryClient(map[string]string{"/app/name": "one"})
+	config := Config{
+		Command:      `trap 'echo reloaded >> ` + file + `' HUP; echo "$NAME" >> ` + file + "; " + loop,
+		ReloadSignal: syscall.SIGHUP,
+	}
+	stopChan, exitChan := startSupervisor(config, client)
+	defer func() {
+		close(stopChan)
+		<-exitChan
+	}()
+	waitForLines(t, file, "one")
+	client.Set("/app/name", "two")
+	waitForLines(t, file, "one", "reloaded")
+}
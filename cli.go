@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/kelseyhightower/confd/backends"
+	"github.com/kelseyhightower/confd/confd"
+	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/resource/template"
+	"github.com/spf13/cobra"
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "confd",
+	Short: "Manage local application configuration files using templates and data from backends",
+	Long: `confd renders configuration files from templates and values of a backend.
+
+Every command takes the flags of "confd run -h". Running confd with flags and
+no command, such as "confd -onetime", works as before.`,
+	SilenceUsage: true,
+}
+
+var runCmd = &cobra.Command{
+	Use:                "run [flags]",
+	Short:              "Run confd as a daemon",
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := parseFlags(args); err != nil {
+			return err
+		}
+		run()
+		return nil
+	},
+}
+
+var onceCmd = &cobra.Command{
+	Use:                "once [flags]",
+	Short:              "Process every template resource once and exit",
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := parseFlags(args); err != nil {
+			return err
+		}
+		onetime = true
+		run()
+		return nil
+	},
+}
+
+var validateCmd = &cobra.Command{
+	Use:                "validate [flags]",
+	Short:              "Render the template resources against the backend, or -fixtures, without writing",
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := parseFlags(args); err != nil {
+			return err
+		}
+		return validate()
+	},
+}
+
+var gcCmd = &cobra.Command{
+	Use:                "gc [flags]",
+	Short:              "List the stale files of managed directories, removing them with -managed-delete",
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := parseFlags(args); err != nil {
+			return err
+		}
+		return collectGarbage()
+	},
+}
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Read and write backend keys, relative to -prefix",
+}
+
+var keysGetCmd = &cobra.Command{
+	Use:                "get [flags] KEY",
+	Short:              "Print the value of a key",
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, keys, err := keysClient(args, 1)
+		if err != nil {
+			return err
+		}
+		vars, err := client.GetValues(context.Background(), keys)
+		if err != nil {
+			return err
+		}
+		value, ok := vars[keys[0]]
+		if !ok {
+			return errors.New("key not found: " + keys[0])
+		}
+		fmt.Println(value)
+		return nil
+	},
+}
+
+var keysSetCmd = &cobra.Command{
+	Use:                "set [flags] KEY VALUE",
+	Short:              "Set the value of a key",
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, keys, err := keysClient(args, 2)
+		if err != nil {
+			return err
+		}
+		return client.Set(keys[0], flag.Arg(1))
+	},
+}
+
+var keysDelCmd = &cobra.Command{
+	Use:                "del [flags] KEY",
+	Short:              "Remove a key",
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, keys, err := keysClient(args, 1)
+		if err != nil {
+			return err
+		}
+		return client.Remove(keys[0])
+	},
+}
+
+var keysLsCmd = &cobra.Command{
+	Use:                "ls [flags] [PREFIX]",
+	Short:              "Print the keys below a prefix with their values",
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, keys, err := keysClient(args, 0)
+		if err != nil {
+			return err
+		}
+		vars, err := client.GetValues(context.Background(), keys)
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(vars))
+		for k := range vars {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			fmt.Printf("%s=%s\n", k, vars[k])
+		}
+		return nil
+	},
+}
+
+var keysExportCmd = &cobra.Command{
+	Use:                "export [flags] [PREFIX] [FILE]",
+	Short:              "Write the keys below a prefix to a JSON or YAML file, or stdout",
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, keys, err := keysClient(args, 0)
+		if err != nil {
+			return err
+		}
+		return exportKeys(client, keys[0], flag.Arg(1))
+	},
+}
+
+var keysImportCmd = &cobra.Command{
+	Use:                "import [flags] FILE",
+	Short:              "Set the keys of a JSON or YAML file written by export",
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, _, err := keysClient(args, 1)
+		if err != nil {
+			return err
+		}
+		return importKeys(client, flag.Arg(0))
+	},
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Printf("confd %s\n", Version)
+	},
+}
+
+func init() {
+	keysCmd.AddCommand(keysGetCmd, keysSetCmd, keysDelCmd, keysLsCmd, keysExportCmd, keysImportCmd)
+	rootCmd.AddCommand(runCmd, onceCmd, validateCmd, gcCmd, keysCmd, versionCmd)
+	flag.Usage = usage
+}
+
+// usage prints the commands and the flags they take.
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: confd [command] [flags]\n\nCommands:\n")
+	for _, c := range rootCmd.Commands() {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.Name(), c.Short)
+	}
+	fmt.Fprintf(os.Stderr, "\nWithout a command confd runs as a daemon, or once with -onetime.\n\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Without a command, keep the flat flag interface: confd [flags].
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		if err := parseFlags(os.Args[1:]); err != nil {
+			os.Exit(2)
+		}
+		run()
+		return
+	}
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// parseFlags parses the confd flags in args. -h prints them and -version
+// the version, and both exit.
+func parseFlags(args []string) error {
+	err := flag.CommandLine.Parse(args)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err == nil && printVersion {
+		fmt.Printf("confd %s\n", Version)
+		os.Exit(0)
+	}
+	return err
+}
+
+// keysClient parses the flags of a keys command and returns the backend
+// client and its arguments, of which n are required, as keys below
+// -prefix.
+func keysClient(args []string, n int) (backends.StoreClient, []string, error) {
+	if err := parseFlags(args); err != nil {
+		return nil, nil, err
+	}
+	if flag.NArg() < n {
+		return nil, nil, fmt.Errorf("expected %d argument(s), got %d", n, flag.NArg())
+	}
+	log.SetLevel("warn")
+	if err := initConfig(); err != nil {
+		return nil, nil, err
+	}
+	client, err := backends.New(backendsConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	key := path.Join("/", config.Prefix, flag.Arg(0))
+	return client, []string{key}, nil
+}
+
+// validate renders every template resource against the backend without
+// writing and reports the ones failing. With -fixtures the template
+// resources are checked against golden files instead.
+func validate() error {
+	if err := initConfig(); err != nil {
+		return err
+	}
+	if err := loadFuncPlugins(); err != nil {
+		return err
+	}
+	if fixtures != "" {
+		return validateFixtures(fixtures)
+	}
+	runner, err := confd.New(runnerConfig())
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	ts, loadErr := template.GetTemplateResources(runner.TemplateConfig())
+	failed := 0
+	for _, t := range ts {
+		result, err := t.DryRun()
+		if err != nil {
+			fmt.Printf("%s: %s\n", t.Name, err.Error())
+			failed++
+			continue
+		}
+		state := "in sync"
+		if result.Changed {
+			state = "out of sync"
+		}
+		fmt.Printf("%s: ok, %s is %s\n", t.Name, t.Dest, state)
+	}
+	if loadErr != nil {
+		return loadErr
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d template resource(s) failed", failed, len(ts))
+	}
+	return nil
+}
+
+// collectGarbage prints the files of the managed directories that no
+// template resource produces anymore, and removes them with
+// -managed-delete.
+func collectGarbage() error {
+	if err := initConfig(); err != nil {
+		return err
+	}
+	if err := loadFuncPlugins(); err != nil {
+		return err
+	}
+	runner, err := confd.New(runnerConfig())
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	tc := runner.TemplateConfig()
+	stale, err := template.CollectGarbage(tc)
+	state := "stale"
+	if tc.ManagedDelete && !tc.Noop {
+		state = "removed"
+	}
+	for _, name := range stale {
+		fmt.Printf("%s: %s\n", name, state)
+	}
+	return err
+}
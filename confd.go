@@ -1,78 +1,250 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/kelseyhightower/confd/admin"
+	"github.com/kelseyhightower/confd/audit"
 	"github.com/kelseyhightower/confd/backends"
+	"github.com/kelseyhightower/confd/backends/plugin"
+	"github.com/kelseyhightower/confd/child"
+	"github.com/kelseyhightower/confd/confd"
+	"github.com/kelseyhightower/confd/leader"
 	"github.com/kelseyhightower/confd/log"
 	"github.com/kelseyhightower/confd/resource/template"
+	"github.com/kelseyhightower/confd/systemd"
+	"github.com/kelseyhightower/confd/tracing"
 )
 
-func main() {
-	flag.Parse()
-	if printVersion {
-		fmt.Printf("confd %s\n", Version)
-		os.Exit(0)
-	}
+// elector campaigns for the leader lock with -leader-elect.
+var elector *leader.Elector
+
+// run runs confd as configured by the parsed flags, as a daemon or, with
+// -onetime, once.
+func run() {
 	if err := initConfig(); err != nil {
 		log.Fatal(err.Error())
 	}
 
 	log.Info("Starting confd")
 
-	storeClient, err := backends.New(backendsConfig)
-	if err != nil {
+	if config.OTLPEndpoint != "" {
+		log.Info("Exporting traces to " + config.OTLPEndpoint)
+		if err := tracing.Init(config.OTLPEndpoint, config.OTLPInsecure); err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	if err := loadFuncPlugins(); err != nil {
 		log.Fatal(err.Error())
 	}
 
-	templateConfig.StoreClient = storeClient
+	runner, err := confd.New(runnerConfig())
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	setupAudit(runner.StoreClient())
 	if onetime {
-		if err := template.Process(templateConfig); err != nil {
+		err := runner.RunOnce()
+		cleanup()
+		if err != nil {
 			log.Fatal(err.Error())
 		}
 		os.Exit(0)
 	}
 
-	stopChan := make(chan bool)
-	doneChan := make(chan bool)
-	errChan := make(chan error, 10)
-
-	var processor template.Processor
-	switch {
-	case config.Watch:
-		processor = template.WatchProcessor(templateConfig, stopChan, doneChan, errChan)
-	default:
-		processor = template.IntervalProcessor(templateConfig, stopChan, doneChan, errChan, config.Interval)
+	leaderChan := make(chan bool)
+	if config.LeaderElect {
+		elector = newElector(runner.Client())
+		go func() {
+			if err := elector.Run(leaderChan); err != nil {
+				log.Fatal(err.Error())
+			}
+		}()
+	} else if err := runner.Start(); err != nil {
+		log.Fatal(err.Error())
 	}
 
-	go processor.Process()
+	errChan := make(chan error, 10)
+	childStopChan := make(chan bool)
+	childExitChan := make(chan int, 1)
+	if config.Exec != "" {
+		supervisor, err := newSupervisor(runner.Client())
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		go func() {
+			childExitChan <- supervisor.Run(childStopChan, errChan)
+		}()
+	}
 
+	reloadChan := make(chan chan error)
 	log.Info("web port: %d", config.Port)
-	webConfig := admin.Setting{Port: config.Port, Username: config.AdminUsername, Password: config.AdminPassword, SecretKey: "$2@!!"}
-	ws := admin.New(templateConfig, webConfig)
+	webConfig := admin.Setting{
+		Port:           config.Port,
+		Username:       config.AdminUsername,
+		Password:       config.AdminPassword,
+		ViewerUsername: config.AdminViewerUsername,
+		ViewerPassword: config.AdminViewerPassword,
+		SecretKey:      config.AdminSecretKey,
+		TokenExpiry:    time.Duration(config.AdminTokenExpiry) * time.Second,
+		TLSCert:        config.AdminTLSCert,
+		TLSKey:         config.AdminTLSKey,
+		ClientCA:       config.AdminClientCA,
+		PushToken:      config.AdminPushToken,
+		EnableDebug:    config.EnableDebug,
+		Reload: func() error {
+			done := make(chan error, 1)
+			reloadChan <- done
+			return <-done
+		},
+	}
+	ws := admin.New(runner.TemplateConfig(), webConfig)
 	go func() {
 		log.Debug("Start web server, listen: %d", config.Port)
 
 		ws.Start()
 	}()
 
+	// The processor does not run on a standby replica: keep the systemd
+	// watchdog at bay from here meanwhile.
+	standby := config.LeaderElect
+	standbyTicker := time.NewTicker(template.HeartbeatInterval)
+	defer standbyTicker.Stop()
+
 	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 	for {
 		select {
+		case err := <-runner.Errors():
+			if _, ok := err.(*backends.RetriesExhaustedError); ok {
+				log.Fatal(err.Error())
+			}
+			log.Error(err.Error())
 		case err := <-errChan:
+			if _, ok := err.(*backends.RetriesExhaustedError); ok {
+				log.Fatal(err.Error())
+			}
 			log.Error(err.Error())
+		case <-standbyTicker.C:
+			if standby {
+				sdReady()
+				sdWatchdog()
+			}
+		case leading := <-leaderChan:
+			standby = !leading
+			if !leading {
+				runner.Stop()
+				log.Info("Standing by until this replica is the leader again")
+				continue
+			}
+			if err := runner.Start(); err != nil {
+				log.Error(err.Error())
+			}
+		case done := <-reloadChan:
+			err := reload(runner)
+			if err == nil {
+				ws.SetTemplateConfig(runner.TemplateConfig())
+			}
+			done <- err
 		case s := <-signalChan:
+			if s == syscall.SIGHUP {
+				log.Info(fmt.Sprintf("Captured %v. Reloading...", s))
+				if err := reload(runner); err != nil {
+					log.Error("Reload failed, keeping the previous configuration: %s", err.Error())
+				} else {
+					ws.SetTemplateConfig(runner.TemplateConfig())
+				}
+				continue
+			}
 			log.Info(fmt.Sprintf("Captured %v. Exiting...", s))
-			close(doneChan)
-		case <-doneChan:
+			// Let the resources being processed and their commands finish.
+			code := 0
+			if err := runner.Shutdown(time.Duration(config.ShutdownTimeout) * time.Second); err != nil {
+				log.Error(err.Error())
+				code = 1
+			}
+			if config.Exec != "" {
+				close(childStopChan)
+				<-childExitChan
+			}
+			cleanup()
+			os.Exit(code)
+		case code := <-childExitChan:
+			cleanup()
+			os.Exit(code)
+		case <-runner.Done():
+			cleanup()
 			os.Exit(0)
 		}
 	}
 
 }
+
+// loadFuncPlugins registers the template functions of the -func-plugin
+// plugins.
+func loadFuncPlugins() error {
+	for _, path := range config.FuncPlugins {
+		log.Info("Loading template function plugin " + path)
+		if err := template.LoadFuncPlugin(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupAudit selects the store of the audit log, writing with storeClient
+// when it is kept in the backend.
+func setupAudit(storeClient backends.StoreClient) {
+	switch {
+	case config.AuditLogFile != "":
+		audit.SetStore(audit.NewFileStore(config.AuditLogFile))
+	case config.AuditLogPrefix != "":
+		audit.SetStore(audit.NewBackendStore(storeClient, config.AuditLogPrefix))
+	default:
+		audit.SetStore(nil)
+	}
+}
+
+// newElector returns the Elector of the -leader-* settings.
+func newElector(storeClient backends.StoreClient) *leader.Elector {
+	id := config.LeaderID
+	if id == "" {
+		hostname, _ := os.Hostname()
+		id = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	log.Info("Campaigning for leader lock " + config.LeaderKey + " as " + id)
+	return leader.New(storeClient, config.LeaderKey, id, time.Duration(config.LeaderTTL)*time.Second)
+}
+
+// cleanup tells systemd confd is stopping, releases the leader lock, stops
+// the backend plugins and flushes the pending spans before confd exits.
+func cleanup() {
+	sdNotify(systemd.Stopping)
+	if elector != nil {
+		elector.Stop()
+	}
+	plugin.Cleanup()
+	tracing.Shutdown()
+}
+
+// newSupervisor creates the supervisor for the -exec child process.
+func newSupervisor(storeClient backends.StoreClient) (*child.Supervisor, error) {
+	childConfig := child.Config{
+		Command:     config.Exec,
+		KillTimeout: time.Duration(config.ExecKillTimeout) * time.Second,
+	}
+	if config.ExecReloadSignal != "" {
+		sig, err := child.ParseSignal(config.ExecReloadSignal)
+		if err != nil {
+			return nil, err
+		}
+		childConfig.ReloadSignal = sig
+	}
+	return child.NewSupervisor(child.New(childConfig), storeClient, config.Prefix,
+		config.ExecKeys, config.Interval, config.Watch), nil
+}
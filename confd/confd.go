@@ -0,0 +1,353 @@
+// Package confd runs the confd processing pipeline, a backend client, the
+// template resources and a processor, from another Go program.
+//
+//	r, err := confd.New(confd.Config{
+//		Backend:  backends.Config{Backend: "etcd", BackendNodes: []string{"127.0.0.1:2379"}},
+//		Template: template.Config{ConfDir: "/etc/confd"},
+//		Watch:    true,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	go func() {
+//		for err := range r.Errors() {
+//			log.Println(err)
+//		}
+//	}()
+//	if err := r.Start(); err != nil {
+//		return err
+//	}
+//	defer r.Stop()
+package confd
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/kelseyhightower/confd/backends"
+	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/resource/template"
+)
+
+// ErrRunning is returned by Start when the processor is already running.
+var ErrRunning = errors.New("confd is already running")
+
+// ErrShutdownTimeout is returned by Shutdown when the resources being
+// processed were not done in time.
+var ErrShutdownTimeout = errors.New("timed out waiting for the resources being processed")
+
+// Config configures a Runner.
+type Config struct {
+	// Backend configures the backend client.
+	Backend backends.Config
+	// Template configures the template resources. The store clients are
+	// set by New.
+	Template template.Config
+	// Watch selects watch mode instead of interval mode.
+	Watch bool
+	// Interval is the number of seconds between two passes in interval
+	// mode. Defaults to 600.
+	Interval int
+}
+
+// Runner drives the processing pipeline.
+type Runner struct {
+	mu             sync.Mutex
+	config         Config
+	storeClient    backends.StoreClient
+	templateConfig template.Config
+	syncChan       chan *template.SyncRequest
+	errChan        chan error
+	stopChan       chan bool
+	doneChan       chan bool
+	// cancel cancels the backend requests of the running processor.
+	cancel context.CancelFunc
+}
+
+// New creates a Runner for config and its backend client, retrying while
+// the backend is unavailable according to its retry policy. With a state
+// directory, it starts at once instead and renders the snapshots meanwhile.
+func New(config Config) (*Runner, error) {
+	var storeClient backends.StoreClient
+	if config.Template.StateDir != "" {
+		storeClient = backends.NewPending(config.Backend)
+	} else {
+		var err error
+		if storeClient, err = newStoreClient(config.Backend); err != nil {
+			return nil, err
+		}
+	}
+	r := &Runner{
+		syncChan: make(chan *template.SyncRequest),
+		errChan:  make(chan error, 10),
+	}
+	r.setup(config, storeClient)
+	return r, nil
+}
+
+// newStoreClient creates the store client, retrying while the backend is
+// unavailable according to its retry policy.
+func newStoreClient(config backends.Config) (backends.StoreClient, error) {
+	backoff := config.RetryPolicy().NewBackoff()
+	for {
+		storeClient, err := backends.New(config)
+		if err == nil {
+			return storeClient, nil
+		}
+		d, retryErr := backoff.Next(err)
+		if retryErr != nil {
+			return nil, retryErr
+		}
+		log.Warning("Cannot create backend client, retrying in %s: %s", d, err.Error())
+		time.Sleep(d)
+	}
+}
+
+// setup completes the template configuration of config with storeClient
+// and the values shared across reloads.
+func (r *Runner) setup(config Config, storeClient backends.StoreClient) {
+	if config.Interval <= 0 {
+		config.Interval = 600
+	}
+	tc := config.Template
+	tc.StoreClient = storeClient
+	if tc.Backend == "" {
+		tc.Backend = config.Backend.Backend
+	}
+	tc.SyncChan = r.syncChan
+	tc.StoreClients = backends.Children(storeClient)
+	tc.WatchResume = config.Backend.WatchResumes()
+	r.config = config
+	r.storeClient = storeClient
+	r.templateConfig = tc
+}
+
+// StoreClient returns the backend client.
+func (r *Runner) StoreClient() backends.StoreClient {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.storeClient
+}
+
+// Client returns a backend client following the reloads of r: each request
+// goes to the client r holds at the time. Unlike the one StoreClient
+// returns, it can be kept by long-running users such as a leader elector.
+func (r *Runner) Client() backends.StoreClient {
+	return runnerClient{r}
+}
+
+// runnerClient is the StoreClient of Runner.Client.
+type runnerClient struct {
+	r *Runner
+}
+
+func (c runnerClient) GetValues(ctx context.Context, keys []string) (map[string]string, error) {
+	return c.r.StoreClient().GetValues(ctx, keys)
+}
+
+func (c runnerClient) Set(key string, value string) error {
+	return c.r.StoreClient().Set(key, value)
+}
+
+func (c runnerClient) Remove(key string) error {
+	return c.r.StoreClient().Remove(key)
+}
+
+func (c runnerClient) WatchPrefix(ctx context.Context, prefix string, keys []string, waitIndex uint64, stopChan chan bool) (uint64, error) {
+	return c.r.StoreClient().WatchPrefix(ctx, prefix, keys, waitIndex, stopChan)
+}
+
+func (c runnerClient) Lock(key, id string, ttl time.Duration) (bool, error) {
+	return backends.Lock(c.r.StoreClient(), key, id, ttl)
+}
+
+func (c runnerClient) Unlock(key, id string) error {
+	return backends.Unlock(c.r.StoreClient(), key, id)
+}
+
+func (c runnerClient) Ping() error {
+	return backends.Ping(c.r.StoreClient())
+}
+
+func (c runnerClient) RetryPolicy() backends.RetryPolicy {
+	return backends.RetryPolicyOf(c.r.StoreClient())
+}
+
+// TemplateConfig returns the template configuration, completed with the
+// store clients.
+func (r *Runner) TemplateConfig() template.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.templateConfig
+}
+
+// Errors returns the channel receiving the errors of the processor, such as
+// failing reload commands or a backend exhausting its retries. It has to be
+// drained while the processor runs.
+func (r *Runner) Errors() <-chan error {
+	return r.errChan
+}
+
+// RunOnce processes every template resource once.
+func (r *Runner) RunOnce() error {
+	return template.Process(r.TemplateConfig())
+}
+
+// Start runs the processor in the background until Stop is called.
+func (r *Runner) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopChan != nil {
+		return ErrRunning
+	}
+	r.start()
+	return nil
+}
+
+func (r *Runner) start() {
+	r.stopChan = make(chan bool)
+	r.doneChan = make(chan bool)
+	tc := r.templateConfig
+	parent := tc.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	tc.Context, r.cancel = context.WithCancel(parent)
+	var processor template.Processor
+	switch {
+	case r.config.Watch:
+		processor = template.WatchProcessor(tc, r.stopChan, r.doneChan, r.errChan)
+	default:
+		processor = template.IntervalProcessor(tc, r.stopChan, r.doneChan, r.errChan, r.config.Interval)
+	}
+	go processor.Process()
+	if switched := backends.Seeded(r.storeClient); switched != nil && !r.config.Watch {
+		select {
+		case <-switched:
+		default:
+			go r.resync(switched, r.stopChan)
+		}
+	}
+	if r.config.Backend.UsesPush() && !r.config.Watch {
+		go r.syncOnPush(tc.Context, r.stopChan)
+	}
+}
+
+// resync asks the processor for a pass over every resource once the backend
+// of a client serving a seed file is reachable, so that resources rendered
+// from the seed file do not wait for the next interval. Watches return by
+// themselves.
+func (r *Runner) resync(switched <-chan struct{}, stopChan chan bool) {
+	select {
+	case <-switched:
+	case <-stopChan:
+		return
+	}
+	select {
+	case r.syncChan <- template.NewSyncRequest(""):
+	case <-stopChan:
+	}
+}
+
+// syncOnPush asks the processor for a pass over every resource whenever
+// keys are pushed to the admin API, as the watches of push backends do.
+func (r *Runner) syncOnPush(ctx context.Context, stopChan chan bool) {
+	var index uint64
+	for {
+		next, err := backends.WatchPush(ctx, index, stopChan)
+		if err != nil {
+			return
+		}
+		if index > 0 {
+			select {
+			case <-stopChan:
+				return
+			case r.syncChan <- template.NewSyncRequest(""):
+			}
+		}
+		index = next
+	}
+}
+
+// Stop asks the processor to stop and waits until the resources being
+// processed are done.
+func (r *Runner) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stop(0)
+}
+
+// Shutdown is Stop waiting at most timeout, or until they are done if
+// timeout is 0. When it returns ErrShutdownTimeout the backend requests of
+// the resources still being processed are cancelled, and they are left to
+// finish in the background.
+func (r *Runner) Shutdown(timeout time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stop(timeout)
+}
+
+func (r *Runner) stop(timeout time.Duration) error {
+	if r.stopChan == nil {
+		return nil
+	}
+	close(r.stopChan)
+	// The passes in progress finish with their backend requests, which are
+	// only cancelled once they are no longer waited for.
+	defer r.cancel()
+	doneChan := r.doneChan
+	r.stopChan = nil
+	r.doneChan = nil
+	if timeout <= 0 {
+		<-doneChan
+		return nil
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-doneChan:
+		return nil
+	case <-timer.C:
+		return ErrShutdownTimeout
+	}
+}
+
+// Done returns a channel closed once the processor stopped on its own. It
+// is nil while the processor is not running.
+func (r *Runner) Done() <-chan bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.doneChan
+}
+
+// Reload replaces the configuration with config and restarts a running
+// processor with it. The backend client is only recreated when the backend
+// configuration changed, and the previous configuration is kept when it
+// cannot be.
+func (r *Runner) Reload(config Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous, storeClient := r.storeClient, r.storeClient
+	if !reflect.DeepEqual(config.Backend, r.config.Backend) {
+		client, err := backends.New(config.Backend)
+		if err != nil {
+			return err
+		}
+		storeClient = client
+	}
+	running := r.stopChan != nil
+	r.stop(0)
+	r.setup(config, storeClient)
+	if running {
+		r.start()
+	}
+	if storeClient != previous {
+		if err := backends.Close(previous); err != nil {
+			log.Warning("Cannot close the previous backend client: %s", err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package confd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kelseyhightower/confd/backends"
+	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/resource/template"
+)
+
+// newTestRunner returns a running Runner of the memory backend over an
+// empty confdir, and a function stopping it.
+func newTestRunner(t *testing.T) (*Runner, Config, func()) {
+	log.SetLevel("fatal")
+	dir, err := ioutil.TempDir("", "confd")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	config := Config{
+		Backend:  backends.Config{Backend: "memory"},
+		Template: template.Config{ConfDir: dir},
+		Interval: 1,
+	}
+	r, err := New(config)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	done := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-r.Errors():
+			case <-done:
+				return
+			}
+		}
+	}()
+	if err := r.Start(); err != nil {
+		t.Fatal(err.Error())
+	}
+	return r, config, func() {
+		r.Stop()
+		close(done)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestReloadBackend(t *testing.T) {
+	r, config, stop := newTestRunner(t)
+	defer stop()
+	client := r.Client()
+	if err := client.Set("/app/name", "first"); err != nil {
+		t.Fatal(err.Error())
+	}
+	first := r.StoreClient()
+
+	// The same backend configuration keeps the client.
+	if err := r.Reload(config); err != nil {
+		t.Fatal(err.Error())
+	}
+	if r.StoreClient() != first {
+		t.Error("Expected the client to be kept when the backend did not change")
+	}
+
+	config.Backend.BackendNodes = []string{"second"}
+	if err := r.Reload(config); err != nil {
+		t.Fatal(err.Error())
+	}
+	if r.StoreClient() == first {
+		t.Fatal("Expected a new client for the new backend")
+	}
+	if r.TemplateConfig().StoreClient != r.StoreClient() {
+		t.Error("Expected the template resources to use the new client")
+	}
+	if r.Done() == nil {
+		t.Error("Expected the processor to be restarted")
+	}
+	values, err := client.GetValues(context.Background(), []string{"/app"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(values) != 0 {
+		t.Errorf("Expected Client to follow the reload to the new backend, got %v", values)
+	}
+	if ok, err := backends.Lock(client, "/confd/leader", "replica", time.Second); err != nil || !ok {
+		t.Errorf("Lock() with Client = %v, %v, want true", ok, err)
+	}
+}
+
+func TestReloadInvalid(t *testing.T) {
+	r, config, stop := newTestRunner(t)
+	defer stop()
+	first := r.StoreClient()
+	if err := first.Set("/app/name", "first"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	invalid := config
+	invalid.Backend = backends.Config{Backend: "nonexistent"}
+	invalid.Interval = 5
+	if err := r.Reload(invalid); err == nil {
+		t.Fatal("Expected a reload to an unknown backend to fail")
+	}
+	if r.StoreClient() != first {
+		t.Error("Expected the previous client to be kept")
+	}
+	if r.config.Interval != config.Interval {
+		t.Errorf("Expected the previous interval %d to be kept, got %d", config.Interval, r.config.Interval)
+	}
+	if r.Done() == nil {
+		t.Error("Expected the processor to keep running")
+	}
+	values, err := r.Client().GetValues(context.Background(), []string{"/app"})
+	if err != nil || values["/app/name"] != "first" {
+		t.Errorf("Expected the previous backend to keep serving, got %v, %v", values, err)
+	}
+}
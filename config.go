@@ -5,115 +5,289 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"net"
 	"os"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/kelseyhightower/confd/backends"
 	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/notify"
+	"github.com/kelseyhightower/confd/redact"
 	"github.com/kelseyhightower/confd/resource/template"
 )
 
 var (
-	configFile        = ""
-	defaultConfigFile = "/etc/confd/confd.conf"
-	authToken         string
-	authType          string
-	backend           string
-	basicAuth         bool
-	clientCaKeys      string
-	clientCert        string
-	clientKey         string
-	confdir           string
-	config            Config // holds the global confd config.
-	interval          int
-	keepStageFile     bool
-	logLevel          string
-	nodes             Nodes
-	noop              bool
-	onetime           bool
-	prefix            string
-	printVersion      bool
-	scheme            string
-	srvDomain         string
-	srvRecord         string
-	syncOnly          bool
-	table             string
-	templateConfig    template.Config
-	backendsConfig    backends.Config
-	username          string
-	password          string
-	watch             bool
-	appID             string
-	userID            string
-	port              int
-	adminUsername     string
-	adminPassword     string
+	configFile          = ""
+	defaultConfigFile   = "/etc/confd/confd.conf"
+	authToken           string
+	authType            string
+	backend             string
+	backendMode         string
+	basicAuth           bool
+	clientCaKeys        string
+	clientCert          string
+	clientKey           string
+	cmdGroup            string
+	cmdUser             string
+	confdir             string
+	execCommand         string
+	execKeys            Nodes
+	funcPlugins         Nodes
+	execReloadSignal    string
+	execKillTimeout     int
+	fixtures            string
+	config              Config // holds the global confd config.
+	interval            int
+	keepStageFile       bool
+	leaderElect         bool
+	leaderID            string
+	leaderKey           string
+	leaderTTL           int
+	logLevel            string
+	logFormat           string
+	logOutput           string
+	managedDir          string
+	managedDelete       bool
+	nodes               Nodes
+	noop                bool
+	notifyWebhooks      Nodes
+	notifyRetries       int
+	notifyTimeout       int
+	webhook             *notify.Webhook
+	onetime             bool
+	passTimeout         int
+	otlpEndpoint        string
+	otlpInsecure        bool
+	prefix              string
+	printVersion        bool
+	scheme              string
+	sensitiveKeys       Nodes
+	replicas            Nodes
+	separator           string
+	seedFile            string
+	shutdownTimeout     int
+	skipUnchanged       bool
+	srvDomain           string
+	srvRecord           string
+	srvRefresh          int
+	staleThreshold      int
+	stateDir            string
+	maxParallel         int
+	enableDebug         bool
+	enableSprig         bool
+	encryptionKeyFile   string
+	encryptionKeyCmd    string
+	eventHistory        int
+	aesKeyFile          string
+	ageIdentityFile     string
+	gpgKeyringFile      string
+	syncOnly            bool
+	table               string
+	templateConfig      template.Config
+	backendsConfig      backends.Config
+	username            string
+	password            string
+	passwordFile        string
+	passwordEnv         string
+	watch               bool
+	watchResume         string
+	appID               string
+	userID              string
+	port                int
+	adminUsername       string
+	adminPassword       string
+	adminViewerUsername string
+	adminViewerPassword string
+	adminSecretKey      string
+	adminTokenExpiry    int
+	adminTLSCert        string
+	adminTLSKey         string
+	adminClientCA       string
+	adminPushToken      string
+	auditLogFile        string
+	auditLogPrefix      string
 )
 
 // A Config structure is used to configure confd.
 type Config struct {
-	AuthToken     string   `toml:"auth_token"`
-	AuthType      string   `toml:"auth_type"`
-	Backend       string   `toml:"backend"`
-	BasicAuth     bool     `toml:"basic_auth"`
-	BackendNodes  []string `toml:"nodes"`
-	ClientCaKeys  string   `toml:"client_cakeys"`
-	ClientCert    string   `toml:"client_cert"`
-	ClientKey     string   `toml:"client_key"`
-	ConfDir       string   `toml:"confdir"`
-	Interval      int      `toml:"interval"`
-	Noop          bool     `toml:"noop"`
-	Password      string   `toml:"password"`
-	Prefix        string   `toml:"prefix"`
-	SRVDomain     string   `toml:"srv_domain"`
-	SRVRecord     string   `toml:"srv_record"`
-	Scheme        string   `toml:"scheme"`
-	SyncOnly      bool     `toml:"sync-only"`
-	Table         string   `toml:"table"`
-	Username      string   `toml:"username"`
-	LogLevel      string   `toml:"log-level"`
-	Watch         bool     `toml:"watch"`
-	AppID         string   `toml:"app_id"`
-	UserID        string   `toml:"user_id"`
-	Port          int      `toml:"port"`
-	AdminUsername string   `toml:"admin_username"`
-	AdminPassword string   `toml:"admin_password"`
+	AuthToken           string            `toml:"auth_token"`
+	AuthType            string            `toml:"auth_type"`
+	Backend             string            `toml:"backend"`
+	BasicAuth           bool              `toml:"basic_auth"`
+	BackendNodes        []string          `toml:"nodes"`
+	ClientCaKeys        string            `toml:"client_cakeys"`
+	ClientCert          string            `toml:"client_cert"`
+	ClientKey           string            `toml:"client_key"`
+	CmdGroup            string            `toml:"cmd_group"`
+	CmdUser             string            `toml:"cmd_user"`
+	ConfDir             string            `toml:"confdir"`
+	Interval            int               `toml:"interval"`
+	ManagedDir          string            `toml:"managed_dir"`
+	ManagedDelete       bool              `toml:"managed_delete"`
+	Noop                bool              `toml:"noop"`
+	NotifyWebhooks      []string          `toml:"notify_webhooks"`
+	NotifyRetries       int               `toml:"notify_retries"`
+	NotifyTimeout       int               `toml:"notify_timeout"`
+	OTLPEndpoint        string            `toml:"otlp_endpoint"`
+	OTLPInsecure        bool              `toml:"otlp_insecure"`
+	Password            string            `toml:"password"`
+	PasswordFile        string            `toml:"password_file"`
+	PasswordEnv         string            `toml:"password_env"`
+	PassTimeout         int               `toml:"pass_timeout"`
+	Prefix              string            `toml:"prefix"`
+	SRVDomain           string            `toml:"srv_domain"`
+	SRVRecord           string            `toml:"srv_record"`
+	SRVRefresh          int               `toml:"srv_refresh"`
+	Scheme              string            `toml:"scheme"`
+	SensitiveKeys       []string          `toml:"sensitive_keys"`
+	Replicas            []string          `toml:"replicas"`
+	Separator           string            `toml:"separator"`
+	SeedFile            string            `toml:"seed_file"`
+	ShutdownTimeout     int               `toml:"shutdown_timeout"`
+	SkipUnchanged       bool              `toml:"skip_unchanged"`
+	SyncOnly            bool              `toml:"sync-only"`
+	StaleThreshold      int               `toml:"stale_threshold"`
+	StateDir            string            `toml:"state_dir"`
+	MaxParallel         int               `toml:"max_parallel"`
+	EnableDebug         bool              `toml:"enable_debug"`
+	EnableSprig         bool              `toml:"enable_sprig"`
+	EncryptionKeyFile   string            `toml:"encryption_key_file"`
+	EncryptionKeyCmd    string            `toml:"encryption_key_cmd"`
+	EventHistory        int               `toml:"event_history"`
+	AESKeyFile          string            `toml:"aes_key_file"`
+	AgeIdentityFile     string            `toml:"age_identity_file"`
+	GPGKeyringFile      string            `toml:"gpg_keyring_file"`
+	Table               string            `toml:"table"`
+	Username            string            `toml:"username"`
+	LeaderElect         bool              `toml:"leader_elect"`
+	LeaderID            string            `toml:"leader_id"`
+	LeaderKey           string            `toml:"leader_key"`
+	LeaderTTL           int               `toml:"leader_ttl"`
+	LogLevel            string            `toml:"log-level"`
+	LogFormat           string            `toml:"log-format"`
+	LogOutput           string            `toml:"log-output"`
+	Watch               bool              `toml:"watch"`
+	WatchResume         string            `toml:"watch_resume"`
+	AppID               string            `toml:"app_id"`
+	UserID              string            `toml:"user_id"`
+	Port                int               `toml:"port"`
+	AdminUsername       string            `toml:"admin_username"`
+	AdminPassword       string            `toml:"admin_password"`
+	AdminViewerUsername string            `toml:"admin_viewer_username"`
+	AdminViewerPassword string            `toml:"admin_viewer_password"`
+	AdminSecretKey      string            `toml:"admin_secret_key"`
+	AdminTokenExpiry    int               `toml:"admin_token_expiry"`
+	AdminTLSCert        string            `toml:"admin_tls_cert"`
+	AdminTLSKey         string            `toml:"admin_tls_key"`
+	AdminClientCA       string            `toml:"admin_client_ca"`
+	AdminPushToken      string            `toml:"admin_push_token"`
+	AuditLogFile        string            `toml:"audit_log_file"`
+	AuditLogPrefix      string            `toml:"audit_log_prefix"`
+	Plugins             map[string]string `toml:"plugins"`
+	Backends            []backends.Config `toml:"backends"`
+	BackendMode         string            `toml:"backend_mode"`
+	RetryBase           int               `toml:"retry_base"`
+	RetryMax            int               `toml:"retry_max"`
+	RetryJitter         float64           `toml:"retry_jitter"`
+	MaxRetries          int               `toml:"max_retries"`
+	BreakerThreshold    int               `toml:"breaker_threshold"`
+	BreakerCooldown     int               `toml:"breaker_cooldown"`
+	CacheTTL            int               `toml:"cache_ttl"`
+	CacheTTLs           map[string]int    `toml:"cache_ttls"`
+	CacheServeStale     bool              `toml:"cache_serve_stale"`
+	Exec                string            `toml:"exec"`
+	ExecKeys            []string          `toml:"exec_keys"`
+	FuncPlugins         []string          `toml:"func_plugins"`
+	ExecReloadSignal    string            `toml:"exec_reload_signal"`
+	ExecKillTimeout     int               `toml:"exec_kill_timeout"`
 }
 
 func init() {
+	flag.StringVar(&aesKeyFile, "aes-key-file", "", "file holding the base64 encoded key of decryptAES (or set CONFD_AES_KEY)")
+	flag.StringVar(&ageIdentityFile, "age-identity-file", "", "file holding the age identities of decryptAge (or set CONFD_AGE_IDENTITY)")
 	flag.StringVar(&authToken, "auth-token", "", "Auth bearer token to use")
 	flag.StringVar(&backend, "backend", "etcd", "backend to use")
+	flag.StringVar(&backendMode, "backend-mode", "route", "how multiple backends are combined (route or overlay)")
 	flag.BoolVar(&basicAuth, "basic-auth", false, "Use Basic Auth to authenticate (only used with -backend=etcd)")
 	flag.StringVar(&clientCaKeys, "client-ca-keys", "", "client ca keys")
 	flag.StringVar(&clientCert, "client-cert", "", "the client cert")
 	flag.StringVar(&clientKey, "client-key", "", "the client key")
+	flag.StringVar(&cmdGroup, "cmd-group", "", "default group, name or ID, running check_cmd and reload_cmd")
+	flag.StringVar(&cmdUser, "cmd-user", "", "default user, name or ID, running check_cmd and reload_cmd")
 	flag.StringVar(&confdir, "confdir", "/etc/confd/conf.d", "confd conf directory")
 	flag.StringVar(&configFile, "config-file", "", "the confd config file")
+	flag.BoolVar(&enableDebug, "enable-debug", false, "serve the pprof profiles at /debug/pprof/ and the expvar variables at /debug/vars of the admin server, to read-write admin users")
+	flag.BoolVar(&enableSprig, "enable-sprig", false, "add the Sprig functions to templates")
+	flag.StringVar(&encryptionKeyFile, "encryption-key-file", "", "file holding the base64 encoded master key backend values are encrypted with")
+	flag.StringVar(&encryptionKeyCmd, "encryption-key-cmd", "", "command printing the base64 encoded master key backend values are encrypted with, such as a KMS decrypt command")
+	flag.IntVar(&eventHistory, "event-history", 200, "number of backend change events kept for the admin API (0 disables them)")
+	flag.StringVar(&execCommand, "exec", "", "command to run as a child process with environment variables rendered from -exec-key keys")
+	flag.Var(&execKeys, "exec-key", "list of keys exposed to the -exec child as environment variables")
+	flag.StringVar(&execReloadSignal, "exec-reload-signal", "", "signal sent to the -exec child when its keys change; it is restarted when empty")
+	flag.IntVar(&execKillTimeout, "exec-kill-timeout", 5, "seconds to wait for the -exec child to exit before killing it")
+	flag.StringVar(&fixtures, "fixtures", "", "directory of fixtures whose golden files confd validate checks the template resources against")
+	flag.Var(&funcPlugins, "func-plugin", "list of Go plugins (.so) exporting Funcs, registered as template functions")
+	flag.StringVar(&gpgKeyringFile, "gpg-keyring-file", "", "secret keyring of decryptGPG, unlocked with CONFD_GPG_PASSPHRASE")
 	flag.IntVar(&interval, "interval", 600, "backend polling interval")
+	flag.StringVar(&managedDir, "managed-dir", "", "directory whose files written by confd are removed once no template resource produces them")
+	flag.BoolVar(&managedDelete, "managed-delete", false, "remove the stale files of managed directories instead of only listing them")
+	flag.IntVar(&maxParallel, "max-parallel", 1, "number of template resources processed at once")
 	flag.BoolVar(&keepStageFile, "keep-stage-file", false, "keep staged files")
+	flag.BoolVar(&leaderElect, "leader-elect", false, "only process template resources while this replica holds the leader lock in the backend")
+	flag.StringVar(&leaderID, "leader-id", "", "identity of this replica in the leader lock (defaults to hostname-pid)")
+	flag.StringVar(&leaderKey, "leader-key", "/confd/leader", "backend key of the leader lock")
+	flag.IntVar(&leaderTTL, "leader-ttl", 15, "seconds after which the leader lock expires unless renewed")
 	flag.StringVar(&logLevel, "log-level", "", "level which confd should log messages")
+	flag.StringVar(&logFormat, "log-format", "", "format of log messages (text or json)")
+	flag.StringVar(&logOutput, "log-output", "", "where log messages are written (stdout, stderr or a file path)")
 	flag.Var(&nodes, "node", "list of backend nodes")
+	flag.Var(&replicas, "replica", "list of redis replicas serving reads, while writes go to -node (only used with -backend=redis)")
 	flag.BoolVar(&noop, "noop", false, "only show pending changes")
+	flag.Var(&notifyWebhooks, "notify-webhook", "list of URLs receiving a JSON event after each sync of a template resource")
+	flag.IntVar(&notifyRetries, "notify-retries", 3, "how many times a failed webhook delivery is retried")
+	flag.IntVar(&notifyTimeout, "notify-timeout", 10, "seconds after which a webhook request is abandoned")
 	flag.BoolVar(&onetime, "onetime", false, "run once and exit")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "", "host:port of the OTLP/HTTP collector receiving traces of processing passes")
+	flag.BoolVar(&otlpInsecure, "otlp-insecure", false, "send traces to the OTLP collector over plain HTTP")
+	flag.IntVar(&passTimeout, "pass-timeout", 0, "seconds the backend requests of a pass over a template resource may take (0 disables)")
 	flag.StringVar(&prefix, "prefix", "", "key path prefix")
 	flag.BoolVar(&printVersion, "version", false, "print version and exit")
 	flag.StringVar(&scheme, "scheme", "http", "the backend URI scheme for nodes retrieved from DNS SRV records (http or https)")
+	flag.Var(&sensitiveKeys, "sensitive-key", "list of key patterns, such as /secrets/*, whose values are masked in logs, errors and the admin API")
+	flag.StringVar(&seedFile, "seed-file", "", "JSON or YAML file of backend keys and values rendered while the backend is not reachable yet")
+	flag.StringVar(&separator, "separator", "/", "separator of redis keys, to which the / of confd keys are translated (only used with -backend=redis)")
+	flag.IntVar(&shutdownTimeout, "shutdown-timeout", 30, "seconds to wait on exit for the template resources being processed and their commands (0 waits until they are done)")
+	flag.BoolVar(&skipUnchanged, "skip-unchanged", false, "skip template resources whose values, templates and dest did not change since their last successful pass")
 	flag.StringVar(&srvDomain, "srv-domain", "", "the name of the resource record")
 	flag.StringVar(&srvRecord, "srv-record", "", "the SRV record to search for backends nodes. Example: _etcd-client._tcp.example.com")
+	flag.IntVar(&srvRefresh, "srv-refresh", 60, "seconds between two resolutions of the SRV record, reconnecting when the nodes changed (0 resolves it once)")
+	flag.StringVar(&stateDir, "state-dir", "", "directory keeping the values of the last successful pass over each template resource, rendered when the backend is down on startup")
 	flag.BoolVar(&syncOnly, "sync-only", false, "sync without check_cmd and reload_cmd")
+	flag.IntVar(&staleThreshold, "stale-threshold", 0, "seconds after which a template resource that was not synced is reported stale by /readyz (0 disables)")
 	flag.StringVar(&authType, "auth-type", "", "Vault auth backend type to use (only used with -backend=vault)")
 	flag.StringVar(&appID, "app-id", "", "Vault app-id to use with the app-id backend (only used with -backend=vault and auth-type=app-id)")
 	flag.StringVar(&userID, "user-id", "", "Vault user-id to use with the app-id backend (only used with -backend=value and auth-type=app-id)")
 	flag.StringVar(&table, "table", "", "the name of the DynamoDB table (only used with -backend=dynamodb)")
 	flag.StringVar(&username, "username", "", "the username to authenticate as (only used with vault and etcd backends)")
 	flag.StringVar(&password, "password", "", "the password to authenticate with (only used with vault and etcd backends)")
+	flag.StringVar(&passwordFile, "password-file", "", "file holding the password, re-read when it changes (overrides -password)")
+	flag.StringVar(&passwordEnv, "password-env", "", "environment variable holding the password (overrides -password)")
 	flag.BoolVar(&watch, "watch", false, "enable watch support")
+	flag.StringVar(&watchResume, "watch-resume", "now", "where watches start after a restart: now, or oldest to resume from the index kept in -state-dir")
 	flag.IntVar(&port, "port", 1520, "the port of webServer")
 	flag.StringVar(&adminUsername, "admin-username", "admin", "username of admin")
 	flag.StringVar(&adminPassword, "admin-password", "admin", "username of admin")
+	flag.StringVar(&adminViewerUsername, "admin-viewer-username", "", "username of the read-only admin user (disabled when empty)")
+	flag.StringVar(&adminViewerPassword, "admin-viewer-password", "", "password of the read-only admin user")
+	flag.StringVar(&adminSecretKey, "admin-secret-key", "", "key signing admin tokens; a random key is used when empty")
+	flag.IntVar(&adminTokenExpiry, "admin-token-expiry", 86400, "seconds after which admin tokens expire")
+	flag.StringVar(&adminTLSCert, "admin-tls-cert", "", "certificate serving the admin web server over https")
+	flag.StringVar(&adminTLSKey, "admin-tls-key", "", "key of -admin-tls-cert")
+	flag.StringVar(&adminClientCA, "admin-client-ca", "", "CA bundle verifying admin client certificates (requires -admin-tls-cert)")
+	flag.StringVar(&adminPushToken, "admin-push-token", "", "bearer token authenticating POST /api/push of the admin server, besides read-write admin tokens")
+	flag.StringVar(&auditLogFile, "audit-log-file", "", "file the audit log of key changes, syncs and template updates is appended to")
+	flag.StringVar(&auditLogPrefix, "audit-log-prefix", "", "backend key prefix the audit log is written below, instead of -audit-log-file")
 }
 
 // initConfig initializes the confd configuration by first setting defaults,
@@ -122,6 +296,15 @@ func init() {
 // settings from flags set on the command line.
 // It returns an error if any.
 func initConfig() error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	return applyConfig()
+}
+
+// loadConfig builds and validates the configuration of initConfig without
+// applying it to the process, so that a reload can still reject it.
+func loadConfig() error {
 	if configFile == "" {
 		if _, err := os.Stat(defaultConfigFile); !os.IsNotExist(err) {
 			configFile = defaultConfigFile
@@ -129,14 +312,24 @@ func initConfig() error {
 	}
 	// Set defaults.
 	config = Config{
-		Backend:       "etcd",
-		ConfDir:       "/etc/confd/conf.d",
-		Interval:      600,
-		Prefix:        "",
-		Scheme:        "http",
-		Port:          1520,
-		AdminUsername: "admin",
-		AdminPassword: "admin",
+		Backend:          "etcd",
+		ConfDir:          "/etc/confd/conf.d",
+		Interval:         600,
+		Prefix:           "",
+		Scheme:           "http",
+		Port:             1520,
+		AdminUsername:    "admin",
+		AdminPassword:    "admin",
+		AdminTokenExpiry: 86400,
+		ExecKillTimeout:  5,
+		MaxParallel:      1,
+		NotifyRetries:    3,
+		NotifyTimeout:    10,
+		SRVRefresh:       60,
+		ShutdownTimeout:  30,
+		LeaderKey:        "/confd/leader",
+		LeaderTTL:        15,
+		EventHistory:     200,
 	}
 	// Update config from the TOML configuration file.
 	if configFile == "" {
@@ -160,42 +353,33 @@ func initConfig() error {
 	processFlags()
 
 	if config.LogLevel != "" {
-		log.SetLevel(config.LogLevel)
+		if err := log.CheckLevel(config.LogLevel); err != nil {
+			return err
+		}
+	}
+
+	if config.LogFormat != "" {
+		if err := log.CheckFormat(config.LogFormat); err != nil {
+			return err
+		}
 	}
 
 	if config.SRVDomain != "" && config.SRVRecord == "" {
 		config.SRVRecord = fmt.Sprintf("_%s._tcp.%s.", config.Backend, config.SRVDomain)
 	}
 
-	// Update BackendNodes from SRV records.
-	if config.Backend != "env" && config.SRVRecord != "" {
-		log.Info("SRV record set to " + config.SRVRecord)
-		srvNodes, err := getBackendNodesFromSRV(config.SRVRecord, config.Scheme)
-		if err != nil {
-			return errors.New("Cannot get nodes from SRV records " + err.Error())
-		}
-		config.BackendNodes = srvNodes
-	}
-	if len(config.BackendNodes) == 0 {
-		switch config.Backend {
-		case "consul":
-			config.BackendNodes = []string{"127.0.0.1:8500"}
-		case "etcd":
-			peerstr := os.Getenv("ETCDCTL_PEERS")
-			if len(peerstr) > 0 {
-				config.BackendNodes = strings.Split(peerstr, ",")
-			} else {
-				config.BackendNodes = []string{"http://127.0.0.1:4001"}
-			}
-		case "redis":
-			config.BackendNodes = []string{"127.0.0.1:6379"}
-		case "zookeeper":
-			config.BackendNodes = []string{"127.0.0.1:2181"}
+	// BackendNodes are resolved from SRV records by the backend client.
+	if config.Backend == "env" {
+		config.SRVRecord = ""
+	}
+	if len(config.BackendNodes) == 0 && config.SRVRecord == "" {
+		config.BackendNodes = defaultBackendNodes(config.Backend)
+	}
+	for i, b := range config.Backends {
+		if len(b.BackendNodes) == 0 && b.SRVRecord == "" {
+			config.Backends[i].BackendNodes = defaultBackendNodes(b.Backend)
 		}
 	}
-	// Initialize the storage client
-	log.Info("Backend set to " + config.Backend)
-
 	if config.Watch {
 		unsupportedBackends := map[string]bool{
 			"redis":    true,
@@ -203,57 +387,154 @@ func initConfig() error {
 			"rancher":  true,
 		}
 
-		if unsupportedBackends[config.Backend] {
-			log.Info(fmt.Sprintf("Watch is not supported for backend %s. Exiting...", config.Backend))
-			os.Exit(1)
+		if len(config.Backends) == 0 && unsupportedBackends[config.Backend] {
+			return fmt.Errorf("Watch is not supported for backend %s", config.Backend)
 		}
 	}
 
+	if config.Exec != "" && len(config.ExecKeys) == 0 {
+		return errors.New("No keys configured for the exec child, use -exec-key")
+	}
+
+	if (config.AdminTLSCert == "") != (config.AdminTLSKey == "") {
+		return errors.New("Both -admin-tls-cert and -admin-tls-key are required to serve https")
+	}
+
+	if config.AdminClientCA != "" && config.AdminTLSCert == "" {
+		return errors.New("-admin-client-ca requires -admin-tls-cert and -admin-tls-key")
+	}
+
+	if config.AuditLogFile != "" && config.AuditLogPrefix != "" {
+		return errors.New("Only one of -audit-log-file and -audit-log-prefix can be set")
+	}
+
 	if config.Backend == "dynamodb" && config.Table == "" {
 		return errors.New("No DynamoDB table configured")
 	}
 
 	backendsConfig = backends.Config{
-		AuthToken:    config.AuthToken,
-		AuthType:     config.AuthType,
-		Backend:      config.Backend,
-		BasicAuth:    config.BasicAuth,
-		ClientCaKeys: config.ClientCaKeys,
-		ClientCert:   config.ClientCert,
-		ClientKey:    config.ClientKey,
-		BackendNodes: config.BackendNodes,
-		Password:     config.Password,
-		Scheme:       config.Scheme,
-		Table:        config.Table,
-		Username:     config.Username,
-		AppID:        config.AppID,
-		UserID:       config.UserID,
+		AuthToken:        config.AuthToken,
+		AuthType:         config.AuthType,
+		Backend:          config.Backend,
+		BasicAuth:        config.BasicAuth,
+		ClientCaKeys:     config.ClientCaKeys,
+		ClientCert:       config.ClientCert,
+		ClientKey:        config.ClientKey,
+		BackendNodes:     config.BackendNodes,
+		Password:         config.Password,
+		PasswordFile:     config.PasswordFile,
+		PasswordEnv:      config.PasswordEnv,
+		Scheme:           config.Scheme,
+		Separator:        config.Separator,
+		Replicas:         config.Replicas,
+		SRVRecord:        config.SRVRecord,
+		SRVRefresh:       config.SRVRefresh,
+		Table:            config.Table,
+		Username:         config.Username,
+		AppID:            config.AppID,
+		UserID:           config.UserID,
+		Plugins:          config.Plugins,
+		Backends:         config.Backends,
+		Mode:             config.BackendMode,
+		RetryBase:        config.RetryBase,
+		RetryMax:         config.RetryMax,
+		RetryJitter:      config.RetryJitter,
+		MaxRetries:       config.MaxRetries,
+		BreakerThreshold: config.BreakerThreshold,
+		BreakerCooldown:  config.BreakerCooldown,
+		CacheTTL:         config.CacheTTL,
+		CacheTTLs:        config.CacheTTLs,
+		CacheServeStale:  config.CacheServeStale,
 	}
+	backendsConfig.EncryptionKeyFile = config.EncryptionKeyFile
+	backendsConfig.EncryptionKeyCmd = config.EncryptionKeyCmd
+	backendsConfig.SeedFile = config.SeedFile
+	backendsConfig.WatchResume = config.WatchResume
 	//// Template configuration.
 	templateConfig = template.Config{
-		ConfDir:       config.ConfDir,
-		KeepStageFile: keepStageFile,
-		Noop:          config.Noop,
-		Prefix:        config.Prefix,
-		SyncOnly:      config.SyncOnly,
+		ConfDir:        config.ConfDir,
+		KeepStageFile:  keepStageFile,
+		Noop:           config.Noop,
+		Prefix:         config.Prefix,
+		SyncOnly:       config.SyncOnly,
+		SkipUnchanged:  config.SkipUnchanged,
+		ManagedDir:     config.ManagedDir,
+		ManagedDelete:  config.ManagedDelete,
+		PassTimeout:    time.Duration(config.PassTimeout) * time.Second,
+		StaleThreshold: config.StaleThreshold,
+		MaxParallel:    config.MaxParallel,
+		EnableSprig:    config.EnableSprig,
+	}
+	decrypter, err := template.NewDecrypter(template.DecryptConfig{
+		AESKey:          os.Getenv("CONFD_AES_KEY"),
+		AESKeyFile:      config.AESKeyFile,
+		AgeIdentity:     os.Getenv("CONFD_AGE_IDENTITY"),
+		AgeIdentityFile: config.AgeIdentityFile,
+		GPGKeyringFile:  config.GPGKeyringFile,
+		GPGPassphrase:   os.Getenv("CONFD_GPG_PASSPHRASE"),
+	})
+	if err != nil {
+		return err
+	}
+	templateConfig.Decrypter = decrypter
+	templateConfig.StateDir = config.StateDir
+	templateConfig.CmdUser = config.CmdUser
+	templateConfig.CmdGroup = config.CmdGroup
+	templateConfig.LockID = config.LeaderID
+	templateConfig.LockTTL = time.Duration(config.LeaderTTL) * time.Second
+
+	if len(config.NotifyWebhooks) > 0 {
+		timeout := time.Duration(config.NotifyTimeout) * time.Second
+		if webhook == nil {
+			webhook = notify.NewWebhook(config.NotifyWebhooks, config.NotifyRetries, timeout)
+		}
+		templateConfig.Notifier = webhook
 	}
 	return nil
 }
 
-func getBackendNodesFromSRV(record, scheme string) ([]string, error) {
-	nodes := make([]string, 0)
-
-	// Ignore the CNAME as we don't need it.
-	_, addrs, err := net.LookupSRV("", "", record)
-	if err != nil {
-		return nodes, err
+// applyConfig applies the settings of the configuration loaded by
+// loadConfig that are global to the process: logging, sensitive keys, the
+// change event history and the webhooks.
+func applyConfig() error {
+	if config.LogLevel != "" {
+		log.SetLevel(config.LogLevel)
+	}
+	if config.LogFormat != "" {
+		log.SetFormat(config.LogFormat)
 	}
-	for _, srv := range addrs {
-		host := strings.TrimRight(srv.Target, ".")
-		port := strconv.FormatUint(uint64(srv.Port), 10)
-		nodes = append(nodes, fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, port)))
+	if config.LogOutput != "" {
+		if err := log.SetOutput(config.LogOutput); err != nil {
+			return err
+		}
+	}
+	log.Info("Backend set to " + config.Backend)
+	redact.SetPatterns(config.SensitiveKeys)
+	template.SetEventHistory(config.EventHistory)
+	if webhook != nil && len(config.NotifyWebhooks) > 0 {
+		webhook.Configure(config.NotifyWebhooks, config.NotifyRetries, time.Duration(config.NotifyTimeout)*time.Second)
 	}
-	return nodes, nil
+	return nil
+}
+
+// defaultBackendNodes returns the nodes used for backend when none are
+// configured.
+func defaultBackendNodes(backend string) []string {
+	switch backend {
+	case "consul":
+		return []string{"127.0.0.1:8500"}
+	case "etcd":
+		peerstr := os.Getenv("ETCDCTL_PEERS")
+		if len(peerstr) > 0 {
+			return strings.Split(peerstr, ",")
+		}
+		return []string{"http://127.0.0.1:4001"}
+	case "redis":
+		return []string{"127.0.0.1:6379"}
+	case "zookeeper":
+		return []string{"127.0.0.1:2181"}
+	}
+	return nil
 }
 
 // processFlags iterates through each flag set on the command line and
@@ -277,6 +558,31 @@ func processEnv() {
 	if len(key) > 0 {
 		config.ClientKey = key
 	}
+
+	level := os.Getenv("CONFD_LOG_LEVEL")
+	if len(level) > 0 {
+		config.LogLevel = level
+	}
+
+	format := os.Getenv("CONFD_LOG_FORMAT")
+	if len(format) > 0 {
+		config.LogFormat = format
+	}
+
+	output := os.Getenv("CONFD_LOG_OUTPUT")
+	if len(output) > 0 {
+		config.LogOutput = output
+	}
+
+	secretKey := os.Getenv("CONFD_ADMIN_SECRET_KEY")
+	if len(secretKey) > 0 {
+		config.AdminSecretKey = secretKey
+	}
+
+	pushToken := os.Getenv("CONFD_ADMIN_PUSH_TOKEN")
+	if len(pushToken) > 0 {
+		config.AdminPushToken = pushToken
+	}
 }
 
 func setConfigFromFlag(f *flag.Flag) {
@@ -287,6 +593,8 @@ func setConfigFromFlag(f *flag.Flag) {
 		config.AuthType = authType
 	case "backend":
 		config.Backend = backend
+	case "backend-mode":
+		config.BackendMode = backendMode
 	case "basic-auth":
 		config.BasicAuth = basicAuth
 	case "client-cert":
@@ -295,34 +603,118 @@ func setConfigFromFlag(f *flag.Flag) {
 		config.ClientKey = clientKey
 	case "client-ca-keys":
 		config.ClientCaKeys = clientCaKeys
+	case "cmd-group":
+		config.CmdGroup = cmdGroup
+	case "cmd-user":
+		config.CmdUser = cmdUser
 	case "confdir":
 		config.ConfDir = confdir
+	case "encryption-key-file":
+		config.EncryptionKeyFile = encryptionKeyFile
+	case "encryption-key-cmd":
+		config.EncryptionKeyCmd = encryptionKeyCmd
+	case "event-history":
+		config.EventHistory = eventHistory
+	case "exec":
+		config.Exec = execCommand
+	case "exec-key":
+		config.ExecKeys = execKeys
+	case "func-plugin":
+		config.FuncPlugins = funcPlugins
+	case "exec-reload-signal":
+		config.ExecReloadSignal = execReloadSignal
+	case "exec-kill-timeout":
+		config.ExecKillTimeout = execKillTimeout
 	case "node":
 		config.BackendNodes = nodes
+	case "replica":
+		config.Replicas = replicas
 	case "interval":
 		config.Interval = interval
 	case "noop":
 		config.Noop = noop
+	case "notify-webhook":
+		config.NotifyWebhooks = notifyWebhooks
+	case "notify-retries":
+		config.NotifyRetries = notifyRetries
+	case "notify-timeout":
+		config.NotifyTimeout = notifyTimeout
+	case "otlp-endpoint":
+		config.OTLPEndpoint = otlpEndpoint
+	case "otlp-insecure":
+		config.OTLPInsecure = otlpInsecure
 	case "password":
 		config.Password = password
+	case "password-file":
+		config.PasswordFile = passwordFile
+	case "password-env":
+		config.PasswordEnv = passwordEnv
 	case "prefix":
 		config.Prefix = prefix
 	case "scheme":
 		config.Scheme = scheme
+	case "sensitive-key":
+		config.SensitiveKeys = sensitiveKeys
+	case "pass-timeout":
+		config.PassTimeout = passTimeout
+	case "seed-file":
+		config.SeedFile = seedFile
+	case "separator":
+		config.Separator = separator
+	case "shutdown-timeout":
+		config.ShutdownTimeout = shutdownTimeout
+	case "skip-unchanged":
+		config.SkipUnchanged = skipUnchanged
 	case "srv-domain":
 		config.SRVDomain = srvDomain
 	case "srv-record":
 		config.SRVRecord = srvRecord
+	case "srv-refresh":
+		config.SRVRefresh = srvRefresh
 	case "sync-only":
 		config.SyncOnly = syncOnly
+	case "stale-threshold":
+		config.StaleThreshold = staleThreshold
+	case "state-dir":
+		config.StateDir = stateDir
+	case "aes-key-file":
+		config.AESKeyFile = aesKeyFile
+	case "age-identity-file":
+		config.AgeIdentityFile = ageIdentityFile
+	case "gpg-keyring-file":
+		config.GPGKeyringFile = gpgKeyringFile
+	case "enable-debug":
+		config.EnableDebug = enableDebug
+	case "enable-sprig":
+		config.EnableSprig = enableSprig
+	case "max-parallel":
+		config.MaxParallel = maxParallel
+	case "managed-dir":
+		config.ManagedDir = managedDir
+	case "managed-delete":
+		config.ManagedDelete = managedDelete
 	case "table":
 		config.Table = table
 	case "username":
 		config.Username = username
+	case "leader-elect":
+		config.LeaderElect = leaderElect
+	case "leader-id":
+		config.LeaderID = leaderID
+	case "leader-key":
+		config.LeaderKey = leaderKey
+	case "leader-ttl":
+		config.LeaderTTL = leaderTTL
 	case "log-level":
 		config.LogLevel = logLevel
+	case "log-format":
+		config.LogFormat = logFormat
+	case "log-output":
+		config.LogOutput = logOutput
 	case "watch":
 		config.Watch = watch
+	case "watch-resume":
+		config.WatchResume = watchResume
 	case "app-id":
 		config.AppID = appID
 	case "user-id":
@@ -333,6 +725,26 @@ func setConfigFromFlag(f *flag.Flag) {
 		config.AdminUsername = adminUsername
 	case "admin-password":
 		config.AdminPassword = adminPassword
+	case "admin-viewer-username":
+		config.AdminViewerUsername = adminViewerUsername
+	case "admin-viewer-password":
+		config.AdminViewerPassword = adminViewerPassword
+	case "admin-secret-key":
+		config.AdminSecretKey = adminSecretKey
+	case "admin-token-expiry":
+		config.AdminTokenExpiry = adminTokenExpiry
+	case "admin-tls-cert":
+		config.AdminTLSCert = adminTLSCert
+	case "admin-tls-key":
+		config.AdminTLSKey = adminTLSKey
+	case "admin-client-ca":
+		config.AdminClientCA = adminClientCA
+	case "admin-push-token":
+		config.AdminPushToken = adminPushToken
+	case "audit-log-file":
+		config.AuditLogFile = auditLogFile
+	case "audit-log-prefix":
+		config.AuditLogPrefix = auditLogPrefix
 
 	}
 }
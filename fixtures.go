@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/kelseyhightower/confd/backends"
+	"github.com/kelseyhightower/confd/backends/memory"
+	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/resource/template"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// fixtureKeyFiles are the names of the keys file of a fixture, in the
+// format written by `confd keys export`.
+var fixtureKeyFiles = []string{"keys.json", "keys.yaml", "keys.yml"}
+
+// validateFixtures renders the template resources against every fixture
+// of dir and compares the results with the expected ones. A fixture is a
+// directory holding a keys file and, for each template resource to check,
+// a <name>.golden file with its expected output.
+func validateFixtures(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	checked, failed := 0, 0
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		c, f, err := validateFixture(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return fmt.Errorf("fixture %s: %s", e.Name(), err.Error())
+		}
+		checked += c
+		failed += f
+	}
+	if checked == 0 {
+		return errors.New("no golden files found in " + dir)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d golden file(s) differ", failed, checked)
+	}
+	return nil
+}
+
+// validateFixture checks the golden files of the fixture in dir and returns
+// how many were checked and how many differ.
+func validateFixture(dir string) (int, int, error) {
+	var keys map[string]string
+	for _, name := range fixtureKeyFiles {
+		file := filepath.Join(dir, name)
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+		var err error
+		if keys, err = readKeysFile(file); err != nil {
+			return 0, 0, err
+		}
+		break
+	}
+	if keys == nil {
+		return 0, 0, errors.New("no keys.json or keys.yaml")
+	}
+	values := make(map[string]string, len(keys))
+	for k, v := range keys {
+		values[path.Join("/", config.Prefix, k)] = v
+	}
+	client := memory.NewMemoryClient(values)
+
+	tc := templateConfig
+	tc.StoreClient = client
+	tc.Backend = "memory"
+	tc.StoreClients = make(map[string]backends.StoreClient)
+	for i, b := range config.Backends {
+		name := b.Name
+		if name == "" {
+			name = fmt.Sprintf("%s#%d", b.Backend, i)
+		}
+		tc.StoreClients[name] = client
+	}
+	ts, err := template.GetTemplateResources(tc)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	fixture := filepath.Base(dir)
+	checked, failed := 0, 0
+	for _, t := range ts {
+		golden := filepath.Join(dir, t.Name+".golden")
+		expected, err := ioutil.ReadFile(golden)
+		if os.IsNotExist(err) {
+			log.Debug("No golden file for " + t.Name + " in " + dir)
+			continue
+		}
+		if err != nil {
+			return checked, failed, err
+		}
+		checked++
+		rendered, err := t.Render()
+		if err != nil {
+			fmt.Printf("%s/%s: %s\n", fixture, t.Name, err.Error())
+			failed++
+			continue
+		}
+		if bytes.Equal(rendered, expected) {
+			fmt.Printf("%s/%s: ok\n", fixture, t.Name)
+			continue
+		}
+		failed++
+		diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(expected)),
+			B:        difflib.SplitLines(string(rendered)),
+			FromFile: golden,
+			ToFile:   t.Name + " (rendered)",
+			Context:  3,
+		})
+		fmt.Printf("%s/%s: differs\n%s", fixture, t.Name, diff)
+	}
+	return checked, failed, nil
+}
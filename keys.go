@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kelseyhightower/confd/backends"
+	"gopkg.in/yaml.v2"
+)
+
+// isYAMLFile reports whether name has a YAML extension. Other files,
+// including stdout, hold JSON.
+func isYAMLFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// relativeKey returns key relative to -prefix.
+func relativeKey(key string) string {
+	prefix := path.Join("/", config.Prefix)
+	if prefix == "/" {
+		return key
+	}
+	return path.Join("/", strings.TrimPrefix(key, prefix))
+}
+
+// exportKeys writes the keys below key, relative to -prefix, with their
+// values to file, or stdout when file is empty or "-".
+func exportKeys(client backends.StoreClient, key, file string) error {
+	vars, err := client.GetValues(context.Background(), []string{key})
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]string, len(vars))
+	for k, v := range vars {
+		keys[relativeKey(k)] = v
+	}
+	var data []byte
+	if isYAMLFile(file) {
+		data, err = yaml.Marshal(keys)
+	} else {
+		data, err = json.MarshalIndent(keys, "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return err
+	}
+	if file == "" || file == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0600)
+}
+
+// readKeysFile reads the keys of file, as written by exportKeys, or of
+// stdin when file is "-".
+func readKeysFile(file string) (map[string]string, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]string)
+	if isYAMLFile(file) {
+		err = yaml.Unmarshal(data, &keys)
+	} else {
+		err = json.Unmarshal(data, &keys)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse %s: %s", file, err.Error())
+	}
+	return keys, nil
+}
+
+// importKeys sets the keys of file, as written by exportKeys, below
+// -prefix.
+func importKeys(client backends.StoreClient, file string) error {
+	keys, err := readKeysFile(file)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return errors.New("no keys in " + file)
+	}
+	names := make([]string, 0, len(keys))
+	for k := range keys {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if err := client.Set(path.Join("/", config.Prefix, k), keys[k]); err != nil {
+			return fmt.Errorf("Cannot set %s: %s", k, err.Error())
+		}
+	}
+	fmt.Printf("Imported %d key(s)\n", len(names))
+	return nil
+}
@@ -0,0 +1,99 @@
+// Package leader elects one of several confd replicas sharing a backend to
+// process the template resources. The leader holds a lock key in the
+// backend and keeps renewing it; a standby takes over once it expired.
+// Semaphores use the same locks to let a few replicas at a time reload.
+package leader
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kelseyhightower/confd/backends"
+	"github.com/kelseyhightower/confd/log"
+)
+
+// Elector campaigns for the lock of a replica.
+type Elector struct {
+	client backends.StoreClient
+	key    string
+	id     string
+	ttl    time.Duration
+
+	stopOnce sync.Once
+	stopChan chan bool
+	doneChan chan bool
+}
+
+// New returns an Elector campaigning for key as id with client. The lock
+// expires ttl after the last renewal, which happens every third of ttl.
+func New(client backends.StoreClient, key, id string, ttl time.Duration) *Elector {
+	return &Elector{
+		client:   client,
+		key:      key,
+		id:       id,
+		ttl:      ttl,
+		stopChan: make(chan bool),
+		doneChan: make(chan bool),
+	}
+}
+
+// Run campaigns until Stop is called, sending true on leaderChan when the
+// replica becomes the leader and false when it stops being one. It fails at
+// once when the backend cannot hold locks.
+func (e *Elector) Run(leaderChan chan<- bool) error {
+	defer close(e.doneChan)
+	interval := e.ttl / 3
+	leading := false
+	var renewed time.Time
+	for {
+		ok, err := backends.Lock(e.client, e.key, e.id, e.ttl)
+		switch {
+		case err == backends.ErrLockUnsupported:
+			return err
+		case err != nil:
+			log.Warning("Cannot renew leader lock %s: %s", e.key, err.Error())
+			// Step down before the lock expires under another replica.
+			if leading && time.Since(renewed)+interval >= e.ttl {
+				leading = false
+				log.Warning("Lost leadership of %s", e.key)
+				e.send(leaderChan, false)
+			}
+		case ok:
+			renewed = time.Now()
+			if !leading {
+				leading = true
+				log.Info("Elected leader of %s as %s", e.key, e.id)
+				e.send(leaderChan, true)
+			}
+		case leading:
+			leading = false
+			log.Warning("Lost leadership of %s to another replica", e.key)
+			e.send(leaderChan, false)
+		}
+		select {
+		case <-e.stopChan:
+			if leading {
+				if err := backends.Unlock(e.client, e.key, e.id); err != nil {
+					log.Warning("Cannot release leader lock %s: %s", e.key, err.Error())
+				}
+			}
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// send sends leading on leaderChan, unless Stop is called meanwhile.
+func (e *Elector) send(leaderChan chan<- bool, leading bool) {
+	select {
+	case leaderChan <- leading:
+	case <-e.stopChan:
+	}
+}
+
+// Stop ends Run, releasing the lock if the replica is the leader, and waits
+// until it returned.
+func (e *Elector) Stop() {
+	e.stopOnce.Do(func() { close(e.stopChan) })
+	<-e.doneChan
+}
@@ -0,0 +1,140 @@
+package leader
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kelseyhightower/confd/backends/memory"
+	"github.com/kelseyhightower/confd/backends/redis"
+)
+
+func TestElector(t *testing.T) {
+	client := memory.NewMemoryClient(nil)
+	ttl := 150 * time.Millisecond
+
+	first := New(client, "/confd/leader", "first", ttl)
+	firstChan := make(chan bool)
+	go first.Run(firstChan)
+	select {
+	case leading := <-firstChan:
+		if !leading {
+			t.Fatal("first replica deposed before being elected")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("first replica not elected")
+	}
+
+	second := New(client, "/confd/leader", "second", ttl)
+	secondChan := make(chan bool)
+	go second.Run(secondChan)
+	defer second.Stop()
+	select {
+	case <-secondChan:
+		t.Fatal("second replica elected while the first one leads")
+	case <-time.After(2 * ttl):
+	}
+
+	// The first replica releases the lock: the standby takes over.
+	first.Stop()
+	select {
+	case leading := <-secondChan:
+		if !leading {
+			t.Fatal("second replica deposed before being elected")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second replica not elected after the leader stopped")
+	}
+}
+
+func TestSemaphore(t *testing.T) {
+	client := memory.NewMemoryClient(nil)
+	semaphorePoll = 10 * time.Millisecond
+	ctx := context.Background()
+
+	release1, err := NewSemaphore(client, "/confd/reload", "first", 2, time.Second).Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release2, err := NewSemaphore(client, "/confd/reload", "second", 2, time.Second).Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release2()
+
+	// Both slots are held: the third replica waits until one is released.
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	third := NewSemaphore(client, "/confd/reload", "third", 2, time.Second)
+	if _, err := third.Acquire(short); err == nil {
+		t.Fatal("third replica acquired a held semaphore")
+	}
+	release1()
+	release3, err := third.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release3()
+}
+
+// redisClient returns a client of the redis server at CONFD_TEST_REDIS,
+// 127.0.0.1:6379 by default, and skips the test when none is listening.
+func redisClient(t *testing.T) *redis.Client {
+	addr := os.Getenv("CONFD_TEST_REDIS")
+	if addr == "" {
+		addr = "127.0.0.1:6379"
+	}
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Skipf("no redis server at %s: %s", addr, err.Error())
+	}
+	conn.Close()
+	client, err := redis.NewRedisClient([]string{addr}, nil, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// TestRedisConcurrentUse shares a redis client between an elector, a held
+// semaphore and reads, as confd does. Run it with -race.
+func TestRedisConcurrentUse(t *testing.T) {
+	client := redisClient(t)
+	defer client.Close()
+	if err := client.Set("/confd-test/key", "value"); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Remove("/confd-test/key")
+	ttl := 150 * time.Millisecond
+
+	elector := New(client, "/confd-test/leader", "first", ttl)
+	leaderChan := make(chan bool, 10)
+	go elector.Run(leaderChan)
+	defer elector.Stop()
+	release, err := NewSemaphore(client, "/confd-test/reload", "first", 1, ttl).Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	deadline := time.Now().Add(2 * ttl)
+	for time.Now().Before(deadline) {
+		values, err := client.GetValues(context.Background(), []string{"/confd-test/key"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if values["/confd-test/key"] != "value" {
+			t.Fatalf("GetValues() = %v, want value at /confd-test/key", values)
+		}
+	}
+	select {
+	case leading := <-leaderChan:
+		if !leading {
+			t.Fatal("elector deposed before being elected")
+		}
+	default:
+		t.Fatal("elector not elected")
+	}
+}
@@ -0,0 +1,100 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"path"
+	"time"
+
+	"github.com/kelseyhightower/confd/backends"
+	"github.com/kelseyhightower/confd/log"
+)
+
+// semaphorePoll is how often Acquire tries the slots again while they are
+// all held.
+var semaphorePoll = time.Second
+
+// Semaphore is held by at most size replicas at once, each holding the lock
+// of one of the size slot keys below its key, such as key/0.
+type Semaphore struct {
+	client backends.StoreClient
+	key    string
+	id     string
+	size   int
+	ttl    time.Duration
+}
+
+// NewSemaphore returns the Semaphore of key with size slots, held as id with
+// client. A slot expires ttl after the last renewal, which happens every
+// third of ttl while it is held.
+func NewSemaphore(client backends.StoreClient, key, id string, size int, ttl time.Duration) *Semaphore {
+	if size < 1 {
+		size = 1
+	}
+	return &Semaphore{client: client, key: key, id: id, size: size, ttl: ttl}
+}
+
+// Acquire waits until it holds a slot, or until ctx is done, and returns the
+// function releasing it. It fails at once when the backend cannot hold
+// locks.
+func (s *Semaphore) Acquire(ctx context.Context) (func(), error) {
+	// Start at a random slot, so that replicas do not all contend for the
+	// first one.
+	offset := rand.Intn(s.size)
+	waiting := false
+	for {
+		for i := 0; i < s.size; i++ {
+			slot := path.Join(s.key, fmt.Sprint((offset+i)%s.size))
+			ok, err := backends.Lock(s.client, slot, s.id, s.ttl)
+			if err == backends.ErrLockUnsupported {
+				return nil, err
+			}
+			if err != nil {
+				log.Warning("Cannot lock %s: %s", slot, err.Error())
+				continue
+			}
+			if ok {
+				return s.hold(slot), nil
+			}
+		}
+		if !waiting {
+			waiting = true
+			log.Info("Waiting for one of the %d slot(s) of %s", s.size, s.key)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("cannot acquire %s: %s", s.key, ctx.Err().Error())
+		case <-time.After(semaphorePoll):
+		}
+	}
+}
+
+// hold renews slot until the returned function is called, which releases
+// it.
+func (s *Semaphore) hold(slot string) func() {
+	stopChan := make(chan bool)
+	doneChan := make(chan bool)
+	go func() {
+		defer close(doneChan)
+		for {
+			select {
+			case <-stopChan:
+				return
+			case <-time.After(s.ttl / 3):
+			}
+			if ok, err := backends.Lock(s.client, slot, s.id, s.ttl); err != nil {
+				log.Warning("Cannot renew %s: %s", slot, err.Error())
+			} else if !ok {
+				log.Warning("Lost %s to another replica", slot)
+			}
+		}
+	}()
+	return func() {
+		close(stopChan)
+		<-doneChan
+		if err := backends.Unlock(s.client, slot, s.id); err != nil {
+			log.Warning("Cannot release %s: %s", slot, err.Error())
+		}
+	}
+}
@@ -3,17 +3,22 @@ Package log provides support for logging to stdout and stderr.
 
 Log entries will be logged in the following format:
 
-    timestamp hostname tag[pid]: SEVERITY Message
+	timestamp hostname tag[pid]: SEVERITY Message key=value...
+
+or, with SetFormat("json"), as one JSON object per line.
 */
 package log
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/kelseyhightower/confd/redact"
 )
 
 type ConfdFormatter struct {
@@ -22,7 +27,18 @@ type ConfdFormatter struct {
 func (c *ConfdFormatter) Format(entry *log.Entry) ([]byte, error) {
 	timestamp := time.Now().Format(time.RFC3339)
 	hostname, _ := os.Hostname()
-	return []byte(fmt.Sprintf("%s %s %s[%d]: %s %s\n", timestamp, hostname, tag, os.Getpid(), strings.ToUpper(entry.Level.String()), entry.Message)), nil
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "%s %s %s[%d]: %s %s", timestamp, hostname, tag, os.Getpid(), strings.ToUpper(entry.Level.String()), entry.Message)
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, " %s=%v", k, entry.Data[k])
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
 }
 
 // tag represents the application name generating the log message. The tag
@@ -32,6 +48,24 @@ var tag string
 func init() {
 	tag = os.Args[0]
 	log.SetFormatter(&ConfdFormatter{})
+	log.AddHook(redactHook{})
+}
+
+// redactHook masks the values of sensitive keys in log entries.
+type redactHook struct{}
+
+func (redactHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (redactHook) Fire(entry *log.Entry) error {
+	entry.Message = redact.Text(entry.Message)
+	for k, v := range entry.Data {
+		if s, ok := v.(string); ok {
+			entry.Data[k] = redact.Text(s)
+		}
+	}
+	return nil
 }
 
 const LOG_CACHING_KEY = "/kelseyhightower/confd/logging"
@@ -41,6 +75,14 @@ func SetTag(t string) {
 	tag = t
 }
 
+// CheckLevel returns an error unless level is a valid log level.
+func CheckLevel(level string) error {
+	if _, err := log.ParseLevel(level); err != nil {
+		return fmt.Errorf(`not a valid level: "%s"`, level)
+	}
+	return nil
+}
+
 // SetLevel sets the log level. Valid levels are panic, fatal, error, warn, info and debug.
 func SetLevel(level string) {
 	lvl, err := log.ParseLevel(level)
@@ -50,42 +92,151 @@ func SetLevel(level string) {
 	log.SetLevel(lvl)
 }
 
+// CheckFormat returns an error unless format is a valid log format.
+func CheckFormat(format string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf(`not a valid format: "%s"`, format)
+	}
+	return nil
+}
+
+// SetFormat sets the log format. Valid formats are text and json.
+func SetFormat(format string) {
+	switch format {
+	case "text":
+		log.SetFormatter(&ConfdFormatter{})
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{TimestampFormat: time.RFC3339})
+	default:
+		Fatal(fmt.Sprintf(`not a valid format: "%s"`, format))
+	}
+}
+
+// outputFile is the file log entries are appended to, opened from
+// outputPath, or nil when they are written to stdout or stderr.
+var (
+	outputPath string
+	outputFile *os.File
+)
+
+// SetOutput sets where log entries are written. Valid outputs are stdout,
+// stderr or the path of a file entries are appended to. The file is kept
+// open while output stays the same. When the file cannot be opened, the
+// output is left unchanged.
+func SetOutput(output string) error {
+	if outputFile != nil && output == outputPath {
+		return nil
+	}
+	switch output {
+	case "stdout":
+		log.SetOutput(os.Stdout)
+	case "stderr":
+		log.SetOutput(os.Stderr)
+	default:
+		f, err := os.OpenFile(output, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return fmt.Errorf("cannot open log output: %s", err.Error())
+		}
+		log.SetOutput(f)
+		if outputFile != nil {
+			outputFile.Close()
+		}
+		outputPath, outputFile = output, f
+		return nil
+	}
+	if outputFile != nil {
+		outputFile.Close()
+	}
+	outputPath, outputFile = output, nil
+	return nil
+}
+
+// Fields are contextual key/values attached to log entries.
+type Fields map[string]interface{}
+
+// An Entry logs messages with a set of fields.
+type Entry struct {
+	entry *log.Entry
+}
+
+var std = &Entry{entry: log.NewEntry(log.StandardLogger())}
+
+// WithFields returns an Entry logging messages with fields.
+func WithFields(fields Fields) *Entry {
+	return std.WithFields(fields)
+}
+
+// WithFields returns an Entry logging messages with the fields of e and
+// fields.
+func (e *Entry) WithFields(fields Fields) *Entry {
+	return &Entry{entry: e.entry.WithFields(log.Fields(fields))}
+}
+
 // Debug logs a message with severity DEBUG.
 func Debug(format string, v ...interface{}) {
-	text := fmt.Sprintf(format, v...)
-	ls := GetLogQueue()
-	ls.Set(text, log.DebugLevel.String())
-	log.Debug(text)
+	std.Debug(format, v...)
 }
 
 // Error logs a message with severity ERROR.
 func Error(format string, v ...interface{}) {
-	text := fmt.Sprintf(format, v...)
-	ls := GetLogQueue()
-	ls.Set(text, log.ErrorLevel.String())
-	log.Error(text)
+	std.Error(format, v...)
 }
 
 // Fatal logs a message with severity ERROR followed by a call to os.Exit().
 func Fatal(format string, v ...interface{}) {
-	text := fmt.Sprintf(format, v...)
-	ls := GetLogQueue()
-	ls.Set(text, log.FatalLevel.String())
-	log.Fatal(text)
+	std.Fatal(format, v...)
 }
 
 // Info logs a message with severity INFO.
 func Info(format string, v ...interface{}) {
-	text := fmt.Sprintf(format, v...)
-	ls := GetLogQueue()
-	ls.Set(text, log.InfoLevel.String())
-	log.Info(text)
+	std.Info(format, v...)
 }
 
 // Warning logs a message with severity WARNING.
 func Warning(format string, v ...interface{}) {
+	std.Warning(format, v...)
+}
+
+// queue sends text to the log queue streamed to the admin web UI, with the
+// sensitive values masked like in the log, unless level is not logged.
+func queue(level log.Level, text string) {
+	if log.GetLevel() < level {
+		return
+	}
+	GetLogQueue().Set(redact.Text(text), level.String())
+}
+
+// Debug logs a message with severity DEBUG.
+func (e *Entry) Debug(format string, v ...interface{}) {
+	text := fmt.Sprintf(format, v...)
+	queue(log.DebugLevel, text)
+	e.entry.Debug(text)
+}
+
+// Error logs a message with severity ERROR.
+func (e *Entry) Error(format string, v ...interface{}) {
+	text := fmt.Sprintf(format, v...)
+	queue(log.ErrorLevel, text)
+	e.entry.Error(text)
+}
+
+// Fatal logs a message with severity ERROR followed by a call to os.Exit().
+func (e *Entry) Fatal(format string, v ...interface{}) {
+	text := fmt.Sprintf(format, v...)
+	queue(log.FatalLevel, text)
+	e.entry.Fatal(text)
+}
+
+// Info logs a message with severity INFO.
+func (e *Entry) Info(format string, v ...interface{}) {
+	text := fmt.Sprintf(format, v...)
+	queue(log.InfoLevel, text)
+	e.entry.Info(text)
+}
+
+// Warning logs a message with severity WARNING.
+func (e *Entry) Warning(format string, v ...interface{}) {
 	text := fmt.Sprintf(format, v...)
-	ls := GetLogQueue()
-	ls.Set(text, log.WarnLevel.String())
-	log.Warning(text)
+	queue(log.WarnLevel, text)
+	e.entry.Warning(text)
 }
@@ -0,0 +1,45 @@
+package log
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kelseyhightower/confd/redact"
+)
+
+// drain returns the messages waiting in the log queue.
+func drain() []string {
+	var messages []string
+	q := GetLogQueue()
+	for {
+		select {
+		case m := <-q.messageQueue:
+			messages = append(messages, m)
+		default:
+			return messages
+		}
+	}
+}
+
+func TestQueueMasksSensitiveValues(t *testing.T) {
+	SetLevel("info")
+	defer SetLevel("warn")
+	redact.SetPatterns([]string{"/secrets/*"})
+	defer redact.SetPatterns(nil)
+	redact.Observe("log", map[string]string{"/secrets/db": "hunter22"})
+	defer redact.Observe("log", nil)
+	drain()
+
+	Info("connecting with hunter22")
+	WithFields(Fields{"resource": "app"}).Error("cannot use hunter22")
+	Debug("debug hunter22")
+	messages := drain()
+	if len(messages) != 2 {
+		t.Fatalf("Expected the 2 entries at or above info to be queued, got %q", messages)
+	}
+	for _, m := range messages {
+		if strings.Contains(m, "hunter22") || !strings.Contains(m, redact.Mask) {
+			t.Errorf("Expected the sensitive value to be masked, got %q", m)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Package metrics exposes Prometheus metrics about backend requests and
+template processing.
+*/
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// BackendRequestDuration observes the latency of backend requests.
+	BackendRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "confd",
+		Subsystem: "backend",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of backend requests.",
+	}, []string{"backend", "method"})
+
+	// BackendErrors counts failed backend requests.
+	BackendErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "confd",
+		Subsystem: "backend",
+		Name:      "errors_total",
+		Help:      "Number of failed backend requests.",
+	}, []string{"backend", "method"})
+
+	// TemplateRenders counts rendered template resources.
+	TemplateRenders = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "confd",
+		Subsystem: "template",
+		Name:      "renders_total",
+		Help:      "Number of times a template resource was rendered.",
+	}, []string{"resource"})
+
+	// RendersSkipped counts watch events skipped because no key read by
+	// the template of the resource changed.
+	RendersSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "confd",
+		Subsystem: "template",
+		Name:      "renders_skipped_total",
+		Help:      "Number of watch events not rendered because no key read by the template changed.",
+	}, []string{"resource"})
+
+	// CommandFailures counts failed check_cmd and reload_cmd runs.
+	CommandFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "confd",
+		Subsystem: "template",
+		Name:      "command_failures_total",
+		Help:      "Number of failed check_cmd and reload_cmd runs.",
+	}, []string{"resource", "command"})
+
+	// LastSync records when a template resource was last synced successfully.
+	LastSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "confd",
+		Subsystem: "template",
+		Name:      "last_sync_timestamp_seconds",
+		Help:      "Unix time of the last successful sync of a template resource.",
+	}, []string{"resource"})
+
+	// WatchReconnects counts watches restarted after a backend error.
+	WatchReconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "confd",
+		Subsystem: "watch",
+		Name:      "reconnects_total",
+		Help:      "Number of watches restarted after a backend error.",
+	}, []string{"resource"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		BackendRequestDuration,
+		BackendErrors,
+		TemplateRenders,
+		RendersSkipped,
+		CommandFailures,
+		LastSync,
+		WatchReconnects,
+	)
+}
+
+// ObserveBackendRequest records a backend request started at start.
+func ObserveBackendRequest(backend, method string, start time.Time, err error) {
+	BackendRequestDuration.WithLabelValues(backend, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		BackendErrors.WithLabelValues(backend, method).Inc()
+	}
+}
+
+// Handler returns the HTTP handler serving the metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
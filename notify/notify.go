@@ -0,0 +1,49 @@
+// Package notify delivers template change events to external systems.
+package notify
+
+import (
+	"time"
+)
+
+// Reload results of an Event.
+const (
+	ReloadOK     = "ok"
+	ReloadFailed = "failed"
+)
+
+// DiffSummary counts the lines a sync added to and removed from the
+// destination.
+type DiffSummary struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+// Event describes a sync of a template resource, successful or not.
+type Event struct {
+	Resource string      `json:"resource"`
+	Dest     string      `json:"dest"`
+	Success  bool        `json:"success"`
+	Error    string      `json:"error,omitempty"`
+	Diff     DiffSummary `json:"diff"`
+	// Reload is ReloadOK or ReloadFailed, and empty when no reload_cmd ran.
+	Reload string    `json:"reload,omitempty"`
+	Time   time.Time `json:"timestamp"`
+}
+
+// A Notifier is told about every sync. Notify must not block processing.
+type Notifier interface {
+	Notify(e Event)
+}
+
+// Multi returns a Notifier telling every one of notifiers.
+func Multi(notifiers ...Notifier) Notifier {
+	return multi(notifiers)
+}
+
+type multi []Notifier
+
+func (m multi) Notify(e Event) {
+	for _, n := range m {
+		n.Notify(e)
+	}
+}
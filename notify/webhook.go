@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kelseyhightower/confd/log"
+)
+
+// queueSize is the number of events a Webhook buffers before dropping new
+// ones.
+const queueSize = 100
+
+// Webhook POSTs events as JSON to URLs, retrying failed deliveries. Events
+// are delivered in order by a background goroutine.
+type Webhook struct {
+	mu   sync.Mutex
+	urls []string
+	// retries is how many times a failed delivery is retried, with a
+	// delay growing by a second per attempt.
+	retries int
+	client  *http.Client
+	queue   chan Event
+}
+
+// NewWebhook creates a Webhook and starts delivering its events. Each
+// request is abandoned after timeout.
+func NewWebhook(urls []string, retries int, timeout time.Duration) *Webhook {
+	w := &Webhook{queue: make(chan Event, queueSize)}
+	w.Configure(urls, retries, timeout)
+	go w.run()
+	return w
+}
+
+// Configure changes the settings of w, for the events delivered from now
+// on.
+func (w *Webhook) Configure(urls []string, retries int, timeout time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.urls = urls
+	w.retries = retries
+	w.client = &http.Client{Timeout: timeout}
+}
+
+func (w *Webhook) settings() ([]string, int, *http.Client) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.urls, w.retries, w.client
+}
+
+// Notify queues e for delivery. It is dropped when the queue is full.
+func (w *Webhook) Notify(e Event) {
+	select {
+	case w.queue <- e:
+	default:
+		log.Warning("Webhook queue full, dropping event of %s", e.Resource)
+	}
+}
+
+func (w *Webhook) run() {
+	for e := range w.queue {
+		body, err := json.Marshal(e)
+		if err != nil {
+			log.Error("Cannot encode event of %s: %s", e.Resource, err.Error())
+			continue
+		}
+		urls, retries, client := w.settings()
+		for _, url := range urls {
+			if err := deliver(client, url, body, retries); err != nil {
+				log.Error("Webhook %s failed: %s", url, err.Error())
+			}
+		}
+	}
+}
+
+// deliver POSTs body to url, retrying retries times.
+func deliver(client *http.Client, url string, body []byte, retries int) error {
+	var err error
+	for i := 0; i <= retries; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i) * time.Second)
+		}
+		if err = post(client, url, body); err == nil {
+			return nil
+		}
+		log.Debug("Webhook %s attempt %d failed: %s", url, i+1, err.Error())
+	}
+	return err
+}
+
+func post(client *http.Client, url string, body []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookRetries(t *testing.T) {
+	events := make(chan Event, 1)
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		events <- e
+	}))
+	defer ts.Close()
+
+	w := NewWebhook([]string{ts.URL}, 1, time.Second)
+	w.Notify(Event{Resource: "nginx", Dest: "/etc/nginx/nginx.conf", Success: true, Reload: ReloadOK})
+	select {
+	case e := <-events:
+		if e.Resource != "nginx" || !e.Success || e.Reload != ReloadOK {
+			t.Errorf("unexpected event %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not delivered")
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
@@ -0,0 +1,133 @@
+// Package redact masks the values of sensitive keys in logs, errors and
+// the admin API. Rendering is not affected.
+package redact
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces sensitive values.
+const Mask = "******"
+
+// minLength is the length below which observed values are not masked in
+// free text, where they would mask unrelated words and numbers.
+const minLength = 4
+
+var (
+	mu       sync.RWMutex
+	patterns []string
+	// observed holds the sensitive values of each source, and values all
+	// of them, longest first.
+	observed = make(map[string]map[string]bool)
+	values   []string
+)
+
+// SetPatterns marks the keys matching one of patterns, shell patterns such
+// as "/secrets/*", and the keys below them as sensitive.
+func SetPatterns(p []string) {
+	mu.Lock()
+	defer mu.Unlock()
+	patterns = append([]string(nil), p...)
+}
+
+// IsSensitive reports whether key, or one of its parents, matches a
+// pattern.
+func IsSensitive(key string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return isSensitive(key)
+}
+
+func isSensitive(key string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	for k := path.Join("/", key); k != "/"; k = path.Dir(k) {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, k); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Value returns value, or Mask when key is sensitive.
+func Value(key, value string) string {
+	if IsSensitive(key) {
+		return Mask
+	}
+	return value
+}
+
+// Map returns a copy of vars with the values of sensitive keys masked.
+func Map(vars map[string]string) map[string]string {
+	masked := make(map[string]string, len(vars))
+	for k, v := range vars {
+		masked[k] = Value(k, v)
+	}
+	return masked
+}
+
+// Observe replaces the values remembered for source, such as a template
+// resource, with those of the sensitive keys of vars, so that Text masks
+// them and no longer the values they replaced.
+func Observe(source string, vars map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+	set := make(map[string]bool)
+	for k, v := range vars {
+		if len(v) >= minLength && isSensitive(k) {
+			set[v] = true
+		}
+	}
+	observe(source, set)
+}
+
+// ObserveValues replaces the values remembered for source with vs, which
+// are sensitive whatever their key, such as decrypted values.
+func ObserveValues(source string, vs []string) {
+	mu.Lock()
+	defer mu.Unlock()
+	set := make(map[string]bool)
+	for _, v := range vs {
+		if len(v) >= minLength {
+			set[v] = true
+		}
+	}
+	observe(source, set)
+}
+
+func observe(source string, set map[string]bool) {
+	if len(set) == 0 {
+		delete(observed, source)
+	} else {
+		observed[source] = set
+	}
+	all := make(map[string]bool)
+	for _, s := range observed {
+		for v := range s {
+			all[v] = true
+		}
+	}
+	values = values[:0]
+	for v := range all {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+}
+
+// Text returns s with the observed sensitive values masked.
+func Text(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, v := range values {
+		if strings.Contains(s, v) {
+			s = strings.Replace(s, v, Mask, -1)
+		}
+	}
+	return s
+}
@@ -0,0 +1,56 @@
+package redact
+
+import (
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	SetPatterns([]string{"/secrets/*"})
+	defer SetPatterns(nil)
+
+	sensitive := map[string]bool{
+		"/secrets/db":      true,
+		"/secrets/db/pass": true,
+		"/secrets":         false,
+		"/app/secrets/db":  false,
+	}
+	for key, want := range sensitive {
+		if got := IsSensitive(key); got != want {
+			t.Errorf("IsSensitive(%q) = %v, want %v", key, got, want)
+		}
+	}
+
+	Observe("app", map[string]string{"/secrets/db/pass": "hunter22", "/app/name": "myapp", "/secrets/pin": "42"})
+	defer Observe("app", nil)
+	if got, want := Text("cannot connect with hunter22 to myapp"), "cannot connect with ****** to myapp"; got != want {
+		t.Errorf("Text = %q, want %q", got, want)
+	}
+	if got := Text("port 42"); got != "port 42" {
+		t.Errorf("short values must not be masked in text, got %q", got)
+	}
+	if got := Value("/secrets/pin", "42"); got != Mask {
+		t.Errorf("Value = %q, want %q", got, Mask)
+	}
+}
+
+func TestObserveReplaces(t *testing.T) {
+	SetPatterns([]string{"/secrets/*"})
+	defer SetPatterns(nil)
+	defer Observe("app", nil)
+	defer Observe("other", nil)
+	defer ObserveValues("decrypted", nil)
+
+	Observe("app", map[string]string{"/secrets/db": "hunter22"})
+	Observe("other", map[string]string{"/secrets/db": "swordfish"})
+	ObserveValues("decrypted", []string{"plaintext"})
+	if got, want := Text("hunter22 swordfish plaintext"), "****** ****** ******"; got != want {
+		t.Errorf("Text = %q, want %q", got, want)
+	}
+
+	// A rotated secret is masked in place of the previous one.
+	Observe("app", map[string]string{"/secrets/db": "correcthorse"})
+	ObserveValues("decrypted", nil)
+	if got, want := Text("hunter22 correcthorse swordfish plaintext"), "hunter22 ****** ****** plaintext"; got != want {
+		t.Errorf("Text = %q, want %q", got, want)
+	}
+}
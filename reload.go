@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/kelseyhightower/confd/confd"
+	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/resource/template"
+)
+
+// runnerConfig returns the confd.Config of the configuration built by
+// initConfig.
+func runnerConfig() confd.Config {
+	tc := templateConfig
+	tc.Heartbeat = sdHeartbeat
+	return confd.Config{
+		Backend:  backendsConfig,
+		Template: tc,
+		Watch:    config.Watch,
+		Interval: config.Interval,
+	}
+}
+
+// resourceNames returns the set of template resource names of c.
+func resourceNames(c template.Config) map[string]bool {
+	names := make(map[string]bool)
+	ts, err := template.GetTemplateResources(c)
+	if err != nil {
+		log.Warning(err.Error())
+	}
+	for _, t := range ts {
+		names[t.Name] = true
+	}
+	return names
+}
+
+// reload re-reads the confd configuration and the template resources and
+// restarts the processor with them. The previous configuration is kept if
+// the new one is invalid, and the logging, sensitive keys and webhook
+// settings are only applied once the processor runs with the new one.
+// Admin server and exec settings are not reloaded; the leader elector and
+// the exec child follow the new backend client.
+func reload(r *confd.Runner) error {
+	oldConfig, oldTemplateConfig, oldBackendsConfig := config, templateConfig, backendsConfig
+	restore := func() {
+		config, templateConfig, backendsConfig = oldConfig, oldTemplateConfig, oldBackendsConfig
+	}
+	oldNames := resourceNames(r.TemplateConfig())
+
+	log.Info("Reloading configuration")
+	if err := loadConfig(); err != nil {
+		restore()
+		return err
+	}
+	if err := r.Reload(runnerConfig()); err != nil {
+		restore()
+		return err
+	}
+	if err := applyConfig(); err != nil {
+		log.Error("Cannot apply the reloaded configuration: %s", err.Error())
+	}
+	setupAudit(r.StoreClient())
+
+	newNames := resourceNames(r.TemplateConfig())
+	for name := range newNames {
+		if !oldNames[name] {
+			log.Info("Template resource " + name + " added")
+		}
+	}
+	for name := range oldNames {
+		if !newNames[name] {
+			log.Info("Template resource " + name + " removed")
+		}
+	}
+	log.Info("Configuration reloaded")
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/kelseyhightower/confd/confd"
+	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/redact"
+)
+
+func TestReloadInvalidConfig(t *testing.T) {
+	log.SetLevel("warn")
+	dir, err := ioutil.TempDir("", "confd")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	defer func(previous string) { configFile = previous }(configFile)
+	configFile = filepath.Join(dir, "confd.toml")
+	write := func(toml string) {
+		if err := ioutil.WriteFile(configFile, []byte("confdir = \""+dir+"\"\n"+toml), 0644); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	write("backend = \"memory\"\nsensitive_keys = [\"/secrets/*\"]\n")
+	if err := initConfig(); err != nil {
+		t.Fatal(err.Error())
+	}
+	log.SetLevel("warn")
+	runner, err := confd.New(runnerConfig())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	client := runner.StoreClient()
+
+	invalid := map[string]string{
+		"log level":   "backend = \"memory\"\nlog-level = \"loud\"\n",
+		"log format":  "backend = \"memory\"\nlog-format = \"xml\"\n",
+		"watch":       "backend = \"redis\"\nwatch = true\n",
+		"backend":     "backend = \"nonexistent\"\nsensitive_keys = [\"/other/*\"]\n",
+		"audit store": "backend = \"memory\"\naudit_log_file = \"a\"\naudit_log_prefix = \"/a\"\n",
+	}
+	for desc, toml := range invalid {
+		write(toml)
+		if err := reload(runner); err == nil {
+			t.Errorf("%s: expected the reload to fail", desc)
+		}
+		if config.Backend != "memory" || runner.StoreClient() != client {
+			t.Errorf("%s: expected the previous configuration to be kept", desc)
+		}
+		if logrus.GetLevel() != logrus.WarnLevel {
+			t.Errorf("%s: expected the log level to be kept, got %s", desc, logrus.GetLevel())
+		}
+		if !redact.IsSensitive("/secrets/db") || redact.IsSensitive("/other/db") {
+			t.Errorf("%s: expected the sensitive keys to be kept", desc)
+		}
+	}
+
+	write("backend = \"memory\"\nlog-level = \"error\"\ninterval = 5\n")
+	if err := reload(runner); err != nil {
+		t.Fatal(err.Error())
+	}
+	if logrus.GetLevel() != logrus.ErrorLevel {
+		t.Errorf("Expected the reloaded log level error, got %s", logrus.GetLevel())
+	}
+	log.SetLevel("warn")
+	redact.SetPatterns(nil)
+}
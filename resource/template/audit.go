@@ -0,0 +1,41 @@
+package template
+
+import (
+	"io/ioutil"
+
+	"github.com/kelseyhightower/confd/audit"
+	"github.com/kelseyhightower/confd/redact"
+)
+
+// startAudit starts the audit entry of an update of the destination with
+// the staged file.
+func (t *TemplateResource) startAudit(staged string) {
+	if !audit.Enabled() {
+		return
+	}
+	actor := t.actor
+	if actor == "" {
+		actor = audit.ActorConfd
+	}
+	e := &audit.Entry{Actor: actor, Action: audit.ActionUpdate, Target: t.Name}
+	current, err := ioutil.ReadFile(t.Dest)
+	e.OldChecksum = audit.Checksum(current, err == nil)
+	rendered, err := ioutil.ReadFile(staged)
+	e.NewChecksum = audit.Checksum(rendered, err == nil)
+	t.auditEntry = e
+}
+
+// recordAudit records the started audit entry, if any, with the result
+// err of the update.
+func (t *TemplateResource) recordAudit(err error) {
+	e := t.auditEntry
+	t.auditEntry = nil
+	if e == nil {
+		return
+	}
+	e.Success = err == nil
+	if err != nil {
+		e.Error = redact.Text(err.Error())
+	}
+	audit.Record(*e)
+}
@@ -0,0 +1,109 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupSuffix is inserted between the destination and the timestamp in the
+// names of backups.
+const backupSuffix = ".confd-backup."
+
+// copyFile copies src to dst through a temporary file, keeping the mode,
+// owner, SELinux context and ACL of src.
+func copyFile(src, dst string) error {
+	fi, err := fileStat(src)
+	if err != nil {
+		return err
+	}
+	contents, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(dst), "."+filepath.Base(dst))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(contents); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	copyXattr(src, temp.Name(), xattrSELinux)
+	copyXattr(src, temp.Name(), xattrACL)
+	chown(temp.Name(), int(fi.Uid), int(fi.Gid))
+	os.Chmod(temp.Name(), fi.Mode)
+	err = renameFile(temp.Name(), dst)
+	if err != nil && strings.Contains(err.Error(), "device or resource busy") {
+		// dst is likely a mount, write to it instead.
+		return ioutil.WriteFile(dst, contents, fi.Mode)
+	}
+	return err
+}
+
+// backupDest copies the destination to a timestamped backup before it is
+// overwritten. It returns the path of the backup, which is empty when the
+// destination does not exist yet.
+func (t *TemplateResource) backupDest() (string, error) {
+	if !isFileExist(t.Dest) {
+		return "", nil
+	}
+	backup := t.Dest + backupSuffix + time.Now().Format("20060102150405.000000000")
+	t.logger().Debug("Backing up " + t.Dest + " to " + backup)
+	if err := copyFile(t.Dest, backup); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+// pruneBackups removes all but the newest Backups backups of the
+// destination.
+func (t *TemplateResource) pruneBackups() error {
+	backups, err := filepath.Glob(t.Dest + backupSuffix + "*")
+	if err != nil {
+		return err
+	}
+	sort.Strings(backups)
+	for len(backups) > t.Backups {
+		t.logger().Debug("Removing backup " + backups[0])
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// rollback restores the destination from backup, or removes it when there
+// was nothing to back up.
+func (t *TemplateResource) rollback(backup string) error {
+	t.logger().Warning("Rolling back " + t.Dest)
+	if backup == "" {
+		return os.Remove(t.Dest)
+	}
+	return copyFile(backup, t.Dest)
+}
+
+// restore rolls the destination back after reload_cmd failed and, with
+// rollback_reload, reloads the previous config.
+func (t *TemplateResource) restore(backup string) {
+	if err := t.rollback(backup); err != nil {
+		t.logger().Error("Rollback of " + t.Dest + " failed: " + err.Error())
+		return
+	}
+	if backup != "" && t.Backups == 0 {
+		os.Remove(backup)
+	}
+	if t.RollbackReload {
+		if err := t.reload(); err != nil {
+			t.logger().Error("Reload after rollback of " + t.Dest + " failed: " + err.Error())
+		}
+	}
+}
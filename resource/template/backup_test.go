@@ -0,0 +1,132 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/kelseyhightower/confd/backends/env"
+	"github.com/kelseyhightower/confd/log"
+)
+
+// newTestResource loads the template resource resourceToml from a file in
+// dir with the env backend, and sets its dest to dir/dest.conf.
+func newTestResource(t *testing.T, dir, resourceToml string) *TemplateResource {
+	path := filepath.Join(dir, "resource.toml")
+	if err := ioutil.WriteFile(path, []byte(resourceToml), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	storeClient, err := env.NewEnvClient()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tr, err := NewTemplateResource(path, Config{StoreClient: storeClient}, &Project{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tr.Dest = filepath.Join(dir, "dest.conf")
+	tr.FileMode = 0644
+	return tr
+}
+
+// stage makes contents the stage file of tr.
+func stage(t *testing.T, tr *TemplateResource, contents string) {
+	temp, err := ioutil.TempFile(filepath.Dir(tr.Dest), ".stage")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer temp.Close()
+	if _, err := temp.WriteString(contents); err != nil {
+		t.Fatal(err.Error())
+	}
+	tr.StageFile = temp
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return string(data)
+}
+
+func TestPruneBackups(t *testing.T) {
+	log.SetLevel("warn")
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	tr := newTestResource(t, dir, `
+[template]
+src = "test.tmpl"
+dest = "dest.conf"
+backups = 2
+`)
+	for _, contents := range []string{"one", "two", "three", "four"} {
+		stage(t, tr, contents)
+		if err := tr.sync(); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	backups, err := filepath.Glob(tr.Dest + backupSuffix + "*")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var got []string
+	for _, backup := range backups {
+		got = append(got, readFile(t, backup))
+	}
+	if expected := []string{"two", "three"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected backups %v, got %v", expected, got)
+	}
+	if got := readFile(t, tr.Dest); got != "four" {
+		t.Errorf("Expected dest four, got %s", got)
+	}
+}
+
+func TestRollbackFailedReload(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("reload_cmd needs a POSIX shell")
+	}
+	log.SetLevel("fatal")
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	tr := newTestResource(t, dir, `
+[template]
+src = "test.tmpl"
+dest = "dest.conf"
+rollback = true
+reload_cmd = "grep -q good `+filepath.Join(dir, "dest.conf")+`"
+`)
+	stage(t, tr, "good")
+	if err := tr.sync(); err != nil {
+		t.Fatal(err.Error())
+	}
+	stage(t, tr, "bad")
+	if err := tr.sync(); err == nil {
+		t.Error("Expected the failing reload_cmd to fail sync")
+	}
+	if got := readFile(t, tr.Dest); got != "good" {
+		t.Errorf("Expected dest to be rolled back to good, got %s", got)
+	}
+	if backups, _ := filepath.Glob(tr.Dest + backupSuffix + "*"); len(backups) != 0 {
+		t.Errorf("Expected no backup to be kept without backups, got %v", backups)
+	}
+
+	// Without a previous dest, the rollback removes it.
+	os.Remove(tr.Dest)
+	stage(t, tr, "bad")
+	if err := tr.sync(); err == nil {
+		t.Error("Expected the failing reload_cmd to fail sync")
+	}
+	if isFileExist(tr.Dest) {
+		t.Errorf("Expected %s to be removed by the rollback", tr.Dest)
+	}
+}
@@ -0,0 +1,65 @@
+package template
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Base64Decode decodes s, standard base64 possibly wrapped over several
+// lines, into the raw bytes it holds.
+func Base64Decode(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Base64Encode encodes s in standard base64.
+func Base64Encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// checkBinary validates the binary setting of t.
+func (t *TemplateResource) checkBinary() error {
+	if !t.Binary {
+		return nil
+	}
+	if t.Src != "" || t.Format != "" {
+		return errors.New("binary excludes src and format")
+	}
+	if len(t.Keys) != 1 {
+		return errors.New("binary requires exactly one key")
+	}
+	return nil
+}
+
+// renderBinary returns the decoded value of the key of a binary resource.
+func (t *TemplateResource) renderBinary() ([]byte, error) {
+	key := t.GetAllKeys()[0]
+	v, ok := t.vars[filepath.Join("/", strings.TrimPrefix(key, t.Prefix))]
+	if !ok {
+		return nil, fmt.Errorf("key %s not found", key)
+	}
+	b, err := Base64Decode(v)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode %s: %s", key, err.Error())
+	}
+	return []byte(b), nil
+}
+
+// diff returns the diff turning current into rendered, which only tells
+// whether they differ for binary resources.
+func (t *TemplateResource) diff(current, rendered []byte) (string, error) {
+	if !t.Binary {
+		return unifiedDiff(t.Dest, current, t.Dest+" (rendered)", rendered)
+	}
+	if bytes.Equal(current, rendered) {
+		return "", nil
+	}
+	return fmt.Sprintf("Binary files %s and %s (rendered) differ\n", t.Dest, t.Dest), nil
+}
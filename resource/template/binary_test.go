@@ -0,0 +1,95 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kelseyhightower/confd/backends/env"
+)
+
+func TestBase64Decode(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		ok       bool
+	}{
+		{"aGVsbG8=", "hello", true},
+		{"aGVs\nbG8=\n", "hello", true},
+		{"  aGVsbG8=  ", "hello", true},
+		{"AP8A", "\x00\xff\x00", true},
+		{"", "", true},
+		{"aGVsbG8", "", false},
+		{"not base64!", "", false},
+	}
+	for _, tt := range tests {
+		got, err := Base64Decode(tt.value)
+		if (err == nil) != tt.ok || got != tt.expected {
+			t.Errorf("Base64Decode(%q) = %q, %v, want %q", tt.value, got, err, tt.expected)
+		}
+	}
+}
+
+func TestRenderBinary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binary")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	tr := newTestResource(t, dir, `
+[template]
+binary = true
+dest = "dest.conf"
+keys = ["/cert"]
+`)
+	tr.vars = map[string]string{"/cert": "AP8K\nAA=="}
+	got, err := tr.render()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(got) != "\x00\xff\n\x00" {
+		t.Errorf("Expected the decoded value, got %q", got)
+	}
+
+	tr.vars = map[string]string{"/cert": "not base64!"}
+	if _, err := tr.render(); err == nil {
+		t.Error("Expected an invalid value to fail")
+	}
+	tr.vars = map[string]string{}
+	if _, err := tr.render(); err == nil {
+		t.Error("Expected a missing key to fail")
+	}
+
+	if diff, _ := tr.diff([]byte("a"), []byte("b")); diff == "" {
+		t.Error("Expected binary files that differ to have a diff")
+	}
+	if diff, _ := tr.diff([]byte("a"), []byte("a")); diff != "" {
+		t.Errorf("Expected no diff for the same binary files, got %q", diff)
+	}
+}
+
+func TestCheckBinary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binary")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	storeClient, err := env.NewEnvClient()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	invalid := map[string]string{
+		"src":  "binary = true\nsrc = \"test.tmpl\"\nkeys = [\"/cert\"]",
+		"keys": "binary = true\nkeys = [\"/cert\", \"/key\"]",
+	}
+	for desc, resource := range invalid {
+		path := filepath.Join(dir, desc+".toml")
+		if err := ioutil.WriteFile(path, []byte("[template]\ndest = \"dest.conf\"\n"+resource+"\n"), 0644); err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, err := NewTemplateResource(path, Config{StoreClient: storeClient}, &Project{}); err == nil {
+			t.Errorf("%s: expected the binary resource to be invalid", desc)
+		}
+	}
+}
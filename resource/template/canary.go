@@ -0,0 +1,81 @@
+package template
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// checkCanary validates the canary settings of t.
+func (t *TemplateResource) checkCanary() error {
+	switch {
+	case t.CanaryCmd != "" && t.CanaryDest == "":
+		return errors.New("canary_cmd requires canary_dest")
+	case t.CanaryDest != "" && t.DestPattern != "":
+		return errors.New("canary_dest is not supported with dest_pattern")
+	}
+	return nil
+}
+
+// canary writes the staged file to canary_dest, with the metadata of dest,
+// and runs check_cmd against it and then canary_cmd, so that dest is only
+// replaced once the new config passed both. A failing canary_dest is kept
+// for inspection.
+func (t *TemplateResource) canary(staged string) error {
+	contents, err := ioutil.ReadFile(staged)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(t.CanaryDest)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(dir, "."+filepath.Base(t.CanaryDest))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	_, err = temp.Write(contents)
+	if cerr := temp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := t.setMetadata(temp.Name()); err != nil {
+		return err
+	}
+	if err := renameFile(temp.Name(), t.CanaryDest); err != nil {
+		return err
+	}
+	t.logger().Debug("Wrote canary config " + t.CanaryDest)
+	if t.CheckCmd != "" {
+		if err := t.check(t.CanaryDest); err != nil {
+			return err
+		}
+	}
+	if t.CanaryCmd == "" {
+		return nil
+	}
+	cmd, err := expandCommand("canarycmd", t.CanaryCmd, t.CanaryDest)
+	if err != nil {
+		return err
+	}
+	return t.runCommand("canary", cmd)
+}
+
+// expandCommand returns cmd with {{.src}} replaced by src.
+func expandCommand(name, cmd, src string) (string, error) {
+	var buf bytes.Buffer
+	tmpl, err := template.New(name).Parse(cmd)
+	if err != nil {
+		return "", err
+	}
+	if err := tmpl.Execute(&buf, map[string]string{"src": src}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,96 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/kelseyhightower/confd/backends/env"
+	"github.com/kelseyhightower/confd/log"
+)
+
+func TestCheckCanary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "canary")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	storeClient, err := env.NewEnvClient()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	invalid := map[string]string{
+		"cmd":          "dest = \"dest.conf\"\ncanary_cmd = \"true\"",
+		"dest_pattern": "dest_pattern = \"{{.Name}}.conf\"\nitems = \"/*\"\ncanary_dest = \"canary.conf\"",
+	}
+	for desc, resource := range invalid {
+		path := filepath.Join(dir, desc+".toml")
+		if err := ioutil.WriteFile(path, []byte("[template]\nsrc = \"test.tmpl\"\n"+resource+"\n"), 0644); err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, err := NewTemplateResource(path, Config{StoreClient: storeClient}, &Project{}); err == nil {
+			t.Errorf("%s: expected the canary settings to be invalid", desc)
+		}
+	}
+}
+
+func TestExpandCommand(t *testing.T) {
+	got, err := expandCommand("canarycmd", "nginx -t -c {{.src}}", "/etc/nginx/canary.conf")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if expected := "nginx -t -c /etc/nginx/canary.conf"; got != expected {
+		t.Errorf("expandCommand() = %q, want %q", got, expected)
+	}
+	if _, err := expandCommand("canarycmd", "{{.src", ""); err == nil {
+		t.Error("Expected an invalid command template to fail")
+	}
+}
+
+func TestCanary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("canary_cmd needs a POSIX shell")
+	}
+	log.SetLevel("fatal")
+	dir, err := ioutil.TempDir("", "canary")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	canaryDest := filepath.Join(dir, "canary", "canary.conf")
+	tr := newTestResource(t, dir, `
+[template]
+src = "test.tmpl"
+dest = "dest.conf"
+canary_dest = "`+canaryDest+`"
+check_cmd = "grep -q good {{.src}}"
+canary_cmd = "grep -q canary {{.src}}"
+`)
+	stage(t, tr, "good canary")
+	if err := tr.sync(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := readFile(t, tr.Dest); got != "good canary" {
+		t.Errorf("Expected dest good canary, got %s", got)
+	}
+	if got := readFile(t, canaryDest); got != "good canary" {
+		t.Errorf("Expected canary_dest good canary, got %s", got)
+	}
+
+	// A config failing check_cmd or canary_cmd is kept in canary_dest
+	// only.
+	for _, contents := range []string{"bad canary", "good"} {
+		stage(t, tr, contents)
+		if err := tr.sync(); err == nil {
+			t.Errorf("%s: expected the canary to fail sync", contents)
+		}
+		if got := readFile(t, tr.Dest); got != "good canary" {
+			t.Errorf("%s: expected dest to be kept, got %s", contents, got)
+		}
+		if got := readFile(t, canaryDest); got != contents {
+			t.Errorf("Expected the failing canary_dest %s, got %s", contents, got)
+		}
+	}
+}
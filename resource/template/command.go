@@ -0,0 +1,158 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kelseyhightower/confd/metrics"
+	"github.com/kelseyhightower/confd/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// CommandError is returned when check_cmd or reload_cmd of a template
+// resource failed on every attempt.
+type CommandError struct {
+	Resource string
+	Command  string
+	Attempts int
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("%s of %s failed after %d attempt(s): %s", e.Command, e.Resource, e.Attempts, e.Err.Error())
+}
+
+// runCommand runs cmd, a check_cmd or reload_cmd as named by kind, retrying
+// it cmd_retries times. Each attempt is killed with its whole process group
+// after cmd_timeout seconds.
+func (t *TemplateResource) runCommand(kind, cmd string) (err error) {
+	_, span := tracing.Start(t.ctx, kind+"_cmd", attribute.String("command", cmd))
+	defer func() { tracing.End(span, err) }()
+	attempts := t.CmdRetries + 1
+	timeout := time.Duration(t.CmdTimeout) * time.Second
+	for i := 1; i <= attempts; i++ {
+		if i > 1 {
+			t.logger().Warning("Retrying %s, attempt %d of %d", kind, i, attempts)
+		}
+		t.logger().Debug("Running " + cmd)
+		var output []byte
+		output, err = runShell(t.Shell, cmd, timeout, t.credential)
+		if kind == "reload" {
+			status := exitStatus(err)
+			t.reloadExit = &status
+		}
+		if err == nil {
+			t.logger().Debug(fmt.Sprintf("%q", string(output)))
+			span.SetAttributes(attribute.Int("attempts", i))
+			return nil
+		}
+		metrics.CommandFailures.WithLabelValues(t.Name, kind).Inc()
+		t.logger().Error(fmt.Sprintf("%q", string(output)))
+	}
+	span.SetAttributes(attribute.Int("attempts", attempts))
+	return &CommandError{Resource: t.Name, Command: kind + "_cmd", Attempts: attempts, Err: err}
+}
+
+// exitStatus returns the exit status of a command that failed with err, or
+// -1 when it did not run to completion.
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	if e, ok := err.(*exec.ExitError); ok {
+		if ws, ok := e.Sys().(syscall.WaitStatus); ok {
+			return ws.ExitStatus()
+		}
+	}
+	return -1
+}
+
+// shellCommand returns the command running cmd with shell: cmd for cmd.exe,
+// powershell or pwsh for PowerShell, or any shell taking -c. An empty shell
+// selects the platform default.
+func shellCommand(shell, cmd string) *exec.Cmd {
+	if shell == "" {
+		shell = defaultShell
+	}
+	switch strings.ToLower(shell) {
+	case "cmd", "cmd.exe":
+		return exec.Command(shell, "/C", cmd)
+	case "powershell", "powershell.exe", "pwsh", "pwsh.exe":
+		return exec.Command(shell, "-NoProfile", "-NonInteractive", "-Command", cmd)
+	}
+	return exec.Command(shell, "-c", cmd)
+}
+
+// credential is the user and groups of the commands of a template
+// resource with cmd_user or cmd_group.
+type credential struct {
+	uid, gid uint32
+	groups   []uint32
+}
+
+// runShell runs cmd with shell, as cred unless nil, and returns its combined
+// output. When timeout is positive the command and its children are killed
+// once it is exceeded.
+func runShell(shell, cmd string, timeout time.Duration, cred *credential) ([]byte, error) {
+	var output bytes.Buffer
+	c := shellCommand(shell, cmd)
+	setProcessGroup(c)
+	setCredential(c, cred)
+	if timeout <= 0 {
+		c.Stdout = &output
+		c.Stderr = &output
+		err := c.Run()
+		return output.Bytes(), err
+	}
+	// The output is read from a pipe of our own rather than by Wait, which
+	// would block as long as a process that left the process group keeps
+	// the pipe open, so that it can be closed once the command is killed.
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	c.Stdout = w
+	c.Stderr = w
+	err = c.Start()
+	w.Close()
+	if err != nil {
+		return nil, err
+	}
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(&output, r)
+		close(copied)
+	}()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Wait()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err = <-done:
+	case <-timer.C:
+		killProcessGroup(c)
+		<-done
+		r.Close()
+		<-copied
+		return output.Bytes(), fmt.Errorf("timed out after %s", timeout)
+	}
+	select {
+	case <-copied:
+	case <-timer.C:
+		// The command exited but the processes it started still hold the
+		// output open.
+		killProcessGroup(c)
+		r.Close()
+		<-copied
+	}
+	return output.Bytes(), err
+}
@@ -0,0 +1,146 @@
+//go:build !windows
+// +build !windows
+
+package template
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// defaultShell runs check_cmd and reload_cmd when shell is unset.
+const defaultShell = "/bin/sh"
+
+// setProcessGroup runs c in its own process group, so that the commands it
+// starts can be killed with it.
+func setProcessGroup(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// setCredential runs c as cred unless nil. It must be called after
+// setProcessGroup.
+func setCredential(c *exec.Cmd, cred *credential) {
+	if cred == nil {
+		return
+	}
+	c.SysProcAttr.Credential = &syscall.Credential{Uid: cred.uid, Gid: cred.gid, Groups: cred.groups}
+}
+
+// lookupCredential returns the credential of the user and group named, or
+// with the IDs, username and groupname. The group defaults to the primary
+// group of the user, and the supplementary groups are those of the user. It
+// returns nil when both are empty.
+func lookupCredential(username, groupname string) (*credential, error) {
+	if username == "" && groupname == "" {
+		return nil, nil
+	}
+	cred := &credential{uid: uint32(syscall.Geteuid()), gid: uint32(syscall.Getegid())}
+	if username != "" {
+		u, err := lookupUser(username)
+		if err != nil {
+			return nil, err
+		}
+		if cred.uid, err = parseID(u.Uid); err != nil {
+			return nil, err
+		}
+		if cred.gid, err = parseID(u.Gid); err != nil {
+			return nil, err
+		}
+		gids, err := u.GroupIds()
+		if err != nil {
+			return nil, fmt.Errorf("cannot list the groups of cmd_user %s: %s", username, err.Error())
+		}
+		for _, id := range gids {
+			gid, err := parseID(id)
+			if err != nil {
+				return nil, err
+			}
+			cred.groups = append(cred.groups, gid)
+		}
+	}
+	if groupname != "" {
+		g, err := lookupGroup(groupname)
+		if err != nil {
+			return nil, err
+		}
+		if cred.gid, err = parseID(g.Gid); err != nil {
+			return nil, err
+		}
+		if username == "" {
+			cred.groups = []uint32{cred.gid}
+		}
+	}
+	return cred, nil
+}
+
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		if u, err := user.LookupId(name); err == nil {
+			return u, nil
+		}
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown cmd_user %s", name)
+	}
+	return u, nil
+}
+
+func lookupGroup(name string) (*user.Group, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		if g, err := user.LookupGroupId(name); err == nil {
+			return g, nil
+		}
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown cmd_group %s", name)
+	}
+	return g, nil
+}
+
+// parseID parses a user or group ID.
+func parseID(id string) (uint32, error) {
+	n, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid user or group ID %s", id)
+	}
+	return uint32(n), nil
+}
+
+// signals are the signals reload_signal may name.
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// parseSignal returns the signal named name, e.g. "SIGHUP" or "HUP".
+func parseSignal(name string) (syscall.Signal, error) {
+	sig, ok := signals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return 0, errors.New("unknown signal " + name)
+	}
+	return sig, nil
+}
+
+// signalProcess sends sig to the process pid.
+func signalProcess(pid int, sig syscall.Signal) error {
+	return syscall.Kill(pid, sig)
+}
+
+// killProcessGroup kills the process group of c.
+func killProcessGroup(c *exec.Cmd) {
+	if c.Process == nil {
+		return
+	}
+	syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+}
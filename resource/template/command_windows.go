@@ -0,0 +1,48 @@
+package template
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// defaultShell runs check_cmd and reload_cmd when shell is unset.
+const defaultShell = "cmd"
+
+var errSignalUnsupported = errors.New("reload_signal is not supported on Windows")
+
+// parseSignal fails: Windows processes cannot be sent signals.
+func parseSignal(name string) (syscall.Signal, error) {
+	return 0, errSignalUnsupported
+}
+
+// signalProcess fails: Windows processes cannot be sent signals.
+func signalProcess(pid int, sig syscall.Signal) error {
+	return errSignalUnsupported
+}
+
+// setProcessGroup is a no-op on Windows.
+func setProcessGroup(c *exec.Cmd) {}
+
+// setCredential is a no-op on Windows, where lookupCredential fails.
+func setCredential(c *exec.Cmd, cred *credential) {}
+
+// lookupCredential fails unless username and groupname are empty: Windows
+// commands cannot be run as another user.
+func lookupCredential(username, groupname string) (*credential, error) {
+	if username != "" || groupname != "" {
+		return nil, errors.New("cmd_user and cmd_group are not supported on Windows")
+	}
+	return nil, nil
+}
+
+// killProcessGroup kills the process of c and the processes it started.
+func killProcessGroup(c *exec.Cmd) {
+	if c.Process == nil {
+		return
+	}
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(c.Process.Pid)).Run(); err != nil {
+		c.Process.Kill()
+	}
+}
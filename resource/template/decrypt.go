@@ -0,0 +1,247 @@
+package template
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	agearmor "filippo.io/age/armor"
+	"github.com/kelseyhightower/confd/redact"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+// DecryptConfig locates the key material of the decrypt template functions.
+// Inline keys take precedence over key files.
+type DecryptConfig struct {
+	// AESKey is a base64 encoded 16, 24 or 32 byte key, AESKeyFile a file
+	// holding one.
+	AESKey     string
+	AESKeyFile string
+	// AgeIdentity holds age identities, AgeIdentityFile is a file of them.
+	AgeIdentity     string
+	AgeIdentityFile string
+	// GPGKeyringFile is a binary or armored secret keyring, whose keys are
+	// unlocked with GPGPassphrase.
+	GPGKeyringFile string
+	GPGPassphrase  string
+}
+
+// A Decrypter decrypts values for the decryptAES, decryptAge and
+// decryptGPG template functions, so that secrets are only in plaintext at
+// render time.
+type Decrypter struct {
+	aesKey        []byte
+	ageIdentities []age.Identity
+	gpgKeyring    openpgp.EntityList
+}
+
+// NewDecrypter loads the keys described by config.
+func NewDecrypter(config DecryptConfig) (*Decrypter, error) {
+	d := &Decrypter{}
+
+	aesKey := config.AESKey
+	if aesKey == "" && config.AESKeyFile != "" {
+		b, err := ioutil.ReadFile(config.AESKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		aesKey = string(b)
+	}
+	if aesKey != "" {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(aesKey))
+		if err != nil {
+			return nil, errors.New("invalid AES key: " + err.Error())
+		}
+		if _, err := aes.NewCipher(key); err != nil {
+			return nil, err
+		}
+		d.aesKey = key
+	}
+
+	var ageIdentity io.Reader
+	if config.AgeIdentity != "" {
+		ageIdentity = strings.NewReader(config.AgeIdentity)
+	} else if config.AgeIdentityFile != "" {
+		f, err := os.Open(config.AgeIdentityFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		ageIdentity = f
+	}
+	if ageIdentity != nil {
+		identities, err := age.ParseIdentities(ageIdentity)
+		if err != nil {
+			return nil, err
+		}
+		d.ageIdentities = identities
+	}
+
+	if config.GPGKeyringFile != "" {
+		keyring, err := readKeyring(config.GPGKeyringFile, config.GPGPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		d.gpgKeyring = keyring
+	}
+	return d, nil
+}
+
+// readKeyring reads the keyring at path and unlocks its private keys with
+// passphrase.
+func readKeyring(path, passphrase string) (openpgp.EntityList, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keyring openpgp.EntityList
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("-----BEGIN")) {
+		keyring, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+	} else {
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(b))
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entity := range keyring {
+		if entity.PrivateKey != nil && entity.PrivateKey.Encrypted {
+			if err := entity.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+				return nil, err
+			}
+		}
+		for _, subkey := range entity.Subkeys {
+			if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+				if err := subkey.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return keyring, nil
+}
+
+// funcMap returns the decrypt template functions.
+func (d *Decrypter) funcMap() map[string]interface{} {
+	return map[string]interface{}{
+		"decryptAES": d.DecryptAES,
+		"decryptAge": d.DecryptAge,
+		"decryptGPG": d.DecryptGPG,
+	}
+}
+
+// observeDecrypted wraps the decrypt functions of funcs so that the values
+// they return are masked like those of sensitive keys.
+func (t *TemplateResource) observeDecrypted(funcs map[string]interface{}) map[string]interface{} {
+	wrapped := make(map[string]interface{}, len(funcs))
+	for name, fn := range funcs {
+		decrypt := fn.(func(string) (string, error))
+		wrapped[name] = func(data string) (string, error) {
+			v, err := decrypt(data)
+			if err == nil {
+				t.decrypted = append(t.decrypted, v)
+				t.observeDecryptedValues()
+			}
+			return v, err
+		}
+	}
+	return wrapped
+}
+
+// observeDecryptedValues makes the values decrypted by the current pass the
+// only decrypted values of t that are masked, so that those of rotated
+// secrets are forgotten.
+func (t *TemplateResource) observeDecryptedValues() {
+	redact.ObserveValues("decrypted by "+t.Name, t.decrypted)
+}
+
+// DecryptAES decrypts data, the base64 encoding of an AES-GCM nonce
+// followed by the sealed value.
+func (d *Decrypter) DecryptAES(data string) (string, error) {
+	if d.aesKey == nil {
+		return "", errors.New("decryptAES: no AES key configured")
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(d.aesKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(b) < gcm.NonceSize() {
+		return "", errors.New("decryptAES: value too short")
+	}
+	plaintext, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// DecryptAge decrypts data, an armored or base64 encoded age file.
+func (d *Decrypter) DecryptAge(data string) (string, error) {
+	if len(d.ageIdentities) == 0 {
+		return "", errors.New("decryptAge: no age identity configured")
+	}
+	var src io.Reader
+	if strings.HasPrefix(strings.TrimSpace(data), "-----BEGIN") {
+		src = agearmor.NewReader(strings.NewReader(strings.TrimSpace(data)))
+	} else {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+		if err != nil {
+			return "", err
+		}
+		src = bytes.NewReader(b)
+	}
+	r, err := age.Decrypt(src, d.ageIdentities...)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// DecryptGPG decrypts data, an armored or base64 encoded OpenPGP message.
+func (d *Decrypter) DecryptGPG(data string) (string, error) {
+	if len(d.gpgKeyring) == 0 {
+		return "", errors.New("decryptGPG: no GPG keyring configured")
+	}
+	var src io.Reader
+	if strings.HasPrefix(strings.TrimSpace(data), "-----BEGIN") {
+		block, err := armor.Decode(strings.NewReader(strings.TrimSpace(data)))
+		if err != nil {
+			return "", err
+		}
+		src = block.Body
+	} else {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+		if err != nil {
+			return "", err
+		}
+		src = bytes.NewReader(b)
+	}
+	md, err := openpgp.ReadMessage(src, d.gpgKeyring, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := ioutil.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
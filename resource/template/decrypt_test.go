@@ -0,0 +1,71 @@
+package template
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kelseyhightower/confd/redact"
+)
+
+// sealAES encrypts plaintext like the values decryptAES expects.
+func sealAES(t *testing.T, key []byte, plaintext string) string {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err.Error())
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plaintext), nil))
+}
+
+func TestDecryptedValuesMasked(t *testing.T) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err.Error())
+	}
+	d, err := NewDecrypter(DecryptConfig{AESKey: base64.StdEncoding.EncodeToString(key)})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	dir, err := ioutil.TempDir("", "decrypt")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	tr := newTestResource(t, dir, `
+[template]
+src = "test.tmpl"
+dest = "dest.conf"
+`)
+	defer redact.ObserveValues("decrypted by "+tr.Name, nil)
+	decrypt := tr.observeDecrypted(d.funcMap())["decryptAES"].(func(string) (string, error))
+
+	v, err := decrypt(sealAES(t, key, "hunter22"))
+	if err != nil || v != "hunter22" {
+		t.Fatalf("decryptAES() = %q, %v, want hunter22", v, err)
+	}
+	if got := redact.Text("password hunter22"); got != "password "+redact.Mask {
+		t.Errorf("Expected the decrypted value to be masked, got %q", got)
+	}
+
+	// The next pass forgets the values it no longer decrypts.
+	tr.decrypted = nil
+	if _, err := decrypt(sealAES(t, key, "correcthorse")); err != nil {
+		t.Fatal(err.Error())
+	}
+	tr.observeDecryptedValues()
+	if got, want := redact.Text("hunter22 correcthorse"), "hunter22 "+redact.Mask; got != want {
+		t.Errorf("Text = %q, want %q", got, want)
+	}
+}
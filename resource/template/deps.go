@@ -0,0 +1,130 @@
+package template
+
+import (
+	"path"
+	"strings"
+
+	"github.com/kelseyhightower/memkv"
+)
+
+// keyReads records the keys a template read while it was rendered.
+type keyReads struct {
+	keys     map[string]bool
+	patterns []string
+	dirs     []string
+	// items is the items pattern of a resource with dest_pattern, every
+	// key below which counts as read.
+	items string
+}
+
+func newKeyReads() *keyReads {
+	return &keyReads{keys: make(map[string]bool)}
+}
+
+// matches reports whether the template read key, directly, through a
+// pattern of gets or getvs, or by listing a parent with ls, lsdir or
+// healthyNodes, or whether key is below an item.
+func (r *keyReads) matches(key string) bool {
+	if r.keys[key] || r.items != "" && underItem(r.items, key) {
+		return true
+	}
+	for _, p := range r.patterns {
+		if ok, _ := path.Match(p, key); ok {
+			return true
+		}
+	}
+	for _, d := range r.dirs {
+		if d == "/" || key == d || strings.HasPrefix(key, d+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// trackReads replaces the store functions of the template with ones
+// recording the keys read into t.reading.
+func (t *TemplateResource) trackReads() {
+	s := &t.store
+	t.funcMap["exists"] = func(key string) bool {
+		t.reading.keys[key] = true
+		return s.Exists(key)
+	}
+	t.funcMap["get"] = func(key string) (memkv.KVPair, error) {
+		t.reading.keys[key] = true
+		return s.Get(key)
+	}
+	t.funcMap["getv"] = func(key string, v ...string) (string, error) {
+		t.reading.keys[key] = true
+		return s.GetValue(key, v...)
+	}
+	t.funcMap["getvBytes"] = func(key string) (string, error) {
+		t.reading.keys[key] = true
+		v, err := s.GetValue(key)
+		if err != nil {
+			return "", err
+		}
+		return Base64Decode(v)
+	}
+	t.funcMap["gets"] = func(pattern string) (memkv.KVPairs, error) {
+		t.reading.patterns = append(t.reading.patterns, pattern)
+		return s.GetAll(pattern)
+	}
+	t.funcMap["getvs"] = func(pattern string) ([]string, error) {
+		t.reading.patterns = append(t.reading.patterns, pattern)
+		return s.GetAllValues(pattern)
+	}
+	t.funcMap["ls"] = func(filePath string) []string {
+		t.reading.dirs = append(t.reading.dirs, path.Clean(filePath))
+		return s.List(filePath)
+	}
+	t.funcMap["lsdir"] = func(filePath string) []string {
+		t.reading.dirs = append(t.reading.dirs, path.Clean(filePath))
+		return s.ListDir(filePath)
+	}
+	t.funcMap["healthyNodes"] = func(dir string, healthKey ...string) []string {
+		t.reading.dirs = append(t.reading.dirs, path.Clean(dir))
+		return healthyNodes(s, dir, healthKey...)
+	}
+}
+
+// changedKeys returns the keys whose value differs between previous and
+// current, including the keys only one of them holds.
+func changedKeys(previous, current map[string]string) []string {
+	var changed []string
+	for k, v := range current {
+		if pv, ok := previous[k]; !ok || pv != v {
+			changed = append(changed, k)
+		}
+	}
+	for k := range previous {
+		if _, ok := current[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+	return changed
+}
+
+// affected reports whether a change from previous to the current values
+// may change the rendered template, judging by the keys read during the
+// last render. Without a successful render to judge by it is always true.
+func (t *TemplateResource) affected(previous map[string]string) bool {
+	if t.reads == nil || previous == nil {
+		return true
+	}
+	for _, k := range changedKeys(previous, t.vars) {
+		if t.reads.matches(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateReads keeps the keys read by a successful process to judge the next
+// watch event by. Formats and remote templates are not tracked.
+func (t *TemplateResource) updateReads(err error) {
+	if err != nil || t.Src == "" || isRemoteSrc(t.Src) {
+		t.reads = nil
+		return
+	}
+	t.reads = t.reading
+}
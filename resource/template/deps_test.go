@@ -0,0 +1,102 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kelseyhightower/confd/log"
+)
+
+func TestKeyReadsMatches(t *testing.T) {
+	reads := &keyReads{
+		keys:     map[string]bool{"/app/name": true},
+		patterns: []string{"/app/upstream/*"},
+		dirs:     []string{"/app/hosts"},
+	}
+	tests := []struct {
+		key      string
+		expected bool
+	}{
+		{"/app/name", true},
+		{"/app/names", false},
+		{"/app/upstream/a", true},
+		{"/app/upstream/a/b", false},
+		{"/app/upstream", false},
+		{"/app/hosts", true},
+		{"/app/hosts/a", true},
+		{"/app/hosts/a/b", true},
+		{"/app/hostsx", false},
+		{"/app", false},
+		{"/other", false},
+	}
+	for _, tt := range tests {
+		if got := reads.matches(tt.key); got != tt.expected {
+			t.Errorf("matches(%q) = %v, want %v", tt.key, got, tt.expected)
+		}
+	}
+	root := &keyReads{keys: map[string]bool{}, dirs: []string{"/"}}
+	if !root.matches("/any/key") {
+		t.Error("Expected ls of / to match every key")
+	}
+	items := &keyReads{keys: map[string]bool{}, items: "/app/sites/*"}
+	if !items.matches("/app/sites/new/name") {
+		t.Error("Expected a key below an item to match")
+	}
+	if items.matches("/app/sites") {
+		t.Error("Expected the parent of the items not to match")
+	}
+}
+
+func TestAffectedNewItem(t *testing.T) {
+	log.SetLevel("warn")
+	dir, err := ioutil.TempDir("", "deps")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("DEPSITEMS_A_NAME", "a")
+	defer os.Unsetenv("DEPSITEMS_A_NAME")
+	src := filepath.Join(dir, "test.tmpl")
+	if err := ioutil.WriteFile(src, []byte(`name = {{getv (printf "%s/name" .Key)}}`), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	tr := newTestResource(t, dir, `
+[template]
+src = "test.tmpl"
+dest_pattern = "`+filepath.Join(dir, "{{.Name}}.conf")+`"
+items = "/depsitems/*"
+keys = ["/depsitems"]
+`)
+	tr.Dest = ""
+	tr.Src = src
+	tr.trackDeps = true
+	defer tr.setItemDests(nil)
+	if err := tr.process(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := readFile(t, filepath.Join(dir, "a.conf")); got != "name = a" {
+		t.Errorf("Expected a.conf name = a, got %s", got)
+	}
+
+	previous := tr.vars
+	tr.vars = map[string]string{"/depsitems/a/name": "a", "/depsitems/b/name": "b"}
+	if !tr.affected(previous) {
+		t.Error("Expected a new item to affect the resource")
+	}
+	tr.vars = map[string]string{"/depsitems/a/name": "a", "/other": "x"}
+	if tr.affected(previous) {
+		t.Error("Expected a key outside the items not to affect the resource")
+	}
+
+	os.Setenv("DEPSITEMS_B_NAME", "b")
+	defer os.Unsetenv("DEPSITEMS_B_NAME")
+	tr.vars = previous
+	if err := tr.process(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := readFile(t, filepath.Join(dir, "b.conf")); got != "name = b" {
+		t.Errorf("Expected the new item b.conf name = b, got %s", got)
+	}
+}
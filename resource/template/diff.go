@@ -0,0 +1,122 @@
+package template
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/kelseyhightower/confd/notify"
+	"github.com/kelseyhightower/confd/redact"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// DryRunResult is the outcome of rendering a template resource without
+// touching its destination.
+type DryRunResult struct {
+	Name    string `json:"name"`
+	Dest    string `json:"dest"`
+	Changed bool   `json:"changed"`
+	Diff    string `json:"diff"`
+}
+
+// noopOutput is where noop mode prints the diffs of out of sync resources.
+var noopOutput io.Writer = os.Stdout
+
+// unifiedDiff returns a unified diff turning from into to, with the values
+// of sensitive keys masked.
+func unifiedDiff(fromName string, from []byte, toName string, to []byte) (string, error) {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(from)),
+		B:        difflib.SplitLines(string(to)),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  3,
+	})
+	return redact.Text(diff), err
+}
+
+// readDest returns the current contents of the destination, which is empty
+// when it does not exist yet.
+func (t *TemplateResource) readDest() ([]byte, error) {
+	current, err := ioutil.ReadFile(t.Dest)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return current, nil
+}
+
+// Render renders the template resource against the current backend values
+// and returns the result. Nothing is written and no command is run. A
+// resource with dest_pattern renders its first item.
+func (t *TemplateResource) Render() ([]byte, error) {
+	if err := t.setVars(); err != nil {
+		return nil, err
+	}
+	if err := t.sampleItem(); err != nil {
+		return nil, err
+	}
+	return t.render()
+}
+
+// DryRun renders the template resource against the current backend values
+// and diffs the result against the destination file. Nothing is written and
+// no command is run.
+func (t *TemplateResource) DryRun() (*DryRunResult, error) {
+	rendered, err := t.Render()
+	if err != nil {
+		return nil, err
+	}
+	current, err := t.readDest()
+	if err != nil {
+		return nil, err
+	}
+	diff, err := t.diff(current, rendered)
+	if err != nil {
+		return nil, err
+	}
+	t.logger().Debug("Dry run done")
+	return &DryRunResult{
+		Name:    t.Name,
+		Dest:    t.Dest,
+		Changed: diff != "",
+		Diff:    diff,
+	}, nil
+}
+
+// printNoopDiff prints the diff turning the destination into the staged
+// file, for noop mode.
+func (t *TemplateResource) printNoopDiff(staged string) error {
+	rendered, err := ioutil.ReadFile(staged)
+	if err != nil {
+		return err
+	}
+	current, err := t.readDest()
+	if err != nil {
+		return err
+	}
+	diff, err := t.diff(current, rendered)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(noopOutput, diff)
+	return err
+}
+
+// diffSummary counts the lines added and removed turning from into to.
+func diffSummary(from, to []byte) notify.DiffSummary {
+	var s notify.DiffSummary
+	m := difflib.NewMatcher(difflib.SplitLines(string(from)), difflib.SplitLines(string(to)))
+	for _, op := range m.GetOpCodes() {
+		switch op.Tag {
+		case 'r':
+			s.Removed += op.I2 - op.I1
+			s.Added += op.J2 - op.J1
+		case 'd':
+			s.Removed += op.I2 - op.I1
+		case 'i':
+			s.Added += op.J2 - op.J1
+		}
+	}
+	return s
+}
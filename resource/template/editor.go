@@ -0,0 +1,190 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"text/template"
+
+	"github.com/flosch/pongo2"
+	"github.com/kelseyhightower/confd/redact"
+)
+
+// PreviewResult is the outcome of rendering an edited src template without
+// touching the destination.
+type PreviewResult struct {
+	Name     string `json:"name"`
+	Dest     string `json:"dest"`
+	Rendered string `json:"rendered"`
+	Changed  bool   `json:"changed"`
+	Diff     string `json:"diff"`
+}
+
+// SourceError is an error parsing or executing an edited src template. Line
+// is the line it occurred at, or 0 when unknown.
+type SourceError struct {
+	Line int
+	Err  error
+}
+
+func (e *SourceError) Error() string {
+	return e.Err.Error()
+}
+
+// sourceErrorLine matches the line number in the errors of text/template,
+// such as `template: nginx.conf.tmpl:12: unexpected "}" in operand`.
+var sourceErrorLine = regexp.MustCompile(`^template: [^:]*:(\d+)`)
+
+func newSourceError(err error) *SourceError {
+	e := &SourceError{Err: err}
+	if perr, ok := err.(*pongo2.Error); ok {
+		e.Line = perr.Line
+	} else if m := sourceErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		e.Line, _ = strconv.Atoi(m[1])
+	}
+	return e
+}
+
+// Source returns the src template of t, read from its file or backend key.
+func (t *TemplateResource) Source() (string, error) {
+	if t.Src == "" {
+		return "", ErrEmptySrc
+	}
+	if isRemoteSrc(t.Src) {
+		return t.fetchRemoteSrc()
+	}
+	b, err := ioutil.ReadFile(t.Src)
+	return string(b), err
+}
+
+// Preview renders source in place of the src template of t against the
+// current backend values and diffs the result against the destination.
+// Nothing is written and no command is run. The values of sensitive keys
+// are masked in the result. The environment and files cannot be read from
+// source, see previewFuncMap.
+func (t *TemplateResource) Preview(source string) (*PreviewResult, error) {
+	rendered, err := t.renderSource(source, true)
+	if err != nil {
+		return nil, err
+	}
+	current, err := t.readDest()
+	if err != nil {
+		return nil, err
+	}
+	diff, err := t.diff(current, rendered)
+	if err != nil {
+		return nil, err
+	}
+	return &PreviewResult{
+		Name:     t.Name,
+		Dest:     t.Dest,
+		Rendered: redact.Text(string(rendered)),
+		Changed:  diff != "",
+		Diff:     diff,
+	}, nil
+}
+
+// SaveSource replaces the src template of t with source, once it rendered
+// against the current backend values. A src file is replaced atomically,
+// keeping its mode; a remote src is set in its backend key.
+func (t *TemplateResource) SaveSource(source string) error {
+	if _, err := t.renderSource(source, false); err != nil {
+		return err
+	}
+	if isRemoteSrc(t.Src) {
+		return t.storeClient.Set(t.remoteSrcKey(), source)
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(t.Src); err == nil {
+		mode = info.Mode()
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(t.Src), "."+filepath.Base(t.Src))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	_, err = temp.WriteString(source)
+	if cerr := temp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), mode)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), t.Src)
+}
+
+// renderSource renders source as the src template of t, with its partials,
+// against the current backend values. A sandboxed render goes without the
+// functions reading the environment and files.
+func (t *TemplateResource) renderSource(source string, sandboxed bool) ([]byte, error) {
+	if t.Src == "" {
+		return nil, ErrEmptySrc
+	}
+	if err := t.setVars(); err != nil {
+		return nil, err
+	}
+	if err := t.sampleItem(); err != nil {
+		return nil, err
+	}
+	t.reading = newKeyReads()
+	if t.Engine == enginePongo2 {
+		b, err := t.renderPongo2(source, sandboxed)
+		if err != nil {
+			return nil, newSourceError(err)
+		}
+		return b, nil
+	}
+	name := path.Base(t.Src)
+	if isRemoteSrc(t.Src) {
+		name = path.Base(t.remoteSrcKey())
+	}
+	funcMap := t.funcMap
+	if sandboxed {
+		funcMap = t.previewFuncMap()
+	}
+	tmpl, err := template.New(name).Funcs(funcMap).Parse(source)
+	if err != nil {
+		return nil, newSourceError(err)
+	}
+	if tmpl, err = t.addPartials(tmpl); err != nil {
+		return nil, err
+	}
+	var data interface{}
+	if t.item != nil {
+		data = t.item
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, newSourceError(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// previewFuncs are the template functions reading the environment or files,
+// which fail in previews: their source is posted to the admin server, not
+// written by whoever deployed confd.
+var previewFuncs = []string{"getenv", "fileExists", "env", "expandenv"}
+
+// previewFuncMap returns the template functions of t for previews, the
+// previewFuncs failing.
+func (t *TemplateResource) previewFuncMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(t.funcMap))
+	for name, fn := range t.funcMap {
+		m[name] = fn
+	}
+	for _, name := range previewFuncs {
+		name := name
+		m[name] = func(...interface{}) (string, error) {
+			return "", fmt.Errorf("%s is not available in previews", name)
+		}
+	}
+	return m
+}
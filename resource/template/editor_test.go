@@ -0,0 +1,84 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kelseyhightower/confd/log"
+)
+
+func TestPreviewSandboxed(t *testing.T) {
+	log.SetLevel("warn")
+	dir, err := ioutil.TempDir("", "preview")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	templates := filepath.Join(dir, "templates")
+	if err := os.MkdirAll(templates, 0755); err != nil {
+		t.Fatal(err.Error())
+	}
+	secret := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(secret, []byte("s3cret"), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := ioutil.WriteFile(filepath.Join(templates, "partial.j2"), []byte("partial"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	os.Setenv("PREVIEW_SECRET", "s3cret")
+	defer os.Unsetenv("PREVIEW_SECRET")
+
+	newResource := func(engine string) *TemplateResource {
+		tr := newTestResource(t, dir, `
+[template]
+src = "test.tmpl"
+dest = "dest.conf"
+keys = ["/preview"]
+engine = "`+engine+`"
+`)
+		tr.Src = filepath.Join(templates, "test.tmpl")
+		return tr
+	}
+
+	refused := map[string][]string{
+		engineGo: {
+			`{{getenv "PREVIEW_SECRET"}}`,
+			`{{env "PREVIEW_SECRET"}}`,
+			`{{expandenv "$PREVIEW_SECRET"}}`,
+			`{{fileExists "` + secret + `"}}`,
+		},
+		enginePongo2: {
+			`{{ getenv("PREVIEW_SECRET") }}`,
+			`{% ssi "` + secret + `" %}`,
+			`{% include "` + secret + `" %}`,
+			`{% include "../secret" %}`,
+		},
+	}
+	for engine, sources := range refused {
+		tr := newResource(engine)
+		for _, source := range sources {
+			result, err := tr.Preview(source)
+			if err == nil {
+				t.Errorf("%s: expected the preview of %s to fail, got %q", engine, source, result.Rendered)
+			} else if strings.Contains(err.Error(), "s3cret") {
+				t.Errorf("%s: the error of %s reveals the secret: %s", engine, source, err)
+			}
+		}
+	}
+
+	tr := newResource(enginePongo2)
+	result, err := tr.Preview(`{% include "partial.j2" %}`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if result.Rendered != "partial" {
+		t.Errorf("Expected the partial in the templates directory, got %q", result.Rendered)
+	}
+	// Saving validates with every function, as the resource renders with them.
+	if _, err := tr.renderSource(`{{ getenv("PREVIEW_SECRET") }}`, false); err != nil {
+		t.Errorf("Expected getenv outside of previews, got %s", err)
+	}
+}
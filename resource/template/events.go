@@ -0,0 +1,223 @@
+package template
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kelseyhightower/confd/audit"
+)
+
+// defaultEventHistory is the number of change events kept unless
+// SetEventHistory is called.
+const defaultEventHistory = 200
+
+// subscriberBuffer is the number of events a subscriber may lag behind
+// before new ones are dropped for it.
+const subscriberBuffer = 64
+
+// ChangeEvent is a change of a backend key, seen by the passes of template
+// resources.
+type ChangeEvent struct {
+	// ID increases with each event, so that a client can resume after the
+	// last one it received.
+	ID   uint64    `json:"id"`
+	Time time.Time `json:"timestamp"`
+	Key  string    `json:"key"`
+	// OldChecksum and NewChecksum are the SHA-256 checksums of the value,
+	// empty when the key was created or removed.
+	OldChecksum string `json:"old_checksum,omitempty"`
+	NewChecksum string `json:"new_checksum,omitempty"`
+	// Resources are the template resources whose pass saw the change, and
+	// Updated those whose dest was rewritten by that pass, reload included.
+	Resources []string `json:"resources"`
+	Updated   []string `json:"updated"`
+}
+
+// EventFilter selects change events.
+type EventFilter struct {
+	// Key is a key prefix and Resource the name of a template resource.
+	Key      string
+	Resource string
+	// Limit, when positive, keeps only the latest Limit events.
+	Limit int
+}
+
+// keyChange is a change seen by a pass, recorded once the pass is over.
+type keyChange struct {
+	key, old, new string
+}
+
+var (
+	eventsMu     sync.Mutex
+	eventHistory = defaultEventHistory
+	events       []ChangeEvent
+	lastEventID  uint64
+	subscribers  = make(map[chan ChangeEvent]bool)
+	// keyChecksums holds, by resource name, the checksums of the values
+	// seen by the last pass of the resource.
+	keyChecksums = make(map[string]map[string]string)
+)
+
+// SetEventHistory sets the number of change events kept. 0 stops recording
+// them.
+func SetEventHistory(n int) {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	eventHistory = n
+	if n <= 0 {
+		events = nil
+		keyChecksums = make(map[string]map[string]string)
+	} else if len(events) > n {
+		events = append([]ChangeEvent(nil), events[len(events)-n:]...)
+	}
+}
+
+// keyChanges returns the backend keys whose values changed since the last
+// pass over t, and remembers the current values for the next one. Nothing
+// changed for the first pass.
+func (t *TemplateResource) keyChanges() []keyChange {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	if eventHistory <= 0 {
+		return nil
+	}
+	checksums := make(map[string]string, len(t.vars))
+	for k, v := range t.vars {
+		checksums[path.Join(t.Prefix, k)] = audit.Checksum([]byte(v), true)
+	}
+	previous, ok := keyChecksums[t.Name]
+	keyChecksums[t.Name] = checksums
+	if !ok {
+		return nil
+	}
+	var changes []keyChange
+	for k, sum := range checksums {
+		if old := previous[k]; old != sum {
+			changes = append(changes, keyChange{key: k, old: old, new: sum})
+		}
+	}
+	for k, old := range previous {
+		if _, ok := checksums[k]; !ok {
+			changes = append(changes, keyChange{key: k, old: old})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].key < changes[j].key })
+	return changes
+}
+
+// recordChanges records the changes seen by the pass over t, adding t to
+// the latest event of a key that changed to the same value, as when
+// several resources read it.
+func (t *TemplateResource) recordChanges(changes []keyChange) {
+	if len(changes) == 0 {
+		return
+	}
+	now := time.Now()
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	if eventHistory <= 0 {
+		return
+	}
+	for _, c := range changes {
+		i := latestEvent(c.key)
+		if i < 0 || events[i].NewChecksum != c.new || contains(events[i].Resources, t.Name) {
+			lastEventID++
+			events = append(events, ChangeEvent{
+				ID:          lastEventID,
+				Time:        now,
+				Key:         c.key,
+				OldChecksum: c.old,
+				NewChecksum: c.new,
+				Resources:   []string{},
+				Updated:     []string{},
+			})
+			i = len(events) - 1
+		}
+		e := &events[i]
+		e.Resources = append(e.Resources, t.Name)
+		if t.updated {
+			e.Updated = append(e.Updated, t.Name)
+		}
+		publish(e.clone())
+	}
+	if len(events) > eventHistory {
+		events = append([]ChangeEvent(nil), events[len(events)-eventHistory:]...)
+	}
+}
+
+// latestEvent returns the index of the latest event of key, or -1.
+func latestEvent(key string) int {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// clone returns a copy of e not sharing its lists.
+func (e *ChangeEvent) clone() ChangeEvent {
+	c := *e
+	c.Resources = append([]string{}, e.Resources...)
+	c.Updated = append([]string{}, e.Updated...)
+	return c
+}
+
+// publish sends e to the subscribers, dropping it for those lagging behind.
+func publish(e ChangeEvent) {
+	for ch := range subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// ChangeEvents returns the recorded change events selected by filter,
+// oldest first.
+func ChangeEvents(filter EventFilter) []ChangeEvent {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	result := make([]ChangeEvent, 0)
+	for i := range events {
+		e := &events[i]
+		if !strings.HasPrefix(e.Key, filter.Key) {
+			continue
+		}
+		if filter.Resource != "" && !contains(e.Resources, filter.Resource) {
+			continue
+		}
+		result = append(result, e.clone())
+	}
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[len(result)-filter.Limit:]
+	}
+	return result
+}
+
+// SubscribeEvents returns a channel receiving the change events recorded
+// from now on, and a function to call once done with it. An event is sent
+// again, with the same ID, when another resource is added to it.
+func SubscribeEvents() (<-chan ChangeEvent, func()) {
+	ch := make(chan ChangeEvent, subscriberBuffer)
+	eventsMu.Lock()
+	subscribers[ch] = true
+	eventsMu.Unlock()
+	return ch, func() {
+		eventsMu.Lock()
+		delete(subscribers, ch)
+		eventsMu.Unlock()
+	}
+}
@@ -0,0 +1,130 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// expandValues adds, for every value of vars holding a JSON or YAML object or
+// array, a pseudo-key per nested value below the key of the value, e.g.
+// /myapp/config/db/host for {"db": {"host": ...}} stored at /myapp/config.
+// Existing keys are never overwritten.
+func expandValues(vars map[string]string) {
+	for k, v := range vars {
+		doc, ok := parseStructured(v)
+		if !ok {
+			continue
+		}
+		flat := make(map[string]string)
+		flatten(k, doc, flat)
+		for fk, fv := range flat {
+			if _, exists := vars[fk]; !exists {
+				vars[fk] = fv
+			}
+		}
+	}
+}
+
+// parseStructured parses v as a JSON or YAML document and reports whether it
+// is an object or an array.
+func parseStructured(v string) (interface{}, bool) {
+	s := strings.TrimSpace(v)
+	if s == "" {
+		return nil, false
+	}
+	var doc interface{}
+	if s[0] == '{' || s[0] == '[' {
+		if err := json.Unmarshal([]byte(s), &doc); err == nil {
+			return doc, true
+		}
+	}
+	if err := yaml.Unmarshal([]byte(s), &doc); err != nil {
+		return nil, false
+	}
+	switch doc.(type) {
+	case map[interface{}]interface{}, []interface{}:
+		return doc, true
+	}
+	return nil, false
+}
+
+// flatten stores the scalar values of doc in out, keyed by their path below
+// prefix.
+func flatten(prefix string, doc interface{}, out map[string]string) {
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		for k, v := range d {
+			flatten(path.Join(prefix, k), v, out)
+		}
+	case map[interface{}]interface{}:
+		for k, v := range d {
+			flatten(path.Join(prefix, fmt.Sprint(k)), v, out)
+		}
+	case []interface{}:
+		for i, v := range d {
+			flatten(path.Join(prefix, strconv.Itoa(i)), v, out)
+		}
+	case nil:
+		out[prefix] = ""
+	default:
+		out[prefix] = fmt.Sprint(d)
+	}
+}
+
+// lookupPath returns the value at p, a slash separated path of object keys
+// and array indexes, in doc.
+func lookupPath(doc interface{}, p string) (interface{}, error) {
+	for _, part := range strings.Split(strings.Trim(p, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		switch d := doc.(type) {
+		case map[string]interface{}:
+			v, ok := d[part]
+			if !ok {
+				return nil, fmt.Errorf("key not found: %s", p)
+			}
+			doc = v
+		case map[interface{}]interface{}:
+			v, ok := d[part]
+			if !ok {
+				return nil, fmt.Errorf("key not found: %s", p)
+			}
+			doc = v
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(d) {
+				return nil, fmt.Errorf("index not found: %s", p)
+			}
+			doc = d[i]
+		default:
+			return nil, fmt.Errorf("key not found: %s", p)
+		}
+	}
+	return doc, nil
+}
+
+// JsonGet returns the value at p, e.g. "/db/host", in the JSON document
+// data.
+func JsonGet(data, p string) (interface{}, error) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		return nil, err
+	}
+	return lookupPath(doc, p)
+}
+
+// YamlGet returns the value at p, e.g. "/db/host", in the YAML document
+// data.
+func YamlGet(data, p string) (interface{}, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		return nil, err
+	}
+	return lookupPath(doc, p)
+}
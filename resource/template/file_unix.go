@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package template
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner returns the uid and gid owning the file described by fi.
+func fileOwner(fi os.FileInfo) (uint32, uint32) {
+	stat := fi.Sys().(*syscall.Stat_t)
+	return stat.Uid, stat.Gid
+}
+
+// chown changes the owner of name, ignoring failures as unprivileged confd
+// may only give files away to itself.
+func chown(name string, uid, gid int) {
+	os.Chown(name, uid, gid)
+}
+
+// renameFile renames src to dst, replacing dst.
+func renameFile(src, dst string) error {
+	return os.Rename(src, dst)
+}
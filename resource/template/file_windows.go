@@ -0,0 +1,51 @@
+package template
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	errorAccessDenied     syscall.Errno = 5
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+
+	renameAttempts = 10
+	renameDelay    = 100 * time.Millisecond
+)
+
+// fileOwner reports files as owned by uid and gid 0, Windows has no Unix
+// owners.
+func fileOwner(fi os.FileInfo) (uint32, uint32) {
+	return 0, 0
+}
+
+// chown is a no-op on Windows.
+func chown(name string, uid, gid int) {}
+
+// renameFile renames src to dst, replacing dst. A dst held open by another
+// process, such as the service reading it, is retried with a growing delay.
+func renameFile(src, dst string) error {
+	var err error
+	for i := 1; i <= renameAttempts; i++ {
+		if err = os.Rename(src, dst); err == nil || !isInUse(err) {
+			return err
+		}
+		time.Sleep(time.Duration(i) * renameDelay)
+	}
+	return err
+}
+
+// isInUse reports whether err is caused by a file being open in another
+// process.
+func isInUse(err error) bool {
+	if le, ok := err.(*os.LinkError); ok {
+		err = le.Err
+	}
+	switch err {
+	case errorAccessDenied, errorSharingViolation, errorLockViolation:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package template
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+var (
+	fingerprintMu sync.Mutex
+	fingerprints  = make(map[string]string)
+)
+
+// fingerprint returns a hash of what a pass over t depends on: its
+// settings, the values read from the backend, the src template and the
+// partials next to it, and dest as found on disk. It is empty when t does
+// not skip unchanged passes. Template functions reading anything else, such
+// as lookupIP or datetime, are not accounted for.
+func (t *TemplateResource) fingerprint() string {
+	if !t.skipUnchanged || isRemoteSrc(t.Src) {
+		return ""
+	}
+	h := sha256.New()
+	h.Write(t.settings)
+	for _, k := range sortedKeys(t.vars) {
+		fmt.Fprintf(h, "%q=%q\n", k, t.vars[k])
+	}
+	if t.Src != "" {
+		writeFileStat(h, t.Src)
+	}
+	if t.templateDir != "" {
+		filepath.Walk(t.templateDir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				writeFileStat(h, path)
+			}
+			return nil
+		})
+	}
+	writeFileStat(h, t.Dest)
+	if t.DestPattern != "" {
+		dests := t.previousItemDests()
+		names := make([]string, 0, len(dests))
+		for dest := range dests {
+			names = append(names, dest)
+		}
+		sort.Strings(names)
+		for _, dest := range names {
+			writeFileStat(h, dest)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeFileStat writes the size, mode and modification time of the file at
+// path to h.
+func writeFileStat(h hash.Hash, path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		fmt.Fprintf(h, "%s missing\n", path)
+		return
+	}
+	fmt.Fprintf(h, "%s %d %s %d\n", path, info.Size(), info.Mode(), info.ModTime().UnixNano())
+}
+
+// unchanged reports whether fingerprint matches the one recorded by the
+// last successful pass over t.
+func (t *TemplateResource) unchanged(fingerprint string) bool {
+	if fingerprint == "" {
+		return false
+	}
+	fingerprintMu.Lock()
+	defer fingerprintMu.Unlock()
+	return fingerprints[t.Name] == fingerprint
+}
+
+// recordFingerprint records the fingerprint of t after a pass, which is
+// forgotten when the pass failed so that the next one runs in full.
+func (t *TemplateResource) recordFingerprint(err error) {
+	if !t.skipUnchanged {
+		return
+	}
+	fingerprint := ""
+	if err == nil {
+		fingerprint = t.fingerprint()
+	}
+	fingerprintMu.Lock()
+	defer fingerprintMu.Unlock()
+	if fingerprint == "" {
+		delete(fingerprints, t.Name)
+		return
+	}
+	fingerprints[t.Name] = fingerprint
+}
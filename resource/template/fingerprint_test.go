@@ -0,0 +1,113 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kelseyhightower/confd/log"
+)
+
+func TestSkipUnchanged(t *testing.T) {
+	log.SetLevel("warn")
+	dir, err := ioutil.TempDir("", "fingerprint")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("FINGERPRINT_KEY", "one")
+	defer os.Unsetenv("FINGERPRINT_KEY")
+	src := filepath.Join(dir, "test.tmpl")
+	if err := ioutil.WriteFile(src, []byte(`{{renders}}key = {{getv "/fingerprint/key"}}`), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	tr := newTestResource(t, dir, `
+[template]
+src = "test.tmpl"
+dest = "dest.conf"
+keys = ["/fingerprint/key"]
+`)
+	tr.Src = src
+	tr.skipUnchanged = true
+	defer tr.recordFingerprint(os.ErrInvalid)
+	renders := 0
+	tr.funcMap["renders"] = func() string {
+		renders++
+		return ""
+	}
+
+	pass := func(desc string, expected int) {
+		if err := tr.process(); err != nil {
+			t.Fatalf("%s: %s", desc, err.Error())
+		}
+		if renders != expected {
+			t.Errorf("%s: expected %d render(s), got %d", desc, expected, renders)
+		}
+	}
+	pass("first pass", 1)
+	pass("unchanged", 1)
+	os.Setenv("FINGERPRINT_KEY", "two")
+	pass("value changed", 2)
+	pass("unchanged after a value changed", 2)
+	// Modification times may not tell writes within the same tick apart.
+	time.Sleep(10 * time.Millisecond)
+	if err := ioutil.WriteFile(src, []byte(`{{renders}}key: {{getv "/fingerprint/key"}}`), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	pass("template changed", 3)
+	if got := readFile(t, tr.Dest); got != "key: two" {
+		t.Errorf("Expected dest key: two, got %s", got)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := ioutil.WriteFile(tr.Dest, []byte("edited"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	pass("dest changed", 4)
+	if got := readFile(t, tr.Dest); got != "key: two" {
+		t.Errorf("Expected dest to be restored, got %s", got)
+	}
+}
+
+// TestSkipUnchangedNewResources skips like the interval processor, which
+// loads the template resources again for every pass.
+func TestSkipUnchangedNewResources(t *testing.T) {
+	log.SetLevel("warn")
+	dir, err := ioutil.TempDir("", "fingerprint")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("FINGERPRINT_KEY", "one")
+	defer os.Unsetenv("FINGERPRINT_KEY")
+	src := filepath.Join(dir, "test.tmpl")
+	if err := ioutil.WriteFile(src, []byte(`{{renders}}key = {{getv "/fingerprint/key"}}`), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	renders := 0
+	for i := 0; i < 3; i++ {
+		tr := newTestResource(t, dir, `
+[template]
+name = "fingerprint-new-resources"
+src = "test.tmpl"
+dest = "dest.conf"
+keys = ["/fingerprint/key"]
+`)
+		tr.Src = src
+		tr.skipUnchanged = true
+		tr.funcMap["renders"] = func() string {
+			renders++
+			return ""
+		}
+		if err := tr.process(); err != nil {
+			t.Fatal(err.Error())
+		}
+		if i == 2 {
+			tr.recordFingerprint(os.ErrInvalid)
+		}
+	}
+	if renders != 1 {
+		t.Errorf("Expected 1 render over new resources, got %d", renders)
+	}
+}
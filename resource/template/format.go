@@ -0,0 +1,115 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Output formats of template resources rendered without a src template.
+const (
+	FormatJSON       = "json"
+	FormatYAML       = "yaml"
+	FormatEnv        = "env"
+	FormatProperties = "properties"
+)
+
+func validFormat(format string) bool {
+	switch format {
+	case FormatJSON, FormatYAML, FormatEnv, FormatProperties:
+		return true
+	}
+	return false
+}
+
+// renderFormat serializes vars, keyed by their path below the resource
+// prefix, in format.
+func renderFormat(format string, vars map[string]string) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		b, err := json.MarshalIndent(nest(vars), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	case FormatYAML:
+		return yaml.Marshal(nest(vars))
+	case FormatEnv:
+		return renderLines(vars, envName, envQuote, "="), nil
+	case FormatProperties:
+		return renderLines(vars, propertiesName, propertiesEscape, "="), nil
+	}
+	return nil, fmt.Errorf("unknown format %s", format)
+}
+
+// nest turns the slash separated keys of vars into nested maps. When a key
+// is both a value and the parent of other keys, the nested keys win.
+func nest(vars map[string]string) map[string]interface{} {
+	root := make(map[string]interface{})
+	for _, k := range sortedKeys(vars) {
+		parts := strings.Split(strings.Trim(k, "/"), "/")
+		m := root
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := m[part].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				m[part] = child
+			}
+			m = child
+		}
+		last := parts[len(parts)-1]
+		if _, ok := m[last].(map[string]interface{}); !ok {
+			m[last] = vars[k]
+		}
+	}
+	return root
+}
+
+// renderLines renders one name/value line per key of vars, sorted by key.
+func renderLines(vars map[string]string, name, quote func(string) string, sep string) []byte {
+	var buf bytes.Buffer
+	for _, k := range sortedKeys(vars) {
+		buf.WriteString(name(k) + sep + quote(vars[k]) + "\n")
+	}
+	return buf.Bytes()
+}
+
+func sortedKeys(vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// envName turns a key such as /db/host into DB_HOST.
+func envName(key string) string {
+	r := strings.NewReplacer("/", "_", "-", "_", ".", "_")
+	return strings.ToUpper(r.Replace(strings.Trim(key, "/")))
+}
+
+// envQuote double quotes value for env files when it holds characters the
+// shell would interpret.
+func envQuote(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\"'\\$`#;&|<>()*?[]{}~") {
+		return value
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`", "\n", `\n`)
+	return `"` + r.Replace(value) + `"`
+}
+
+// propertiesName turns a key such as /db/host into db.host.
+func propertiesName(key string) string {
+	return propertiesEscape(strings.Replace(strings.Trim(key, "/"), "/", ".", -1))
+}
+
+// propertiesEscape escapes s for Java properties files.
+func propertiesEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "=", `\=`, ":", `\:`, "#", `\#`, "!", `\!`)
+	return r.Replace(s)
+}
@@ -0,0 +1,238 @@
+package template
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kelseyhightower/confd/log"
+)
+
+// managedList is the file, in each managed directory, listing the files
+// confd wrote there.
+const managedList = ".confd-managed"
+
+// errItemsUnknown is returned by producedDests while a resource with
+// dest_pattern has not rendered its items yet.
+var errItemsUnknown = errors.New("destinations of dest_pattern not rendered yet")
+
+var (
+	gcMu sync.Mutex
+	// reportedStale holds the stale files already logged, so that they are
+	// not logged on every pass.
+	reportedStale = make(map[string]bool)
+)
+
+// CollectGarbage loads the template resources of config and returns the
+// files confd wrote to their managed directories that none of them
+// produces anymore. With config.ManagedDelete, outside noop mode, these
+// files are removed.
+func CollectGarbage(config Config) ([]string, error) {
+	ts, err := getTemplateResources(config)
+	if err != nil {
+		return nil, err
+	}
+	return collectGarbage(config, ts, true)
+}
+
+// collect runs collectGarbage after a processing pass over ts.
+func collect(config Config, ts []*TemplateResource) {
+	_, err := collectGarbage(config, ts, false)
+	if err == errItemsUnknown {
+		log.Debug("Not collecting stale files: %s", err.Error())
+	} else if err != nil {
+		log.Error("Cannot collect stale files: %s", err.Error())
+	}
+}
+
+// collectGarbage records the destinations of ts below each managed
+// directory in its managedList, and returns the files listed there that ts
+// no longer produce, removing them with config.ManagedDelete. The items of
+// resources with dest_pattern not processed yet are rendered only when
+// render is set. Nothing is collected without any template resource, so
+// that a missing or unreadable confdir does not empty managed directories.
+func collectGarbage(config Config, ts []*TemplateResource, render bool) ([]string, error) {
+	dirs := managedDirs(config, ts)
+	if len(dirs) == 0 || len(ts) == 0 {
+		return nil, nil
+	}
+	produced, err := producedDests(ts, render)
+	if err != nil {
+		return nil, err
+	}
+	remove := config.ManagedDelete && !config.Noop
+
+	gcMu.Lock()
+	defer gcMu.Unlock()
+	var stale []string
+	var lastErr error
+	for _, dir := range dirs {
+		managed, err := readManaged(dir)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		changed := false
+		for dest := range produced {
+			if !managed[dest] && isBelow(dir, dest) && isFileExist(dest) {
+				managed[dest] = true
+				changed = true
+			}
+		}
+		for dest := range managed {
+			if produced[dest] {
+				continue
+			}
+			if !isFileExist(dest) {
+				delete(managed, dest)
+				delete(reportedStale, dest)
+				changed = true
+				continue
+			}
+			stale = append(stale, dest)
+			if !remove {
+				if !reportedStale[dest] {
+					log.Warning("%s is no longer produced by any template resource; set managed_delete to remove it", dest)
+					reportedStale[dest] = true
+				}
+				continue
+			}
+			if err := os.Remove(dest); err != nil {
+				log.Error("Cannot remove stale file %s: %s", dest, err.Error())
+				lastErr = err
+				continue
+			}
+			log.Info("Removed stale file %s", dest)
+			delete(managed, dest)
+			delete(reportedStale, dest)
+			changed = true
+		}
+		if changed {
+			if err := writeManaged(dir, managed); err != nil {
+				lastErr = err
+			}
+		}
+	}
+	sort.Strings(stale)
+	return stale, lastErr
+}
+
+// managedDirs returns the managed directories of config and ts.
+func managedDirs(config Config, ts []*TemplateResource) []string {
+	found := make(map[string]bool)
+	if config.ManagedDir != "" {
+		found[filepath.Clean(config.ManagedDir)] = true
+	}
+	for _, t := range ts {
+		if t.ManagedDir != "" {
+			found[filepath.Clean(t.ManagedDir)] = true
+		}
+	}
+	dirs := make([]string, 0, len(found))
+	for dir := range found {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// producedDests returns the destinations of ts. Those of a resource with
+// dest_pattern are the ones of its last pass or, with render, rendered
+// from the current backend values.
+func producedDests(ts []*TemplateResource, render bool) (map[string]bool, error) {
+	produced := make(map[string]bool)
+	for _, t := range ts {
+		if t.DestPattern == "" {
+			produced[filepath.Clean(t.Dest)] = true
+			continue
+		}
+		itemDestsMu.Lock()
+		dests, ok := itemDests[t.Name]
+		for dest := range dests {
+			produced[dest] = true
+		}
+		itemDestsMu.Unlock()
+		if ok {
+			continue
+		}
+		if !render {
+			return nil, errItemsUnknown
+		}
+		if err := t.setVars(); err != nil {
+			return nil, err
+		}
+		for _, item := range t.items() {
+			dest, err := t.itemDest(item)
+			if err != nil {
+				return nil, err
+			}
+			produced[dest] = true
+		}
+	}
+	return produced, nil
+}
+
+// isBelow reports whether name is inside dir.
+func isBelow(dir, name string) bool {
+	rel, err := filepath.Rel(dir, name)
+	return err == nil && rel != "." && rel != ".." &&
+		!strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// readManaged returns the files listed in the managedList of dir. Entries
+// outside dir are ignored.
+func readManaged(dir string) (map[string]bool, error) {
+	managed := make(map[string]bool)
+	f, err := os.Open(filepath.Join(dir, managedList))
+	if os.IsNotExist(err) {
+		return managed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		name := filepath.Join(dir, line)
+		if isBelow(dir, name) && filepath.Base(name) != managedList {
+			managed[name] = true
+		}
+	}
+	return managed, scanner.Err()
+}
+
+// writeManaged replaces the managedList of dir with managed, relative to
+// dir.
+func writeManaged(dir string, managed map[string]bool) error {
+	lines := make([]string, 0, len(managed))
+	for name := range managed {
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, filepath.ToSlash(rel)+"\n")
+	}
+	sort.Strings(lines)
+	temp, err := ioutil.TempFile(dir, managedList)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	_, err = temp.WriteString(strings.Join(lines, ""))
+	if cerr := temp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), filepath.Join(dir, managedList))
+}
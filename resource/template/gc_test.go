@@ -0,0 +1,90 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kelseyhightower/confd/log"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	log.SetLevel("fatal")
+	dir, err := ioutil.TempDir("", "gc")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	a, b := filepath.Join(dir, "a.conf"), filepath.Join(dir, "b.conf")
+	for _, dest := range []string{a, b} {
+		if err := ioutil.WriteFile(dest, []byte(dest), 0644); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	outside, err := ioutil.TempFile("", "gc")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	outside.Close()
+	defer os.Remove(outside.Name())
+	ta := &TemplateResource{Dest: a}
+	tb := &TemplateResource{Dest: b}
+	tOutside := &TemplateResource{Dest: outside.Name()}
+
+	config := Config{ManagedDir: dir}
+	stale, err := collectGarbage(config, []*TemplateResource{ta, tb, tOutside}, false)
+	if err != nil || len(stale) != 0 {
+		t.Fatalf("Expected nothing stale, got %v, %v", stale, err)
+	}
+	managed, err := readManaged(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if expected := map[string]bool{a: true, b: true}; !reflect.DeepEqual(managed, expected) {
+		t.Errorf("Expected the managed files %v, got %v", expected, managed)
+	}
+
+	// Without managed_delete, and with it in noop mode, stale files are
+	// only reported.
+	for _, config := range []Config{{ManagedDir: dir}, {ManagedDir: dir, ManagedDelete: true, Noop: true}} {
+		stale, err = collectGarbage(config, []*TemplateResource{ta}, false)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if expected := []string{b}; !reflect.DeepEqual(stale, expected) {
+			t.Errorf("Expected the stale files %v, got %v", expected, stale)
+		}
+		if !isFileExist(b) {
+			t.Fatalf("Expected %s to be kept without managed_delete or in noop mode", b)
+		}
+	}
+
+	// Without any template resource nothing is collected.
+	config = Config{ManagedDir: dir, ManagedDelete: true}
+	if stale, err = collectGarbage(config, nil, false); err != nil || len(stale) != 0 {
+		t.Errorf("Expected nothing collected without template resources, got %v, %v", stale, err)
+	}
+
+	stale, err = collectGarbage(config, []*TemplateResource{ta}, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if expected := []string{b}; !reflect.DeepEqual(stale, expected) {
+		t.Errorf("Expected the stale files %v, got %v", expected, stale)
+	}
+	if isFileExist(b) {
+		t.Errorf("Expected %s to be removed with managed_delete", b)
+	}
+	if !isFileExist(a) {
+		t.Errorf("Expected %s, still produced, to be kept", a)
+	}
+	managed, err = readManaged(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if expected := map[string]bool{a: true}; !reflect.DeepEqual(managed, expected) {
+		t.Errorf("Expected the managed files %v, got %v", expected, managed)
+	}
+}
@@ -0,0 +1,283 @@
+package template
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Item is one of the items of a template resource with dest_pattern. It is
+// the data of dest_pattern and of the src template rendering its dest.
+type Item struct {
+	// Name is the last element of Key, e.g. web.
+	Name string
+	// Key is the key prefix of the item below the resource prefix, e.g.
+	// /services/web.
+	Key string
+}
+
+var (
+	itemDestsMu sync.Mutex
+	// itemDests holds the destinations rendered by the last pass over each
+	// resource with dest_pattern, by resource name.
+	itemDests = make(map[string]map[string]bool)
+)
+
+// checkItems validates the dest_pattern settings of t.
+func (t *TemplateResource) checkItems() error {
+	if t.DestPattern == "" {
+		if t.Items != "" {
+			return errors.New("items requires dest_pattern")
+		}
+		return nil
+	}
+	switch {
+	case t.Dest != "":
+		return errors.New("dest and dest_pattern are mutually exclusive")
+	case t.Items == "":
+		return errors.New("dest_pattern requires items")
+	case t.Src == "" || t.Format != "" || t.Binary:
+		return errors.New("dest_pattern requires a src template")
+	case t.Rollback:
+		return errors.New("rollback is not supported with dest_pattern")
+	}
+	if _, err := path.Match(t.Items, ""); err != nil {
+		return fmt.Errorf("invalid items %s: %s", t.Items, err.Error())
+	}
+	_, err := template.New("dest_pattern").Parse(t.DestPattern)
+	return err
+}
+
+// items returns the items of t: the key prefixes matching its items pattern,
+// sorted.
+func (t *TemplateResource) items() []Item {
+	found := make(map[string]bool)
+	for k := range t.vars {
+		for _, p := range keyPrefixes(k) {
+			if ok, _ := path.Match(t.Items, p); ok {
+				found[p] = true
+			}
+		}
+	}
+	items := make([]Item, 0, len(found))
+	for p := range found {
+		items = append(items, Item{Name: path.Base(p), Key: p})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	return items
+}
+
+// keyPrefixes returns key and its parents, shortest first: /a, /a/b, /a/b/c
+// for /a/b/c.
+func keyPrefixes(key string) []string {
+	parts := strings.Split(strings.Trim(key, "/"), "/")
+	prefixes := make([]string, len(parts))
+	for i := range parts {
+		prefixes[i] = "/" + strings.Join(parts[:i+1], "/")
+	}
+	return prefixes
+}
+
+// underItem reports whether key is an item matching the items pattern, or
+// a key below one.
+func underItem(pattern, key string) bool {
+	for _, p := range keyPrefixes(key) {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// itemDest returns the destination of item, dest_pattern rendered for it.
+func (t *TemplateResource) itemDest(item Item) (string, error) {
+	tmpl, err := template.New("dest_pattern").Funcs(t.funcMap).Parse(t.DestPattern)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, item); err != nil {
+		return "", err
+	}
+	dest := filepath.Clean(buf.String())
+	if dest == filepath.Clean(t.DestPattern) || strings.Contains(dest, "{{") {
+		return "", fmt.Errorf("dest_pattern renders to %s for item %s", dest, item.Key)
+	}
+	return dest, nil
+}
+
+// processItems renders the src template of t once per item to the
+// destination dest_pattern gives it, then removes the destinations of the
+// items gone since the last pass. reload_cmd runs once, when any
+// destination changed. The sync of each item is notified and audited on its
+// own, as are the failures of the pass not tied to a synced item.
+func (t *TemplateResource) processItems() error {
+	t.reading = newKeyReads()
+	// A key added below the items pattern may be a new item, which no
+	// render has read yet.
+	t.reading.items = t.Items
+	previous := t.previousItemDests()
+	dests := make(map[string]bool)
+	var lastErr error
+	for _, item := range t.items() {
+		item := item
+		dest, err := t.itemDest(item)
+		if err != nil {
+			t.logger().Error(err.Error())
+			t.notify(err)
+			lastErr = err
+			continue
+		}
+		dests[dest] = true
+		c := t.itemResource(dest, &item)
+		err = c.createStageFile()
+		if err == nil {
+			err = c.sync()
+		}
+		c.notify(err)
+		c.recordAudit(err)
+		if err != nil {
+			t.logger().Error("Cannot sync item %s to %s: %s", item.Key, dest, err.Error())
+			lastErr = err
+			continue
+		}
+		t.updated = t.updated || c.updated
+	}
+	// Keep the destinations of failed items, which may be retried.
+	if lastErr != nil {
+		for dest := range previous {
+			dests[dest] = true
+		}
+	}
+	for dest := range previous {
+		if dests[dest] {
+			continue
+		}
+		if t.noop {
+			t.logger().Warning("Noop mode enabled. " + dest + " of a removed item will not be removed")
+			dests[dest] = true
+			continue
+		}
+		if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+			t.logger().Error("Cannot remove " + dest + " of a removed item: " + err.Error())
+			t.notify(err)
+			dests[dest] = true
+			lastErr = err
+			continue
+		}
+		t.logger().Info("Removed " + dest + " of a removed item")
+		t.updated = true
+	}
+	t.setItemDests(dests)
+	if t.updated && !t.noop && !t.syncOnly && (t.ReloadCmd != "" || t.ReloadSignal != "") {
+		release, err := t.acquireReload()
+		if err != nil {
+			t.notify(err)
+			return err
+		}
+		err = t.reload()
+		release()
+		if err != nil {
+			t.notify(err)
+			return err
+		}
+	}
+	return lastErr
+}
+
+// itemResource returns the resource syncing item to dest: a fresh value with
+// the settings and the pass state of t that the sync of a destination uses.
+// Its template functions are those of t, reading the store of t.
+func (t *TemplateResource) itemResource(dest string, item *Item) *TemplateResource {
+	return &TemplateResource{
+		ACL:               t.ACL,
+		Backups:           t.Backups,
+		Binary:            t.Binary,
+		CanaryCmd:         t.CanaryCmd,
+		CanaryDest:        t.CanaryDest,
+		CheckCmd:          t.CheckCmd,
+		CmdRetries:        t.CmdRetries,
+		CmdTimeout:        t.CmdTimeout,
+		Dest:              dest,
+		Engine:            t.Engine,
+		FileMode:          t.FileMode,
+		Format:            t.Format,
+		Gid:               t.Gid,
+		Keys:              t.Keys,
+		Name:              t.Name,
+		Prefix:            t.Prefix,
+		ReloadCmd:         t.ReloadCmd,
+		ReloadConcurrency: t.ReloadConcurrency,
+		ReloadPidfile:     t.ReloadPidfile,
+		ReloadProcess:     t.ReloadProcess,
+		ReloadSemaphore:   t.ReloadSemaphore,
+		ReloadSignal:      t.ReloadSignal,
+		Rollback:          t.Rollback,
+		RollbackReload:    t.RollbackReload,
+		SELinuxContext:    t.SELinuxContext,
+		Shell:             t.Shell,
+		Src:               t.Src,
+		Uid:               t.Uid,
+		actor:             t.actor,
+		backend:           t.backend,
+		credential:        t.credential,
+		ctx:               t.ctx,
+		funcMap:           t.funcMap,
+		item:              item,
+		keepStageFile:     t.keepStageFile,
+		lockID:            t.lockID,
+		lockTTL:           t.lockTTL,
+		noop:              t.noop,
+		notifier:          t.notifier,
+		reading:           t.reading,
+		reloadExit:        t.reloadExit,
+		storeClient:       t.storeClient,
+		syncOnly:          t.syncOnly,
+		templateDir:       t.templateDir,
+		vars:              t.vars,
+	}
+}
+
+// sampleItem selects the first item of a resource with dest_pattern for a
+// dry run or preview, which render a single destination.
+func (t *TemplateResource) sampleItem() error {
+	if t.DestPattern == "" || t.item != nil {
+		return nil
+	}
+	items := t.items()
+	if len(items) == 0 {
+		return fmt.Errorf("no item matches %s", t.Items)
+	}
+	dest, err := t.itemDest(items[0])
+	if err != nil {
+		return err
+	}
+	t.item = &items[0]
+	t.Dest = dest
+	return nil
+}
+
+// previousItemDests returns the destinations rendered by the last pass over
+// t.
+func (t *TemplateResource) previousItemDests() map[string]bool {
+	itemDestsMu.Lock()
+	defer itemDestsMu.Unlock()
+	previous := make(map[string]bool, len(itemDests[t.Name]))
+	for dest := range itemDests[t.Name] {
+		previous[dest] = true
+	}
+	return previous
+}
+
+func (t *TemplateResource) setItemDests(dests map[string]bool) {
+	itemDestsMu.Lock()
+	defer itemDestsMu.Unlock()
+	itemDests[t.Name] = dests
+}
@@ -0,0 +1,76 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/kelseyhightower/confd/audit"
+	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/notify"
+)
+
+type recordingNotifier struct {
+	events []notify.Event
+}
+
+func (n *recordingNotifier) Notify(e notify.Event) {
+	n.events = append(n.events, e)
+}
+
+func TestProcessItemsReportsOnce(t *testing.T) {
+	log.SetLevel("fatal")
+	dir, err := ioutil.TempDir("", "items")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("REPORTITEMS_A_NAME", "a")
+	defer os.Unsetenv("REPORTITEMS_A_NAME")
+	os.Setenv("REPORTITEMS_B_NAME", "b")
+	defer os.Unsetenv("REPORTITEMS_B_NAME")
+	src := filepath.Join(dir, "test.tmpl")
+	if err := ioutil.WriteFile(src, []byte(`name = {{getv (printf "%s/name" .Key)}}`), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	audit.SetStore(audit.NewFileStore(filepath.Join(dir, "audit.log")))
+	defer audit.SetStore(nil)
+
+	tr := newTestResource(t, dir, `
+[template]
+src = "test.tmpl"
+dest_pattern = "`+filepath.Join(dir, "{{.Name}}.conf")+`"
+items = "/reportitems/*"
+keys = ["/reportitems"]
+check_cmd = "grep -q 'name = a' {{.src}}"
+`)
+	tr.Dest = ""
+	tr.Src = src
+	notifier := &recordingNotifier{}
+	tr.notifier = notifier
+	defer tr.setItemDests(nil)
+	if err := tr.process(); err == nil {
+		t.Fatal("Expected the check_cmd of item b to fail")
+	}
+
+	var dests []string
+	for _, e := range notifier.events {
+		dests = append(dests, filepath.Base(e.Dest))
+		if e.Success != (e.Dest == filepath.Join(dir, "a.conf")) {
+			t.Errorf("Unexpected event %+v", e)
+		}
+	}
+	sort.Strings(dests)
+	if len(dests) != 2 || dests[0] != "a.conf" || dests[1] != "b.conf" {
+		t.Errorf("Expected one event per item, got %v", dests)
+	}
+	entries, err := audit.Query(audit.Filter{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected one audit entry per item, got %d", len(entries))
+	}
+}
@@ -0,0 +1,70 @@
+package template
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	xattrSELinux = "security.selinux"
+	xattrACL     = "system.posix_acl_access"
+)
+
+var errXattrUnsupported = errors.New("extended attributes are not supported on this platform")
+
+// fileModeFromUnix converts Unix permission bits, including the setuid,
+// setgid and sticky bits, to an os.FileMode.
+func fileModeFromUnix(mode uint32) os.FileMode {
+	m := os.FileMode(mode & 0777)
+	if mode&04000 != 0 {
+		m |= os.ModeSetuid
+	}
+	if mode&02000 != 0 {
+		m |= os.ModeSetgid
+	}
+	if mode&01000 != 0 {
+		m |= os.ModeSticky
+	}
+	return m
+}
+
+// setMetadata sets the owner, mode, SELinux context and ACL of name. The
+// SELinux context and ACL are the declared ones or, when unset, those of the
+// existing destination.
+func (t *TemplateResource) setMetadata(name string) error {
+	if name != t.Dest && isFileExist(t.Dest) {
+		if t.SELinuxContext == "" {
+			copyXattr(t.Dest, name, xattrSELinux)
+		}
+		if len(t.ACL) == 0 {
+			copyXattr(t.Dest, name, xattrACL)
+		}
+	}
+	// Chown clears the setuid and setgid bits, so it has to come first.
+	chown(name, t.Uid, t.Gid)
+	os.Chmod(name, t.FileMode)
+	if t.SELinuxContext != "" {
+		if err := setXattr(name, xattrSELinux, []byte(t.SELinuxContext)); err != nil {
+			return err
+		}
+	}
+	if len(t.ACL) > 0 {
+		out, err := exec.Command("setfacl", "-m", strings.Join(t.ACL, ","), name).CombinedOutput()
+		if err != nil {
+			return errors.New("setfacl " + name + ": " + strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+// copyXattr copies the extended attribute attr from src to dst. Missing
+// attributes and platforms without extended attributes are ignored.
+func copyXattr(src, dst, attr string) {
+	value, err := getXattr(src, attr)
+	if err != nil || value == nil {
+		return
+	}
+	setXattr(dst, attr, value)
+}
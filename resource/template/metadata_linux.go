@@ -0,0 +1,26 @@
+package template
+
+import "syscall"
+
+// getXattr returns the value of the extended attribute attr of name, or nil
+// when it is not set.
+func getXattr(name, attr string) ([]byte, error) {
+	size, err := syscall.Getxattr(name, attr, nil)
+	if err == syscall.ENODATA || err == syscall.ENOTSUP {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, size)
+	size, err = syscall.Getxattr(name, attr, value)
+	if err != nil {
+		return nil, err
+	}
+	return value[:size], nil
+}
+
+// setXattr sets the extended attribute attr of name to value.
+func setXattr(name, attr string, value []byte) error {
+	return syscall.Setxattr(name, attr, value, 0)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package template
+
+// getXattr reports that no extended attributes are set.
+func getXattr(name, attr string) ([]byte, error) {
+	return nil, nil
+}
+
+// setXattr fails, extended attributes are only supported on Linux.
+func setXattr(name, attr string, value []byte) error {
+	return errXattrUnsupported
+}
@@ -0,0 +1,56 @@
+package template
+
+import (
+	"io/ioutil"
+	"time"
+
+	"github.com/kelseyhightower/confd/notify"
+	"github.com/kelseyhightower/confd/redact"
+)
+
+// startEvent starts the event of a sync of the staged file, summarizing its
+// diff against the destination.
+func (t *TemplateResource) startEvent(staged string) {
+	if t.notifier == nil {
+		return
+	}
+	e := &notify.Event{Resource: t.Name, Dest: t.Dest}
+	rendered, err := ioutil.ReadFile(staged)
+	current, derr := t.readDest()
+	if err == nil && derr == nil {
+		e.Diff = diffSummary(current, rendered)
+	}
+	t.event = e
+}
+
+// setReloadResult records the result of reload_cmd in the event.
+func (t *TemplateResource) setReloadResult(err error) {
+	if t.event == nil {
+		return
+	}
+	t.event.Reload = notify.ReloadOK
+	if err != nil {
+		t.event.Reload = notify.ReloadFailed
+	}
+}
+
+// notify sends the event of the sync, if any, or of the failure err.
+func (t *TemplateResource) notify(err error) {
+	if t.notifier == nil {
+		return
+	}
+	e := t.event
+	t.event = nil
+	if e == nil {
+		if err == nil {
+			return
+		}
+		e = &notify.Event{Resource: t.Name, Dest: t.Dest}
+	}
+	e.Success = err == nil
+	if err != nil {
+		e.Error = redact.Text(err.Error())
+	}
+	e.Time = time.Now()
+	t.notifier.Notify(*e)
+}
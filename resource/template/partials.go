@@ -0,0 +1,25 @@
+package template
+
+import (
+	"path/filepath"
+	"text/template"
+)
+
+// partialsDir is the directory, below the templates directory of a project,
+// holding partials shared by every template of the project.
+const partialsDir = "_partials"
+
+// addPartials parses the partials of the templates directory of t into tmpl,
+// so that templates can include them with {{template "name.tmpl" .}} or use
+// the templates they define.
+func (t *TemplateResource) addPartials(tmpl *template.Template) (*template.Template, error) {
+	if t.templateDir == "" {
+		return tmpl, nil
+	}
+	partials, err := filepath.Glob(filepath.Join(t.templateDir, partialsDir, "*.tmpl"))
+	if err != nil || len(partials) == 0 {
+		return tmpl, err
+	}
+	t.logger().Debug("Adding %d partials", len(partials))
+	return tmpl.ParseFiles(partials...)
+}
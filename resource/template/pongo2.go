@@ -0,0 +1,97 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/flosch/pongo2"
+)
+
+// Engines rendering src templates, set with the engine option.
+const (
+	engineGo     = "go"
+	enginePongo2 = "pongo2"
+)
+
+func validEngine(engine string) bool {
+	return engine == "" || engine == engineGo || engine == enginePongo2
+}
+
+// renderPongo2 executes source as a pongo2 template. Templates it includes
+// or extends are loaded relative to the templates directory of the project,
+// or else to the directory of src. A sandboxed render bans ssi, confines the
+// templates loaded to that directory and leaves the env and file functions
+// out, see previewFuncMap.
+func (t *TemplateResource) renderPongo2(source string, sandboxed bool) ([]byte, error) {
+	dir := t.templateDir
+	if dir == "" && !isRemoteSrc(t.Src) {
+		dir = filepath.Dir(t.Src)
+	}
+	var loader pongo2.TemplateLoader
+	funcMap := t.funcMap
+	if sandboxed {
+		loader = confinedLoader{dir: dir}
+		funcMap = t.previewFuncMap()
+	} else {
+		local, err := pongo2.NewLocalFileSystemLoader(dir)
+		if err != nil {
+			return nil, err
+		}
+		loader = local
+	}
+	// A new set per render, as sets cache the templates they load.
+	set := pongo2.NewSet(t.Name, loader)
+	if sandboxed {
+		if err := set.BanTag("ssi"); err != nil {
+			return nil, err
+		}
+	}
+	tmpl, err := set.FromString(source)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl.ExecuteBytes(t.pongo2Context(funcMap))
+}
+
+// pongo2Context returns the context of the pongo2 templates of t: the
+// template functions of funcMap, called like {{ getv("/key") }}, and the
+// Item of dest_pattern as item.
+func (t *TemplateResource) pongo2Context(funcMap map[string]interface{}) pongo2.Context {
+	ctx := make(pongo2.Context, len(funcMap)+1)
+	for name, fn := range funcMap {
+		ctx[name] = fn
+	}
+	if t.item != nil {
+		ctx["item"] = t.item
+	}
+	return ctx
+}
+
+// confinedLoader loads the pongo2 templates of a sandboxed render from dir,
+// refusing absolute paths and paths leaving dir.
+type confinedLoader struct {
+	dir string
+}
+
+// Abs keeps name as is: included templates are relative to dir, not to the
+// template including them.
+func (l confinedLoader) Abs(base, name string) string {
+	return name
+}
+
+func (l confinedLoader) Get(name string) (io.Reader, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if l.dir == "" || filepath.IsAbs(clean) || clean == ".." ||
+		strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("Template %s is outside of the templates directory", name)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(l.dir, clean))
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
@@ -6,7 +6,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/kelseyhightower/confd/backends"
 	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/metrics"
+	"github.com/kelseyhightower/confd/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Processor interface {
@@ -18,20 +22,61 @@ func Process(config Config) error {
 	if err != nil {
 		return err
 	}
-	return process(ts)
+	err = process(config, ts)
+	collect(config, ts)
+	return err
 }
 
-func process(ts []*TemplateResource) error {
+func process(config Config, ts []*TemplateResource) error {
 	var lastErr error
-	for _, t := range ts {
-		if err := t.process(); err != nil {
-			log.Error("process resource fail. src: %s, error: %s", t.Src, err.Error())
+	for i, err := range processAll(config, ts, nil) {
+		if err != nil {
+			ts[i].logger().Error("process resource fail. src: %s, error: %s", ts[i].Src, err.Error())
 			lastErr = err
 		}
 	}
 	return lastErr
 }
 
+// processAll processes ts with at most config.MaxParallel resources at a
+// time and returns the error of each. Resources sharing a destination are still
+// processed one after the other. Once stopChan is closed no further resource
+// is started, while those started are waited for.
+func processAll(config Config, ts []*TemplateResource, stopChan chan bool) []error {
+	maxParallel := config.MaxParallel
+	if maxParallel < 1 {
+		maxParallel = 1
+	}
+	errs := make([]error, len(ts))
+	if len(ts) == 0 {
+		return errs
+	}
+	ctx, span := tracing.Start(config.context(), "pass", attribute.Int("resources", len(ts)))
+	defer span.End()
+	sem := make(chan struct{}, maxParallel)
+	var wg sync.WaitGroup
+loop:
+	for i, t := range ts {
+		i, t := i, t
+		t.ctx = ctx
+		select {
+		case sem <- struct{}{}:
+		case <-stopChan:
+			break loop
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = t.process()
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
 type intervalProcessor struct {
 	config   Config
 	stopChan chan bool
@@ -46,34 +91,125 @@ func IntervalProcessor(config Config, stopChan, doneChan chan bool, errChan chan
 
 func (p *intervalProcessor) Process() {
 	defer close(p.doneChan)
+	// next holds when each resource is due, by name.
+	next := make(map[string]time.Time)
+	backoffs := make(map[string]*backends.Backoff)
 	for {
-
 		ts, err := getTemplateResources(p.config)
 		if err != nil {
 			log.Warning("resource parse failure: %s", err.Error())
-			continue
 		}
-		process(ts)
+		now := time.Now()
+		wake := now.Add(time.Duration(p.interval) * time.Second)
+		due := make([]*TemplateResource, 0, len(ts))
+		for _, t := range ts {
+			n, ok := next[t.Name]
+			if !ok || !now.Before(n) {
+				due = append(due, t)
+				n = now.Add(time.Duration(t.interval(p.interval)) * time.Second)
+				next[t.Name] = n
+			}
+			if n.Before(wake) {
+				wake = n
+			}
+		}
+		for i, err := range processAll(p.config, due, p.stopChan) {
+			t := due[i]
+			if err == nil {
+				delete(backoffs, t.Name)
+				continue
+			}
+			t.logger().Error("process resource fail. src: %s, error: %s", t.Src, err.Error())
+			if _, ok := err.(*CommandError); ok {
+				p.errChan <- err
+			}
+			if !isBackendError(err) {
+				continue
+			}
+			// Retry a failing backend with backoff instead of the interval.
+			b, ok := backoffs[t.Name]
+			if !ok {
+				b = backends.RetryPolicyOf(t.storeClient).NewBackoff()
+				backoffs[t.Name] = b
+			}
+			d, err := b.Next(err)
+			if err != nil {
+				p.errChan <- err
+				continue
+			}
+			retry := time.Now().Add(d)
+			next[t.Name] = retry
+			if retry.Before(wake) {
+				wake = retry
+			}
+		}
+		if err == nil {
+			collect(p.config, ts)
+		}
+		heartbeat(p.config)
+		if !wait(p.config, p.stopChan, wake.Sub(time.Now())) {
+			return
+		}
+	}
+}
+
+// backendError marks errors returned by the store client, which are retried
+// with backoff.
+type backendError struct {
+	err error
+}
+
+func (e *backendError) Error() string {
+	return e.err.Error()
+}
+
+func isBackendError(err error) bool {
+	_, ok := err.(*backendError)
+	return ok
+}
+
+// wait blocks for d while serving sync requests. It returns false if the
+// processor was stopped.
+func wait(config Config, stopChan chan bool, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+	for {
 		select {
-		case <-p.stopChan:
-			break
-		case <-time.After(time.Duration(p.interval) * time.Second):
-			continue
+		case <-stopChan:
+			return false
+		case req := <-config.SyncChan:
+			serveSyncRequest(config, req)
+		case <-ticker.C:
+			heartbeat(config)
+		case <-timer.C:
+			return true
 		}
 	}
 }
 
+// heartbeat calls the Heartbeat of config, if any.
+func heartbeat(config Config) {
+	if config.Heartbeat != nil {
+		config.Heartbeat()
+	}
+}
+
 type watchProcessor struct {
 	config   Config
 	stopChan chan bool
 	doneChan chan bool
 	errChan  chan error
 	wg       sync.WaitGroup
+	// ts holds every template resource, for collecting stale files. It is
+	// empty when some failed to load.
+	ts []*TemplateResource
 }
 
 func WatchProcessor(config Config, stopChan, doneChan chan bool, errChan chan error) Processor {
 	var wg sync.WaitGroup
-	return &watchProcessor{config, stopChan, doneChan, errChan, wg}
+	return &watchProcessor{config, stopChan, doneChan, errChan, wg, nil}
 }
 
 func (p *watchProcessor) Process() {
@@ -83,34 +219,125 @@ func (p *watchProcessor) Process() {
 	ts, err := getTemplateResources(p.config)
 	if err != nil {
 		log.Warning(fmt.Sprintf("Parse template faild. %s", err.Error()))
+	} else {
+		p.ts = ts
 	}
 
 	for _, t := range ts {
 		t := t
+		t.trackDeps = true
 		p.wg.Add(1)
 		go p.monitorPrefix(t)
 	}
+	p.wg.Add(1)
+	go p.serveSyncRequests()
 	p.wg.Wait()
 }
 
+// stopped reports whether the processor was asked to stop.
+func (p *watchProcessor) stopped() bool {
+	select {
+	case <-p.stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *watchProcessor) serveSyncRequests() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stopChan:
+			return
+		case req := <-p.config.SyncChan:
+			serveSyncRequest(p.config, req)
+		case <-ticker.C:
+			heartbeat(p.config)
+		}
+	}
+}
+
 func (p *watchProcessor) monitorPrefix(t *TemplateResource) {
 	defer p.wg.Done()
-	keys := appendPrefix(t.Prefix, t.Keys)
+	prefix, keys := t.watchedKeys()
+	backoff := backends.RetryPolicyOf(t.storeClient).NewBackoff()
+	var lastProcess time.Time
+	if index := t.resumeIndex(); index > 0 {
+		// The watch only returns for the changes after index: render the
+		// current values first.
+		t.logger().Info("Resuming watch from index %d", index)
+		lastProcess = time.Now()
+		if err := t.process(); err != nil {
+			p.errChan <- err
+		}
+		collect(p.config, p.ts)
+		t.lastIndex, t.savedIndex = index, index
+	}
 	for {
-		index, err := t.storeClient.WatchPrefix(t.Prefix, keys, t.lastIndex, p.stopChan)
+		index, err := t.storeClient.WatchPrefix(p.config.context(), prefix, keys, t.lastIndex, p.stopChan)
+		if p.stopped() {
+			return
+		}
 		if err != nil {
 			p.errChan <- err
+			metrics.WatchReconnects.WithLabelValues(t.Name).Inc()
 			// Prevent backend errors from consuming all resources.
-			time.Sleep(time.Second * 2)
+			d, err := backoff.Next(err)
+			if err != nil {
+				p.errChan <- err
+				return
+			}
+			select {
+			case <-p.stopChan:
+				return
+			case <-time.After(d):
+			}
 			continue
 		}
+		backoff.Reset()
 		t.lastIndex = index
+		t.logger().Debug("Watch returned index %d", index)
+		// Let further changes coalesce into the same pass.
+		if d := t.reloadDelay(lastProcess, time.Now()); d > 0 {
+			t.logger().Debug("Delaying processing by %s", d)
+			select {
+			case <-p.stopChan:
+				return
+			case <-time.After(d):
+			}
+		}
+		lastProcess = time.Now()
 		if err := t.process(); err != nil {
 			p.errChan <- err
+		} else {
+			t.saveIndex()
 		}
+		collect(p.config, p.ts)
 	}
 }
 
+// GetTemplateResources loads every template resource of every project.
+func GetTemplateResources(config Config) ([]*TemplateResource, error) {
+	return getTemplateResources(config)
+}
+
+// FindTemplateResource returns the template resource called name.
+func FindTemplateResource(config Config, name string) (*TemplateResource, error) {
+	ts, err := getTemplateResources(config)
+	for _, t := range ts {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("template resource %s not found", name)
+}
+
 func getTemplateResources(config Config) ([]*TemplateResource, error) {
 	var lastError error
 	templates := make([]*TemplateResource, 0)
@@ -123,14 +350,24 @@ func getTemplateResources(config Config) ([]*TemplateResource, error) {
 
 	projects, err := LoadProjects(config.ConfDir)
 	if err != nil {
-		log.Fatal(err.Error())
+		return nil, err
 	}
 
+	// Names identify resources in their status, state files and the admin
+	// API: they must be unique across projects.
+	paths := make(map[string]string)
 	for _, project := range projects {
 		tmplsOfProject, err := GetTemplateResourceByProject(project, config)
 		if tmplsOfProject != nil {
-			templates = append(templates, tmplsOfProject...)
 			lastError = err
+			for _, t := range tmplsOfProject {
+				if other, ok := paths[t.Name]; ok {
+					lastError = fmt.Errorf("Cannot process template resource %s - name %s is already used by %s, set a unique name", t.path, t.Name, other)
+					continue
+				}
+				paths[t.Name] = t.path
+				templates = append(templates, t)
+			}
 		}
 	}
 
@@ -162,11 +399,20 @@ func GetTemplateResourceByProject(project *Project, config Config) ([]*TemplateR
 			continue
 		}
 
-		t.Src = filepath.Join(templatePath, t.Src)
+		t.templateDir = templatePath
+		if t.Src != "" && !isRemoteSrc(t.Src) {
+			t.Src = filepath.Join(templatePath, t.Src)
+		}
 		// if is absolute path, or relative path
 		if !filepath.IsAbs(t.Dest) {
 			t.Dest = filepath.Join(project.ConfDir, t.Dest)
 		}
+		if t.DestPattern != "" && !filepath.IsAbs(t.DestPattern) {
+			t.DestPattern = filepath.Join(project.ConfDir, t.DestPattern)
+		}
+		if t.ManagedDir != "" && !filepath.IsAbs(t.ManagedDir) {
+			t.ManagedDir = filepath.Join(project.ConfDir, t.ManagedDir)
+		}
 		templates = append(templates, t)
 	}
 	return templates, lastError
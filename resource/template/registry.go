@@ -0,0 +1,72 @@
+package template
+
+import (
+	"fmt"
+	"plugin"
+	"reflect"
+	"sync"
+)
+
+var (
+	registryMu      sync.RWMutex
+	registeredFuncs = make(map[string]interface{})
+)
+
+// RegisterFunc makes fn available to every template resource created
+// afterwards as name. Registered functions override built-in ones of the
+// same name, but not the key functions such as getv.
+func RegisterFunc(name string, fn interface{}) error {
+	if name == "" {
+		return fmt.Errorf("template function name is empty")
+	}
+	if fn == nil || reflect.TypeOf(fn).Kind() != reflect.Func {
+		return fmt.Errorf("template function %s is not a function", name)
+	}
+	registryMu.Lock()
+	registeredFuncs[name] = fn
+	registryMu.Unlock()
+	return nil
+}
+
+// RegisterFuncs registers every function of funcs.
+func RegisterFuncs(funcs map[string]interface{}) error {
+	for name, fn := range funcs {
+		if err := RegisterFunc(name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFuncPlugin registers the functions of the Go plugin at path. The
+// plugin must export either
+//
+//	var Funcs map[string]interface{}
+//
+// or
+//
+//	func Funcs() map[string]interface{}
+func LoadFuncPlugin(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return err
+	}
+	sym, err := p.Lookup("Funcs")
+	if err != nil {
+		return err
+	}
+	switch funcs := sym.(type) {
+	case *map[string]interface{}:
+		return RegisterFuncs(*funcs)
+	case func() map[string]interface{}:
+		return RegisterFuncs(funcs())
+	}
+	return fmt.Errorf("%s: Funcs is a %T, not a map[string]interface{}", path, sym)
+}
+
+// addRegisteredFuncs adds the registered functions to funcMap.
+func addRegisteredFuncs(funcMap map[string]interface{}) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	addFuncs(funcMap, registeredFuncs)
+}
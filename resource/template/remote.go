@@ -0,0 +1,58 @@
+package template
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// remoteSrcPrefix marks a src stored in the backend, e.g.
+// backend:/templates/nginx.conf.tmpl.
+const remoteSrcPrefix = "backend:"
+
+// isRemoteSrc reports whether src names a backend key.
+func isRemoteSrc(src string) bool {
+	return strings.HasPrefix(src, remoteSrcPrefix)
+}
+
+// remoteSrcKey returns the backend key holding the src template of t.
+func (t *TemplateResource) remoteSrcKey() string {
+	return path.Join("/", strings.TrimPrefix(t.Src, remoteSrcPrefix))
+}
+
+// fetchRemoteSrc returns the src template of t from the backend.
+func (t *TemplateResource) fetchRemoteSrc() (string, error) {
+	key := t.remoteSrcKey()
+	t.logger().Debug("Fetching source template from key " + key)
+	values, err := t.storeClient.GetValues(t.ctx, []string{key})
+	if err != nil {
+		return "", &backendError{err}
+	}
+	body, ok := values[key]
+	if !ok {
+		return "", fmt.Errorf("Missing template: key %s not found", key)
+	}
+	return body, nil
+}
+
+// watchedKeys returns the prefix and keys to watch for changes of t,
+// including the key of a remote src template.
+func (t *TemplateResource) watchedKeys() (string, []string) {
+	keys := appendPrefix(t.Prefix, t.Keys)
+	if !isRemoteSrc(t.Src) {
+		return t.Prefix, keys
+	}
+	key := t.remoteSrcKey()
+	return commonPathPrefix(t.Prefix, key), append(keys, key)
+}
+
+// commonPathPrefix returns the longest path both a and b lie below.
+func commonPathPrefix(a, b string) string {
+	as := strings.Split(strings.Trim(a, "/"), "/")
+	bs := strings.Split(strings.Trim(b, "/"), "/")
+	common := make([]string, 0, len(as))
+	for i := 0; i < len(as) && i < len(bs) && as[i] == bs[i]; i++ {
+		common = append(common, as[i])
+	}
+	return "/" + strings.Join(common, "/")
+}
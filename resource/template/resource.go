@@ -2,21 +2,30 @@ package template
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/kelseyhightower/confd/audit"
 	"github.com/kelseyhightower/confd/backends"
 	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/metrics"
+	"github.com/kelseyhightower/confd/notify"
+	"github.com/kelseyhightower/confd/redact"
+	"github.com/kelseyhightower/confd/tracing"
 	"github.com/kelseyhightower/memkv"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Config struct {
@@ -26,6 +35,69 @@ type Config struct {
 	Prefix        string
 	StoreClient   backends.StoreClient
 	SyncOnly      bool
+	// StaleThreshold is the default stale_threshold of template resources.
+	StaleThreshold int
+	// SyncChan receives requests for immediate processing passes.
+	SyncChan chan *SyncRequest
+	// Backend is the name of the backend, attached to log entries.
+	Backend string
+	// StoreClients are the named backends template resources may select
+	// with their backend setting.
+	StoreClients map[string]backends.StoreClient
+	// MaxParallel is the number of template resources processed at once.
+	MaxParallel int
+	// EnableSprig adds the Sprig functions to templates.
+	EnableSprig bool
+	// Decrypter holds the keys of the decrypt template functions.
+	Decrypter *Decrypter
+	// Notifier is told about every sync of a template resource.
+	Notifier notify.Notifier
+	// SkipUnchanged skips passes over template resources whose values,
+	// templates and dest did not change since their last successful pass.
+	SkipUnchanged bool
+	// ManagedDir is managed like the managed_dir of template resources,
+	// and ManagedDelete removes the stale files of managed directories
+	// instead of only logging them.
+	ManagedDir    string
+	ManagedDelete bool
+	// Context cancels the backend requests of the processor when done,
+	// e.g. on shutdown or reload. Defaults to context.Background().
+	Context context.Context
+	// PassTimeout, when set, bounds the backend requests of each pass over
+	// a template resource.
+	PassTimeout time.Duration
+	// Heartbeat, when set, is called by the processor loop after each pass
+	// and every HeartbeatInterval while it waits, so that a hung processor
+	// can be told from an idle one.
+	Heartbeat func()
+	// CmdUser and CmdGroup are the default cmd_user and cmd_group of
+	// template resources.
+	CmdUser  string
+	CmdGroup string
+	// LockID identifies this replica in the reload_semaphore locks, which
+	// expire LockTTL after their last renewal.
+	LockID  string
+	LockTTL time.Duration
+	// StateDir, when set, keeps the values read by the last successful pass
+	// over each template resource, rendered while the backend is down
+	// after a restart.
+	StateDir string
+	// WatchResume holds the watch_resume settings of the backends by name.
+	// The watches on the backends set to backends.WatchResumeOldest resume
+	// from the index kept in StateDir.
+	WatchResume map[string]string
+}
+
+// HeartbeatInterval is how often Config.Heartbeat is called while the
+// processor waits.
+const HeartbeatInterval = time.Second
+
+// context returns the Context of config, or context.Background().
+func (c Config) context() context.Context {
+	if c.Context == nil {
+		return context.Background()
+	}
+	return c.Context
 }
 
 // TemplateResourceConfig holds the parsed template resource.
@@ -35,24 +107,135 @@ type TemplateResourceConfig struct {
 
 // TemplateResource is the representation of a parsed template resource.
 type TemplateResource struct {
-	CheckCmd      string `toml:"check_cmd"`
-	Dest          string
-	FileMode      os.FileMode
-	Gid           int
-	Keys          []string
-	Mode          string
-	Prefix        string
-	ReloadCmd     string `toml:"reload_cmd"`
-	Src           string
-	StageFile     *os.File
-	Uid           int
-	funcMap       map[string]interface{}
-	lastIndex     uint64
-	keepStageFile bool
-	noop          bool
+	// AlwaysRender opts the resource out of SkipUnchanged, for templates
+	// reading more than the backend, e.g. with lookupIP.
+	AlwaysRender bool `toml:"always_render"`
+	// ACL lists setfacl entries, such as "u:nginx:r", applied to dest.
+	// When unset, the ACL of the existing dest is kept.
+	ACL []string `toml:"acl"`
+	// Backend names the backend serving the keys of this resource, one of
+	// the [[backends]] of the confd configuration.
+	Backend string
+	// Binary writes the base64 decoded value of the single key of the
+	// resource to dest as is, without a src template.
+	Binary bool
+	// Backups is the number of timestamped copies of dest kept before it
+	// is overwritten.
+	Backups int
+	// CanaryDest, when set, receives the new config first: check_cmd runs
+	// against it and then CanaryCmd, and dest is only replaced once both
+	// succeeded.
+	CanaryDest string `toml:"canary_dest"`
+	CanaryCmd  string `toml:"canary_cmd"`
+	CheckCmd   string `toml:"check_cmd"`
+	// CmdRetries is how many times a failing check_cmd or reload_cmd is
+	// retried, and CmdTimeout the seconds after which each attempt is
+	// killed.
+	CmdRetries int `toml:"cmd_retries"`
+	CmdTimeout int `toml:"cmd_timeout"`
+	// CmdUser and CmdGroup, names or IDs, run check_cmd and reload_cmd
+	// with the privileges of that user and group instead of those of confd.
+	// CmdGroup defaults to the primary group of CmdUser.
+	CmdUser  string `toml:"cmd_user"`
+	CmdGroup string `toml:"cmd_group"`
+	Dest     string
+	// DestPattern, such as "/etc/nginx/sites.d/{{.Name}}.conf", renders
+	// the src template once per key prefix matching Items, such as
+	// "/services/*", to the destination it gives the Item. Dest is set
+	// to it for the logs and the status.
+	DestPattern string `toml:"dest_pattern"`
+	// Engine renders src: "go" for text/template, the default, or
+	// "pongo2" for Jinja2-like templates.
+	Engine string
+	// ExpandValues flattens values holding JSON or YAML documents into
+	// pseudo-keys below their key.
+	ExpandValues bool `toml:"expand_values"`
+	FileMode     os.FileMode
+	// Format, one of json, yaml, env or properties, renders the keys
+	// straight to dest without a src template.
+	Format string
+	Gid    int
+	// Interval is the number of seconds between two passes over this
+	// resource in interval mode, overriding the global interval.
+	Interval int
+	Items    string
+	Keys     []string
+	// ManagedDir is a directory whose files written by confd are removed
+	// once no template resource produces them anymore.
+	ManagedDir string `toml:"managed_dir"`
+	// MinReloadInterval is the minimum number of seconds between two
+	// passes triggered by a watch, and Splay the maximum number of seconds
+	// of random delay added to them, so bursts of changes coalesce.
+	MinReloadInterval int `toml:"min_reload_interval"`
+	Mode              string
+	Name              string
+	Prefix            string
+	ReloadCmd         string `toml:"reload_cmd"`
+	// ReloadSignal, such as "HUP" or "USR1", is sent instead of running
+	// reload_cmd to the process whose ID is in ReloadPidfile or to the
+	// processes called ReloadProcess.
+	ReloadSignal  string `toml:"reload_signal"`
+	ReloadPidfile string `toml:"reload_pidfile"`
+	ReloadProcess string `toml:"reload_process"`
+	// ReloadSemaphore, a backend key, lets at most ReloadConcurrency
+	// replicas, 1 by default, replace dest and reload at once.
+	ReloadSemaphore   string `toml:"reload_semaphore"`
+	ReloadConcurrency int    `toml:"reload_concurrency"`
+	// Rollback restores the previous dest when reload_cmd fails, and
+	// RollbackReload then runs reload_cmd again for the previous config.
+	Rollback       bool
+	RollbackReload bool `toml:"rollback_reload"`
+	// SELinuxContext is the SELinux context of dest, such as
+	// "system_u:object_r:httpd_config_t:s0". When unset, the context of the
+	// existing dest is kept.
+	SELinuxContext string `toml:"selinux_context"`
+	// Shell runs check_cmd and reload_cmd: cmd or powershell on Windows,
+	// or a shell taking -c. Defaults to /bin/sh, or cmd on Windows.
+	Shell     string
+	Splay     int
+	Src       string
+	StageFile *os.File
+	// StaleThreshold is the number of seconds after which a resource that
+	// was not synced successfully is reported as stale by /readyz.
+	StaleThreshold int `toml:"stale_threshold"`
+	Uid            int
+	actor          string
+	auditEntry     *audit.Entry
+	backend        string
+	credential     *credential
+	lockID         string
+	lockTTL        time.Duration
+	funcMap        map[string]interface{}
+	item           *Item
+	lastIndex      uint64
+	savedIndex     uint64
+	keepStageFile  bool
+	noop           bool
+	path           string
+	reading        *keyReads
+	// settings holds the TOML settings of the resource as decoded, before
+	// any runtime state is set, for fingerprint.
+	settings      []byte
+	reloadExit    *int
+	reads         *keyReads
+	skipUnchanged bool
+	trackDeps     bool
+	ctx           context.Context
+	passTimeout   time.Duration
+	notifier      notify.Notifier
+	event         *notify.Event
+	fromSnapshot  bool
+	stateDir      string
+	watchResume   string
 	store         memkv.Store
 	storeClient   backends.StoreClient
 	syncOnly      bool
+	templateDir   string
+	updated       bool
+	vars          map[string]string
+	// decrypted holds the values returned by the decrypt functions since
+	// the last pass started rendering.
+	decrypted []string
 }
 
 var ErrEmptySrc = errors.New("empty src template")
@@ -74,13 +257,54 @@ func NewTemplateResource(path string, config Config, project *Project) (*Templat
 	}
 
 	tr := tc.TemplateResource
+	tr.path = path
+	if tr.settings, err = json.Marshal(&tc.TemplateResource); err != nil {
+		return nil, fmt.Errorf("Cannot process template resource %s - %s", path, err.Error())
+	}
+	if tr.Name == "" {
+		tr.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
 	tr.keepStageFile = config.KeepStageFile
 	tr.noop = config.Noop
+	tr.notifier = config.Notifier
 	tr.storeClient = config.StoreClient
-	tr.funcMap = newFuncMap()
+	tr.backend = config.Backend
+	if tr.Backend != "" {
+		client, ok := config.StoreClients[tr.Backend]
+		if !ok {
+			return nil, fmt.Errorf("Cannot process template resource %s - unknown backend %s", path, tr.Backend)
+		}
+		tr.storeClient = client
+		tr.backend = tr.Backend
+	}
+	tr.funcMap = make(map[string]interface{})
+	if config.EnableSprig {
+		addFuncs(tr.funcMap, newSprigFuncMap())
+	}
+	addFuncs(tr.funcMap, newFuncMap())
+	decrypter := config.Decrypter
+	if decrypter == nil {
+		decrypter = &Decrypter{}
+	}
+	addFuncs(tr.funcMap, tr.observeDecrypted(decrypter.funcMap()))
+	addRegisteredFuncs(tr.funcMap)
 	tr.store = memkv.New()
 	tr.syncOnly = config.SyncOnly
+	tr.ctx = config.context()
+	tr.passTimeout = config.PassTimeout
+	tr.stateDir = config.StateDir
+	tr.watchResume = config.WatchResume[tr.backend]
+	tr.lockID = config.LockID
+	tr.lockTTL = config.LockTTL
+	tr.skipUnchanged = config.SkipUnchanged && !tr.AlwaysRender
+	if tr.StaleThreshold == 0 {
+		tr.StaleThreshold = config.StaleThreshold
+	}
+	if tr.CmdUser == "" && tr.CmdGroup == "" {
+		tr.CmdUser, tr.CmdGroup = config.CmdUser, config.CmdGroup
+	}
 	addFuncs(tr.funcMap, tr.store.FuncMap)
+	tr.trackReads()
 
 	var prefix string
 
@@ -92,10 +316,41 @@ func NewTemplateResource(path string, config Config, project *Project) (*Templat
 
 	tr.Prefix = filepath.Join("/", prefix, tr.Prefix)
 
-	if tr.Src == "" {
+	if !validEngine(tr.Engine) {
+		return nil, fmt.Errorf("Cannot process template resource %s - unknown engine %s", path, tr.Engine)
+	}
+
+	if tr.Format != "" && !validFormat(tr.Format) {
+		return nil, fmt.Errorf("Cannot process template resource %s - unknown format %s", path, tr.Format)
+	}
+
+	if err := tr.checkBinary(); err != nil {
+		return nil, fmt.Errorf("Cannot process template resource %s - %s", path, err.Error())
+	}
+
+	if err := tr.checkItems(); err != nil {
+		return nil, fmt.Errorf("Cannot process template resource %s - %s", path, err.Error())
+	}
+
+	if err := tr.checkCanary(); err != nil {
+		return nil, fmt.Errorf("Cannot process template resource %s - %s", path, err.Error())
+	}
+	if tr.DestPattern != "" {
+		tr.Dest = tr.DestPattern
+	}
+
+	if tr.Src == "" && tr.Format == "" && !tr.Binary {
 		return nil, ErrEmptySrc
 	}
 
+	if err := tr.checkReloadSignal(); err != nil {
+		return nil, fmt.Errorf("Cannot process template resource %s - %s", path, err.Error())
+	}
+
+	if tr.credential, err = lookupCredential(tr.CmdUser, tr.CmdGroup); err != nil {
+		return nil, fmt.Errorf("Cannot process template resource %s - %s", path, err.Error())
+	}
+
 	if tr.Uid == -1 {
 		tr.Uid = os.Geteuid()
 	}
@@ -107,9 +362,39 @@ func NewTemplateResource(path string, config Config, project *Project) (*Templat
 	return &tr, nil
 }
 
+// logger returns a log entry carrying the resource name, backend and key
+// prefix of t.
+func (t *TemplateResource) logger() *log.Entry {
+	return log.WithFields(log.Fields{"resource": t.Name, "backend": t.backend, "prefix": t.Prefix})
+}
+
+// interval returns the polling interval of t, which is def unless the
+// resource sets its own.
+func (t *TemplateResource) interval(def int) int {
+	if t.Interval > 0 {
+		return t.Interval
+	}
+	return def
+}
+
+// reloadDelay returns how long to wait before processing t after a watch
+// fired at now, given the last pass triggered by the watch at last.
+func (t *TemplateResource) reloadDelay(last, now time.Time) time.Duration {
+	var d time.Duration
+	if t.MinReloadInterval > 0 && !last.IsZero() {
+		if d = last.Add(time.Duration(t.MinReloadInterval) * time.Second).Sub(now); d < 0 {
+			d = 0
+		}
+	}
+	if t.Splay > 0 {
+		d += time.Duration(rand.Int63n(int64(time.Duration(t.Splay) * time.Second)))
+	}
+	return d
+}
+
 func (t *TemplateResource) GetAllKeys() []string {
-	log.Debug("Retrieving keys from store")
-	log.Debug("Key prefix set to " + t.Prefix)
+	t.logger().Debug("Retrieving keys from store")
+	t.logger().Debug("Key prefix set to " + t.Prefix)
 
 	keys := make([]string, len(t.Keys))
 	for i, k := range t.Keys {
@@ -122,40 +407,156 @@ func (t *TemplateResource) GetAllKeys() []string {
 	return keys
 }
 
-// setVars sets the Vars for template resource.
+// setVars sets the Vars for template resource. Values are streamed into the
+// store as they are read, unless expand_values needs them all at once.
 func (t *TemplateResource) setVars() error {
 
 	keys := t.GetAllKeys()
+	ctx, span := tracing.Start(t.ctx, "GetValues",
+		attribute.String("backend", t.backend),
+		attribute.Int("keys", len(keys)))
+	t.store.Purge()
+	t.logger().Debug("set store")
+	t.vars = make(map[string]string)
+	var sensitive []string
+	set := func(k, v string) error {
+		backendKey := k
+		k = filepath.Join("/", strings.TrimPrefix(k, t.Prefix))
+		// Patterns match the backend key or the key the template sees.
+		if redact.IsSensitive(backendKey) || redact.IsSensitive(k) {
+			sensitive = append(sensitive, v)
+		}
+		t.store.Set(k, v)
+		t.vars[k] = v
+		return nil
+	}
+	t.fromSnapshot = false
 	var err error
-	result, err := t.storeClient.GetValues(keys)
+	if t.ExpandValues {
+		var result map[string]string
+		if result, err = t.storeClient.GetValues(ctx, keys); err == nil {
+			expandValues(result)
+			for k, v := range result {
+				set(k, v)
+			}
+		}
+	} else {
+		err = backends.StreamValues(ctx, t.storeClient, keys, set)
+	}
 	if err != nil {
-		return err
+		s, ok := t.readSnapshot()
+		if !ok {
+			tracing.End(span, err)
+			return &backendError{err}
+		}
+		t.logger().Warning("Backend not reachable, rendering the snapshot of %s: %s", s.Time.Format(time.RFC3339), err.Error())
+		t.store.Purge()
+		t.vars = make(map[string]string)
+		sensitive = nil
+		for k, v := range s.Values {
+			set(k, v)
+		}
+		t.fromSnapshot = true
+	} else {
+		t.markLive()
 	}
+	span.SetAttributes(attribute.Int("values", len(t.vars)))
+	span.End()
 
-	t.store.Purge()
-	log.Debug("set store")
-	for k, v := range result {
-		t.store.Set(filepath.Join("/", strings.TrimPrefix(k, t.Prefix)), v)
-	}
+	redact.ObserveValues(t.Name, sensitive)
 	return nil
 }
 
+// render executes the src template against the values in the store and
+// returns the result.
+func (t *TemplateResource) render() ([]byte, error) {
+	t.reading = newKeyReads()
+	if t.Binary {
+		t.logger().Debug("Writing the decoded value of " + t.Keys[0])
+		b, err := t.renderBinary()
+		if err != nil {
+			return nil, err
+		}
+		metrics.TemplateRenders.WithLabelValues(t.Name).Inc()
+		return b, nil
+	}
+	if t.Format != "" && t.Src == "" {
+		t.logger().Debug("Rendering keys as " + t.Format)
+		b, err := renderFormat(t.Format, t.vars)
+		if err != nil {
+			return nil, err
+		}
+		metrics.TemplateRenders.WithLabelValues(t.Name).Inc()
+		return b, nil
+	}
+	t.logger().Debug("Using source template " + t.Src)
+
+	if t.Engine == enginePongo2 {
+		source, err := t.Source()
+		if err != nil {
+			return nil, err
+		}
+		t.logger().Debug("Compiling source template " + t.Src + " with pongo2")
+		b, err := t.renderPongo2(source, false)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to process template %s, %s", t.Src, err)
+		}
+		metrics.TemplateRenders.WithLabelValues(t.Name).Inc()
+		return b, nil
+	}
+
+	var tmpl *template.Template
+	if isRemoteSrc(t.Src) {
+		body, err := t.fetchRemoteSrc()
+		if err != nil {
+			return nil, err
+		}
+		t.logger().Debug("Compiling source template " + t.Src)
+		tmpl, err = template.New(path.Base(t.remoteSrcKey())).Funcs(t.funcMap).Parse(body)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to process template %s, %s", t.Src, err)
+		}
+	} else {
+		if !isFileExist(t.Src) {
+			return nil, errors.New("Missing template: " + t.Src)
+		}
+
+		t.logger().Debug("Compiling source template " + t.Src)
+		var err error
+		tmpl, err = template.New(path.Base(t.Src)).Funcs(t.funcMap).ParseFiles(t.Src)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to process template %s, %s", t.Src, err)
+		}
+	}
+
+	tmpl, err := t.addPartials(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to process partials of template %s, %s", t.Src, err)
+	}
+
+	var data interface{}
+	if t.item != nil {
+		data = t.item
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	metrics.TemplateRenders.WithLabelValues(t.Name).Inc()
+	return buf.Bytes(), nil
+}
+
 // createStageFile stages the src configuration file by processing the src
 // template and setting the desired owner, group, and mode. It also sets the
 // StageFile for the template resource.
 // It returns an error if any.
 func (t *TemplateResource) createStageFile() error {
-	log.Debug("Using source template " + t.Src)
-
-	if !isFileExist(t.Src) {
-		return errors.New("Missing template: " + t.Src)
-	}
-
-	log.Debug("Compiling source template " + t.Src)
-	tmpl, err := template.New(path.Base(t.Src)).Funcs(t.funcMap).ParseFiles(t.Src)
-
+	_, span := tracing.Start(t.ctx, "render", attribute.String("src", t.Src))
+	rendered, err := t.render()
+	tracing.End(span, err)
 	if err != nil {
-		return fmt.Errorf("Unable to process template %s, %s", t.Src, err)
+		t.logger().Error("execute temp file: %s, error: %s", t.Src, err.Error())
+		return err
 	}
 
 	// create TempFile in Dest directory to avoid cross-filesystem issues
@@ -164,18 +565,20 @@ func (t *TemplateResource) createStageFile() error {
 		return err
 	}
 
-	if err = tmpl.Execute(temp, nil); err != nil {
-		log.Error("execute temp file: %s, error: %s", t.Src, err.Error())
+	if _, err = temp.Write(rendered); err != nil {
 		temp.Close()
 		os.Remove(temp.Name())
 		return err
 	}
 	defer temp.Close()
 
-	// Set the owner, group, and mode on the stage file now to make it easier to
-	// compare against the destination configuration file later.
-	os.Chmod(temp.Name(), t.FileMode)
-	os.Chown(temp.Name(), t.Uid, t.Gid)
+	// Set the owner, group, mode, SELinux context and ACL on the stage file
+	// now to make it easier to compare against the destination configuration
+	// file later.
+	if err := t.setMetadata(temp.Name()); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
 	t.StageFile = temp
 	return nil
 }
@@ -188,32 +591,61 @@ func (t *TemplateResource) createStageFile() error {
 func (t *TemplateResource) sync() error {
 	staged := t.StageFile.Name()
 	if t.keepStageFile {
-		log.Info("Keeping staged file: " + staged)
+		t.logger().Info("Keeping staged file: " + staged)
 	} else {
 		defer os.Remove(staged)
 	}
 
-	log.Debug("Comparing candidate config to " + t.Dest)
+	t.logger().Debug("Comparing candidate config to " + t.Dest)
 	ok, err := sameConfig(staged, t.Dest)
 	if err != nil {
-		log.Error(err.Error())
+		t.logger().Error(err.Error())
 	}
 	if t.noop {
-		log.Warning("Noop mode enabled. " + t.Dest + " will not be modified")
+		t.logger().Warning("Noop mode enabled. " + t.Dest + " will not be modified")
+		if !ok {
+			t.logger().Warning("Target config " + t.Dest + " out of sync")
+			if err := t.printNoopDiff(staged); err != nil {
+				return err
+			}
+		}
 		return nil
 	}
 	if !ok {
-		log.Info("Target config " + t.Dest + " out of sync")
-		if !t.syncOnly && t.CheckCmd != "" {
-			if err := t.check(); err != nil {
-				return errors.New("Config check failed: " + err.Error())
+		t.logger().Info("Target config " + t.Dest + " out of sync")
+		t.startEvent(staged)
+		t.startAudit(staged)
+		switch {
+		case t.syncOnly:
+		case t.CanaryDest != "":
+			if err := t.canary(staged); err != nil {
+				return err
+			}
+		case t.CheckCmd != "":
+			if err := t.check(staged); err != nil {
+				return err
+			}
+		}
+		// Items are reloaded together by processItems.
+		reload := !t.syncOnly && t.item == nil && (t.ReloadCmd != "" || t.ReloadSignal != "")
+		if reload {
+			release, err := t.acquireReload()
+			if err != nil {
+				return err
+			}
+			defer release()
+		}
+		var backup string
+		if t.Backups > 0 || t.Rollback {
+			if backup, err = t.backupDest(); err != nil {
+				return err
 			}
 		}
-		log.Debug("Overwriting target config " + t.Dest)
-		err := os.Rename(staged, t.Dest)
+		t.logger().Debug("Overwriting target config " + t.Dest)
+		err := renameFile(staged, t.Dest)
 		if err != nil {
 			if strings.Contains(err.Error(), "device or resource busy") {
-				log.Debug("Rename failed - target is likely a mount. Trying to write instead")
+				t.logger().Debug("Rename failed - target is likely a mount. Trying to write instead")
 				// try to open the file and write to it
 				var contents []byte
 				var rerr error
@@ -222,67 +654,62 @@ func (t *TemplateResource) sync() error {
 					return rerr
 				}
 				err := ioutil.WriteFile(t.Dest, contents, t.FileMode)
-				// make sure owner and group match the temp file, in case the file was created with WriteFile
-				os.Chown(t.Dest, t.Uid, t.Gid)
 				if err != nil {
 					return err
 				}
+				// make sure the metadata matches the temp file, in case the file was created with WriteFile
+				if err := t.setMetadata(t.Dest); err != nil {
+					return err
+				}
 			} else {
 				return err
 			}
 		}
-		if !t.syncOnly && t.ReloadCmd != "" {
-			if err := t.reload(); err != nil {
+		t.updated = true
+		if reload {
+			err := t.reload()
+			t.setReloadResult(err)
+			if err != nil {
+				if t.Rollback {
+					t.restore(backup)
+				}
 				return err
 			}
 		}
-		log.Info("Target config " + t.Dest + " has been updated")
+		if t.Backups > 0 {
+			if err := t.pruneBackups(); err != nil {
+				t.logger().Warning("Cannot remove old backups: " + err.Error())
+			}
+		} else if backup != "" {
+			os.Remove(backup)
+		}
+		t.logger().Info("Target config " + t.Dest + " has been updated")
 	} else {
-		log.Debug("Target config " + t.Dest + " in sync")
+		t.logger().Debug("Target config " + t.Dest + " in sync")
 	}
 	return nil
 }
 
-// check executes the check command to validate the staged config file. The
-// command is modified so that any references to src template are substituted
-// with a string representing the full path of the staged file. This allows the
-// check to be run on the staged file before overwriting the destination config
-// file.
+// check executes the check command to validate the config file src, the
+// staged file or the canary. The command is modified so that any references
+// to src template are substituted with the full path of src. This allows the
+// check to be run before overwriting the destination config file.
 // It returns nil if the check command returns 0 and there are no other errors.
-func (t *TemplateResource) check() error {
-	var cmdBuffer bytes.Buffer
-	data := make(map[string]string)
-	data["src"] = t.StageFile.Name()
-	tmpl, err := template.New("checkcmd").Parse(t.CheckCmd)
+func (t *TemplateResource) check(src string) error {
+	cmd, err := expandCommand("checkcmd", t.CheckCmd, src)
 	if err != nil {
 		return err
 	}
-	if err := tmpl.Execute(&cmdBuffer, data); err != nil {
-		return err
-	}
-	log.Debug("Running " + cmdBuffer.String())
-	c := exec.Command("/bin/sh", "-c", cmdBuffer.String())
-	output, err := c.CombinedOutput()
-	if err != nil {
-		log.Error(fmt.Sprintf("%q", string(output)))
-		return err
-	}
-	log.Debug(fmt.Sprintf("%q", string(output)))
-	return nil
+	return t.runCommand("check", cmd)
 }
 
-// reload executes the reload command.
+// reload executes the reload command, or sends the reload signal.
 // It returns nil if the reload command returns 0.
 func (t *TemplateResource) reload() error {
-	log.Debug("Running " + t.ReloadCmd)
-	c := exec.Command("/bin/sh", "-c", t.ReloadCmd)
-	output, err := c.CombinedOutput()
-	if err != nil {
-		log.Error(fmt.Sprintf("%q", string(output)))
-		return err
+	if t.ReloadSignal != "" {
+		return t.signalReload()
 	}
-	log.Debug(fmt.Sprintf("%q", string(output)))
-	return nil
+	return t.runCommand("reload", t.ReloadCmd)
 }
 
 // process is a convenience function that wraps calls to the three main tasks
@@ -290,19 +717,76 @@ func (t *TemplateResource) reload() error {
 // from the store, then we stage a candidate configuration file, and finally sync
 // things up.
 // It returns an error if any.
-func (t *TemplateResource) process() error {
+func (t *TemplateResource) process() (err error) {
+	defer lockDest(t.Dest)()
+	parent := t.ctx
+	ctx, span := tracing.Start(parent, "process",
+		attribute.String("resource", t.Name),
+		attribute.String("dest", t.Dest))
+	if t.passTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.passTimeout)
+		defer cancel()
+	}
+	t.ctx = ctx
+	defer func() {
+		tracing.End(span, err)
+		t.ctx = parent
+	}()
+	// processItems notifies and audits the items itself.
+	itemsReported := false
+	defer func() {
+		t.recordStatus(err)
+		if !itemsReported {
+			t.notify(err)
+			t.recordAudit(err)
+		}
+	}()
+	t.updated = false
 	if err := t.setFileMode(); err != nil {
 		return err
 	}
+	previous := t.vars
 	if err := t.setVars(); err != nil {
+		t.updateReads(err)
 		return err
 	}
-	if err := t.createStageFile(); err != nil {
-		return err
+	changes := t.keyChanges()
+	defer func() { t.recordChanges(changes) }()
+	defer func() {
+		if err == nil {
+			t.writeSnapshot()
+		}
+	}()
+	if t.trackDeps && !t.affected(previous) {
+		t.logger().Debug("No key read by the template changed, skipping")
+		metrics.RendersSkipped.WithLabelValues(t.Name).Inc()
+		return nil
 	}
-	if err := t.sync(); err != nil {
-		return err
+	fingerprint := t.fingerprint()
+	if t.unchanged(fingerprint) {
+		t.logger().Debug("Values, templates and dest unchanged since the last pass, skipping")
+		metrics.RendersSkipped.WithLabelValues(t.Name).Inc()
+		return nil
+	}
+	defer func() { t.recordFingerprint(err) }()
+	defer func() { t.updateReads(err) }()
+	t.decrypted = nil
+	defer t.observeDecryptedValues()
+	if t.DestPattern != "" {
+		itemsReported = true
+		if err := t.processItems(); err != nil {
+			return err
+		}
+	} else {
+		if err := t.createStageFile(); err != nil {
+			return err
+		}
+		if err := t.sync(); err != nil {
+			return err
+		}
 	}
+	metrics.LastSync.WithLabelValues(t.Name).SetToCurrentTime()
 	return nil
 }
 
@@ -323,7 +807,7 @@ func (t *TemplateResource) setFileMode() error {
 		if err != nil {
 			return err
 		}
-		t.FileMode = os.FileMode(mode)
+		t.FileMode = fileModeFromUnix(uint32(mode))
 	}
 	return nil
 }
@@ -31,6 +31,12 @@ func createTempDirs() (string, error) {
 	return confDir, nil
 }
 
+var projectConfigTmpl = `
+[project]
+name = "foo"
+conf_dir = "{{.conf_dir}}"
+`
+
 var templateResourceConfigTmpl = `
 [template]
 src = "{{.src}}"
@@ -81,16 +87,33 @@ func TestProcessTemplateResources(t *testing.T) {
 		t.Errorf(err.Error())
 	}
 
+	// Create the project pointing to the conf dir.
+	projectsDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Errorf("Failed to create projects dir: %s", err.Error())
+	}
+	defer os.RemoveAll(projectsDir)
+	projectFile, err := os.Create(filepath.Join(projectsDir, "foo.toml"))
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	tmpl, err = template.New("projectConfig").Parse(projectConfigTmpl)
+	if err != nil {
+		t.Errorf("Unable to parse project template: %s", err.Error())
+	}
+	err = tmpl.Execute(projectFile, map[string]string{"conf_dir": tempConfDir})
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
 	os.Setenv("FOO", "bar")
 	storeClient, err := env.NewEnvClient()
 	if err != nil {
 		t.Errorf(err.Error())
 	}
 	c := Config{
-		ConfDir:     tempConfDir,
-		ConfigDir:   filepath.Join(tempConfDir, "conf.d"),
+		ConfDir:     projectsDir,
 		StoreClient: storeClient,
-		TemplateDir: filepath.Join(tempConfDir, "templates"),
 	}
 	// Process the test template resource.
 	err = Process(c)
@@ -0,0 +1,57 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kelseyhightower/confd/backends"
+	"github.com/kelseyhightower/confd/log"
+)
+
+// indexFile returns the file holding the watch index of t, or "" when the
+// watch of t resumes from the current index after a restart.
+func (t *TemplateResource) indexFile() string {
+	if t.stateDir == "" || t.watchResume != backends.WatchResumeOldest {
+		return ""
+	}
+	return filepath.Join(t.stateDir, strings.Replace(t.Name, "/", "_", -1)+".index")
+}
+
+// resumeIndex returns the watch index persisted by the last successful pass
+// over t before a restart, or 0.
+func (t *TemplateResource) resumeIndex() uint64 {
+	file := t.indexFile()
+	if file == "" {
+		return 0
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			t.logger().Warning("Cannot read watch index " + file + ": " + err.Error())
+		}
+		return 0
+	}
+	index, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		t.logger().Warning("Cannot parse watch index " + file + ": " + err.Error())
+		return 0
+	}
+	return index
+}
+
+// saveIndex persists the watch index of t, processed successfully, unless
+// it is the one persisted already.
+func (t *TemplateResource) saveIndex() {
+	file := t.indexFile()
+	if file == "" || t.lastIndex == t.savedIndex {
+		return
+	}
+	if err := writeStateFile(file, []byte(strconv.FormatUint(t.lastIndex, 10)+"\n")); err != nil {
+		log.Warning("Cannot write watch index %s: %s", file, err.Error())
+		return
+	}
+	t.savedIndex = t.lastIndex
+}
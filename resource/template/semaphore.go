@@ -0,0 +1,39 @@
+package template
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kelseyhightower/confd/leader"
+)
+
+// defaultLockTTL is the TTL of the reload_semaphore locks unless
+// Config.LockTTL is set.
+const defaultLockTTL = 15 * time.Second
+
+// acquireReload waits until this replica holds a slot of the
+// reload_semaphore of t, if any, and returns the function releasing it.
+func (t *TemplateResource) acquireReload() (func(), error) {
+	if t.ReloadSemaphore == "" {
+		return func() {}, nil
+	}
+	id := t.lockID
+	if id == "" {
+		hostname, _ := os.Hostname()
+		id = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	// Resources of one instance sharing a semaphore hold separate slots.
+	id += "/" + t.Name
+	ttl := t.lockTTL
+	if ttl <= 0 {
+		ttl = defaultLockTTL
+	}
+	s := leader.NewSemaphore(t.storeClient, t.ReloadSemaphore, id, t.ReloadConcurrency, ttl)
+	release, err := s.Acquire(t.ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.logger().Debug("Holding a slot of " + t.ReloadSemaphore)
+	return release, nil
+}
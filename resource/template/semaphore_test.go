@@ -0,0 +1,45 @@
+package template
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kelseyhightower/confd/backends/memory"
+	"github.com/kelseyhightower/confd/log"
+)
+
+func TestAcquireReloadPerResource(t *testing.T) {
+	log.SetLevel("warn")
+	client := memory.NewMemoryClient(nil)
+	resource := func(name string) *TemplateResource {
+		return &TemplateResource{
+			Name:              name,
+			ReloadSemaphore:   "/confd/semaphores/app",
+			ReloadConcurrency: 1,
+			storeClient:       client,
+			lockID:            "host-1",
+			ctx:               context.Background(),
+		}
+	}
+	release, err := resource("first").acquireReload()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Another resource of the same instance waits for the slot.
+	second := resource("second")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	second.ctx = ctx
+	if _, err := second.acquireReload(); err == nil {
+		t.Fatal("Expected the second resource not to share the slot of the first")
+	}
+	release()
+	second.ctx = context.Background()
+	release, err = second.acquireReload()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	release()
+}
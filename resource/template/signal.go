@@ -0,0 +1,116 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kelseyhightower/confd/metrics"
+	"github.com/kelseyhightower/confd/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxCommLength is the length at which the kernel truncates process names.
+const maxCommLength = 15
+
+// checkReloadSignal validates the reload_signal settings of t.
+func (t *TemplateResource) checkReloadSignal() error {
+	if t.ReloadSignal == "" {
+		if t.ReloadPidfile != "" || t.ReloadProcess != "" {
+			return errors.New("reload_pidfile and reload_process require reload_signal")
+		}
+		return nil
+	}
+	if t.ReloadCmd != "" {
+		return errors.New("reload_cmd and reload_signal are mutually exclusive")
+	}
+	if (t.ReloadPidfile == "") == (t.ReloadProcess == "") {
+		return errors.New("reload_signal requires one of reload_pidfile or reload_process")
+	}
+	_, err := parseSignal(t.ReloadSignal)
+	return err
+}
+
+// signalReload sends reload_signal to the process of reload_pidfile, or to
+// every process called reload_process.
+func (t *TemplateResource) signalReload() (err error) {
+	_, span := tracing.Start(t.ctx, "reload_signal", attribute.String("signal", t.ReloadSignal))
+	defer func() { tracing.End(span, err) }()
+	defer func() {
+		status := exitStatus(err)
+		t.reloadExit = &status
+		if err != nil {
+			metrics.CommandFailures.WithLabelValues(t.Name, "reload").Inc()
+			err = &CommandError{Resource: t.Name, Command: "reload_signal", Attempts: 1, Err: err}
+		}
+	}()
+	sig, err := parseSignal(t.ReloadSignal)
+	if err != nil {
+		return err
+	}
+	var pids []int
+	if t.ReloadPidfile != "" {
+		pid, err := readPidfile(t.ReloadPidfile)
+		if err != nil {
+			return err
+		}
+		pids = []int{pid}
+	} else if pids, err = findProcesses(t.ReloadProcess); err != nil {
+		return err
+	}
+	for _, pid := range pids {
+		t.logger().Debug("Sending %s to process %d", t.ReloadSignal, pid)
+		if err := signalProcess(pid, sig); err != nil {
+			return fmt.Errorf("cannot signal process %d: %s", pid, err.Error())
+		}
+	}
+	return nil
+}
+
+// readPidfile returns the process ID written in the pidfile at path.
+func readPidfile(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("%s does not hold a process ID", path)
+	}
+	return pid, nil
+}
+
+// findProcesses returns the IDs of the processes called name, as listed in
+// /proc.
+func findProcesses(name string) ([]int, error) {
+	dirs, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("cannot list processes: %s", err.Error())
+	}
+	comm := name
+	if len(comm) > maxCommLength {
+		comm = comm[:maxCommLength]
+	}
+	var pids []int
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(dir.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join("/proc", dir.Name(), "comm"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(data)) == comm {
+			pids = append(pids, pid)
+		}
+	}
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("no process called %s", name)
+	}
+	return pids, nil
+}
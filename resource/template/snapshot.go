@@ -0,0 +1,133 @@
+package template
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kelseyhightower/confd/log"
+)
+
+// snapshot holds the values read by the last successful pass over a
+// template resource, keyed by backend key.
+type snapshot struct {
+	Time   time.Time         `json:"timestamp"`
+	Values map[string]string `json:"values"`
+}
+
+var (
+	snapshotMu sync.Mutex
+	// liveResources holds the names of the resources that read the backend
+	// successfully since confd started: they no longer fall back on their
+	// snapshot.
+	liveResources = make(map[string]bool)
+)
+
+// snapshotFile returns the file holding the snapshot of t, or "" when t
+// keeps none.
+func (t *TemplateResource) snapshotFile() string {
+	if t.stateDir == "" {
+		return ""
+	}
+	return filepath.Join(t.stateDir, strings.Replace(t.Name, "/", "_", -1)+".json")
+}
+
+// markLive records that t read the backend successfully.
+func (t *TemplateResource) markLive() {
+	snapshotMu.Lock()
+	liveResources[t.Name] = true
+	snapshotMu.Unlock()
+}
+
+// readSnapshot returns the snapshot of t when t keeps one and never read
+// the backend successfully since confd started.
+func (t *TemplateResource) readSnapshot() (*snapshot, bool) {
+	file := t.snapshotFile()
+	if file == "" {
+		return nil, false
+	}
+	snapshotMu.Lock()
+	live := liveResources[t.Name]
+	snapshotMu.Unlock()
+	if live {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			t.logger().Warning("Cannot read snapshot " + file + ": " + err.Error())
+		}
+		return nil, false
+	}
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.logger().Warning("Cannot parse snapshot " + file + ": " + err.Error())
+		return nil, false
+	}
+	return &s, true
+}
+
+// writeSnapshot writes the values read by the pass over t to its snapshot,
+// unless they are the ones it already holds.
+func (t *TemplateResource) writeSnapshot() {
+	file := t.snapshotFile()
+	if file == "" || t.fromSnapshot {
+		return
+	}
+	values := make(map[string]string, len(t.vars))
+	for k, v := range t.vars {
+		values[path.Join("/", t.Prefix, k)] = v
+	}
+	if current, err := ioutil.ReadFile(file); err == nil {
+		var s snapshot
+		if json.Unmarshal(current, &s) == nil && sameValues(s.Values, values) {
+			return
+		}
+	}
+	data, err := json.Marshal(snapshot{Time: time.Now(), Values: values})
+	if err == nil {
+		err = writeStateFile(file, data)
+	}
+	if err != nil {
+		log.Warning("Cannot write snapshot %s: %s", file, err.Error())
+	}
+}
+
+func sameValues(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+// writeStateFile atomically replaces file with data, readable by its owner
+// only since snapshots hold secrets in clear.
+func writeStateFile(file string, data []byte) error {
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(dir, "."+filepath.Base(file))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	_, err = temp.Write(data)
+	if cerr := temp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), file)
+}
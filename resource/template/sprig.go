@@ -0,0 +1,14 @@
+package template
+
+import (
+	"github.com/Masterminds/sprig"
+)
+
+// newSprigFuncMap returns the Sprig functions. Built-in functions of the
+// same name, such as split or replace, take precedence over them so that
+// existing templates keep working.
+func newSprigFuncMap() map[string]interface{} {
+	m := make(map[string]interface{})
+	addFuncs(m, sprig.TxtFuncMap())
+	return m
+}
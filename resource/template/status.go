@@ -0,0 +1,164 @@
+package template
+
+import (
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kelseyhightower/confd/audit"
+	"github.com/kelseyhightower/confd/redact"
+)
+
+// Results of a processing pass over a template resource.
+const (
+	ResultUpdated   = "updated"
+	ResultUnchanged = "unchanged"
+	ResultFailed    = "failed"
+)
+
+// historySize is the number of passes kept in the history of a resource.
+const historySize = 20
+
+// SyncRecord is the outcome of one processing pass over a template resource.
+type SyncRecord struct {
+	Time   time.Time `json:"time"`
+	Result string    `json:"result"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// ResourceStatus describes the outcome of processing a template resource.
+type ResourceStatus struct {
+	Name        string    `json:"name"`
+	Dest        string    `json:"dest"`
+	LastRun     time.Time `json:"last_run"`
+	LastSuccess time.Time `json:"last_success"`
+	LastResult  string    `json:"last_result,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+	// LastCommandError is the last failure of check_cmd or reload_cmd.
+	LastCommandError string `json:"last_command_error,omitempty"`
+	// LastReloadExitStatus is the exit status of the last reload_cmd, -1
+	// when it could not be started or timed out.
+	LastReloadExitStatus *int          `json:"last_reload_exit_status,omitempty"`
+	StaleThreshold       time.Duration `json:"stale_threshold"`
+	// Degraded is set while the resource is rendered from its snapshot
+	// because the backend is down.
+	Degraded bool `json:"degraded,omitempty"`
+	// DestChecksum is the SHA-256 checksum of dest, and Keys the keys the
+	// resource depends on. Both are only set by TemplateResource.Status.
+	DestChecksum string   `json:"dest_checksum,omitempty"`
+	Keys         []string `json:"keys,omitempty"`
+	// History holds the latest passes, oldest first.
+	History []SyncRecord `json:"history"`
+}
+
+// clone returns a copy of s not sharing its history.
+func (s *ResourceStatus) clone() ResourceStatus {
+	c := *s
+	c.History = append([]SyncRecord(nil), s.History...)
+	return c
+}
+
+// Stale reports whether the resource has not been synced successfully
+// within its stale threshold. Resources without a threshold are never stale.
+func (s ResourceStatus) Stale(now time.Time) bool {
+	if s.StaleThreshold <= 0 {
+		return false
+	}
+	last := s.LastSuccess
+	if last.IsZero() {
+		last = startTime
+	}
+	return now.Sub(last) > s.StaleThreshold
+}
+
+var (
+	startTime = time.Now()
+
+	statusMu    sync.RWMutex
+	statuses    = make(map[string]*ResourceStatus)
+	lastSuccess time.Time
+)
+
+// recordStatus records the result of processing t.
+func (t *TemplateResource) recordStatus(err error) {
+	now := time.Now()
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	s, ok := statuses[t.Name]
+	if !ok {
+		s = &ResourceStatus{Name: t.Name}
+		statuses[t.Name] = s
+	}
+	s.Dest = t.Dest
+	s.StaleThreshold = time.Duration(t.StaleThreshold) * time.Second
+	s.LastRun = now
+	s.Degraded = t.fromSnapshot
+	if t.reloadExit != nil {
+		s.LastReloadExitStatus = t.reloadExit
+		t.reloadExit = nil
+	}
+	record := SyncRecord{Time: now, Result: ResultUnchanged}
+	if t.updated {
+		record.Result = ResultUpdated
+	}
+	if err != nil {
+		record.Result = ResultFailed
+		record.Error = redact.Text(err.Error())
+	}
+	s.LastResult = record.Result
+	s.History = append(s.History, record)
+	if len(s.History) > historySize {
+		s.History = s.History[len(s.History)-historySize:]
+	}
+	if err != nil {
+		s.LastError = record.Error
+		if _, ok := err.(*CommandError); ok {
+			s.LastCommandError = s.LastError
+		}
+		return
+	}
+	s.LastError = ""
+	s.LastSuccess = now
+	lastSuccess = now
+}
+
+// Statuses returns the status of every template resource processed so far,
+// sorted by name.
+func Statuses() []ResourceStatus {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+	result := make([]ResourceStatus, 0, len(statuses))
+	for _, s := range statuses {
+		result = append(result, s.clone())
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Status returns the status of t, with its keys and the checksum of its
+// current dest, whether it was processed yet or not.
+func (t *TemplateResource) Status() ResourceStatus {
+	status := ResourceStatus{History: []SyncRecord{}}
+	statusMu.RLock()
+	if s, ok := statuses[t.Name]; ok {
+		status = s.clone()
+	}
+	statusMu.RUnlock()
+	status.Name = t.Name
+	status.Dest = t.Dest
+	status.StaleThreshold = time.Duration(t.StaleThreshold) * time.Second
+	status.Keys = t.GetAllKeys()
+	if data, err := ioutil.ReadFile(t.Dest); err == nil {
+		status.DestChecksum = audit.Checksum(data, true)
+	}
+	return status
+}
+
+// LastSuccessfulRun returns when a template resource was last synced
+// successfully. It is zero if none was.
+func LastSuccessfulRun() time.Time {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+	return lastSuccess
+}
@@ -0,0 +1,75 @@
+package template
+
+import (
+	"sync"
+)
+
+// SyncResult is the outcome of processing one template resource on request.
+type SyncResult struct {
+	Name  string `json:"name"`
+	Dest  string `json:"dest"`
+	Error string `json:"error,omitempty"`
+}
+
+// A SyncRequest asks the running Processor for an immediate, out-of-band
+// processing pass of the resource called Resource, or of every resource when
+// Resource is empty. The results are sent on Done.
+type SyncRequest struct {
+	Resource string
+	// Actor is the admin user asking for the pass, recorded in the audit
+	// log.
+	Actor string
+	Done  chan []SyncResult
+}
+
+// NewSyncRequest returns a SyncRequest for resource.
+func NewSyncRequest(resource string) *SyncRequest {
+	return &SyncRequest{Resource: resource, Done: make(chan []SyncResult, 1)}
+}
+
+// serveSyncRequest processes the resources selected by req and reports the
+// results.
+func serveSyncRequest(config Config, req *SyncRequest) {
+	results := make([]SyncResult, 0)
+	ts, err := getTemplateResources(config)
+	if err != nil && len(ts) == 0 {
+		results = append(results, SyncResult{Name: req.Resource, Error: err.Error()})
+		req.Done <- results
+		return
+	}
+	selected := make([]*TemplateResource, 0, len(ts))
+	for _, t := range ts {
+		if req.Resource == "" || t.Name == req.Resource {
+			t.actor = req.Actor
+			selected = append(selected, t)
+		}
+	}
+	for i, err := range processAll(config, selected, nil) {
+		result := SyncResult{Name: selected[i].Name, Dest: selected[i].Dest}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	req.Done <- results
+}
+
+// destLocks serializes processing of template resources sharing a
+// destination, e.g. a watch triggered pass and a requested sync.
+var destLocks = struct {
+	sync.Mutex
+	m map[string]*sync.Mutex
+}{m: make(map[string]*sync.Mutex)}
+
+// lockDest locks dest and returns the function unlocking it.
+func lockDest(dest string) func() {
+	destLocks.Lock()
+	l, ok := destLocks.m[dest]
+	if !ok {
+		l = &sync.Mutex{}
+		destLocks.m[dest] = l
+	}
+	destLocks.Unlock()
+	l.Lock()
+	return l.Unlock
+}
@@ -8,8 +8,11 @@ import (
 	"os"
 	"path"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/kelseyhightower/memkv"
 )
 
 func newFuncMap() map[string]interface{} {
@@ -18,6 +21,8 @@ func newFuncMap() map[string]interface{} {
 	m["split"] = strings.Split
 	m["json"] = UnmarshalJsonObject
 	m["jsonArray"] = UnmarshalJsonArray
+	m["jsonGet"] = JsonGet
+	m["yamlGet"] = YamlGet
 	m["dir"] = path.Dir
 	m["map"] = CreateMap
 	m["getenv"] = Getenv
@@ -30,6 +35,8 @@ func newFuncMap() map[string]interface{} {
 	m["lookupIP"] = LookupIP
 	m["lookupSRV"] = LookupSRV
 	m["fileExists"] = isFileExist
+	m["base64Decode"] = Base64Decode
+	m["base64Encode"] = Base64Encode
 	return m
 }
 
@@ -123,3 +130,30 @@ func LookupSRV(service, proto, name string) []*net.SRV {
 	sort.Sort(sortSRV(addrs))
 	return addrs
 }
+
+// defaultHealthKey is the sub-key of the nodes of a service read by
+// healthyNodes unless another one is given.
+const defaultHealthKey = "healthy"
+
+// healthyNodes returns the sorted names of the nodes below dir, such as
+// /services/web, whose health sub-key, /services/web/<node>/healthy unless
+// healthKey is given, holds a true value such as "true" or "1". Nodes
+// without the sub-key are left out.
+func healthyNodes(s *memkv.Store, dir string, healthKey ...string) []string {
+	key := defaultHealthKey
+	if len(healthKey) > 0 {
+		key = healthKey[0]
+	}
+	nodes := make([]string, 0)
+	for _, node := range s.ListDir(dir) {
+		v, err := s.GetValue(path.Join(dir, node, key))
+		if err != nil {
+			continue
+		}
+		if ok, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil && ok {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes
+}
@@ -0,0 +1,170 @@
+package template
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kelseyhightower/memkv"
+)
+
+func TestExpandValues(t *testing.T) {
+	tests := []struct {
+		desc     string
+		vars     map[string]string
+		expected map[string]string
+	}{
+		{
+			desc:     "json object",
+			vars:     map[string]string{"/app/config": `{"db": {"host": "db1", "port": 5432}}`},
+			expected: map[string]string{"/app/config": `{"db": {"host": "db1", "port": 5432}}`, "/app/config/db/host": "db1", "/app/config/db/port": "5432"},
+		},
+		{
+			desc:     "json array",
+			vars:     map[string]string{"/app/hosts": `["a", "b"]`},
+			expected: map[string]string{"/app/hosts": `["a", "b"]`, "/app/hosts/0": "a", "/app/hosts/1": "b"},
+		},
+		{
+			desc:     "yaml object",
+			vars:     map[string]string{"/app/config": "db:\n  host: db1\n  user: null\n"},
+			expected: map[string]string{"/app/config": "db:\n  host: db1\n  user: null\n", "/app/config/db/host": "db1", "/app/config/db/user": ""},
+		},
+		{
+			desc:     "scalars",
+			vars:     map[string]string{"/app/name": "confd", "/app/port": "8080", "/app/empty": ""},
+			expected: map[string]string{"/app/name": "confd", "/app/port": "8080", "/app/empty": ""},
+		},
+		{
+			desc:     "existing keys are kept",
+			vars:     map[string]string{"/app/config": `{"host": "db1"}`, "/app/config/host": "db2"},
+			expected: map[string]string{"/app/config": `{"host": "db1"}`, "/app/config/host": "db2"},
+		},
+	}
+	for _, tt := range tests {
+		expandValues(tt.vars)
+		if !reflect.DeepEqual(tt.vars, tt.expected) {
+			t.Errorf("%s: expandValues() = %v, want %v", tt.desc, tt.vars, tt.expected)
+		}
+	}
+}
+
+func TestJsonYamlGet(t *testing.T) {
+	jsonDoc := `{"db": {"host": "db1", "port": 5432}, "hosts": ["a", "b"]}`
+	yamlDoc := "db:\n  host: db1\n  port: 5432\nhosts:\n- a\n- b\n"
+	tests := []struct {
+		path      string
+		jsonValue interface{}
+		yamlValue interface{}
+	}{
+		{"/db/host", "db1", "db1"},
+		{"db/port", float64(5432), 5432},
+		{"/hosts/1", "b", "b"},
+		{"/hosts/2", nil, nil},
+		{"/db/user", nil, nil},
+		{"/db/host/name", nil, nil},
+	}
+	for _, tt := range tests {
+		got, err := JsonGet(jsonDoc, tt.path)
+		if tt.jsonValue == nil {
+			if err == nil {
+				t.Errorf("JsonGet(%q) = %v, want an error", tt.path, got)
+			}
+		} else if err != nil || got != tt.jsonValue {
+			t.Errorf("JsonGet(%q) = %v, %v, want %v", tt.path, got, err, tt.jsonValue)
+		}
+		got, err = YamlGet(yamlDoc, tt.path)
+		if tt.yamlValue == nil {
+			if err == nil {
+				t.Errorf("YamlGet(%q) = %v, want an error", tt.path, got)
+			}
+		} else if err != nil || got != tt.yamlValue {
+			t.Errorf("YamlGet(%q) = %v, %v, want %v", tt.path, got, err, tt.yamlValue)
+		}
+	}
+	if _, err := JsonGet("{", "/db"); err == nil {
+		t.Error("JsonGet() of invalid JSON did not fail")
+	}
+}
+
+func TestEnvQuote(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"plain", "plain"},
+		{"", `""`},
+		{"two words", `"two words"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{"$HOME", `"\$HOME"`},
+		{"a`b`", "\"a\\`b\\`\""},
+		{`back\slash`, `"back\\slash"`},
+		{"line\nbreak", `"line\nbreak"`},
+		{"a;b", `"a;b"`},
+	}
+	for _, tt := range tests {
+		if got := envQuote(tt.value); got != tt.expected {
+			t.Errorf("envQuote(%q) = %s, want %s", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestPropertiesEscape(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"plain", "plain"},
+		{"a=b", `a\=b`},
+		{"host:port", `host\:port`},
+		{"#comment", `\#comment`},
+		{"!bang", `\!bang`},
+		{`C:\dir`, `C\:\\dir`},
+		{"line\nbreak\ttab\r", `line\nbreak\ttab\r`},
+	}
+	for _, tt := range tests {
+		if got := propertiesEscape(tt.value); got != tt.expected {
+			t.Errorf("propertiesEscape(%q) = %s, want %s", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestNest(t *testing.T) {
+	vars := map[string]string{
+		"/db/host":      "db1",
+		"/db/port":      "5432",
+		"/name":         "confd",
+		"/app":          "shadowed",
+		"/app/replicas": "3",
+	}
+	expected := map[string]interface{}{
+		"db":   map[string]interface{}{"host": "db1", "port": "5432"},
+		"name": "confd",
+		"app":  map[string]interface{}{"replicas": "3"},
+	}
+	if got := nest(vars); !reflect.DeepEqual(got, expected) {
+		t.Errorf("nest() = %v, want %v", got, expected)
+	}
+}
+
+func TestHealthyNodes(t *testing.T) {
+	s := memkv.New()
+	for k, v := range map[string]string{
+		"/services/web/node1/healthy": "true",
+		"/services/web/node2/healthy": "false",
+		"/services/web/node3/healthy": " 1 ",
+		"/services/web/node4/addr":    "10.0.0.4",
+		"/services/web/node5/healthy": "maybe",
+		"/services/web/node5/up":      "true",
+		"/services/web/node1/up":      "false",
+	} {
+		s.Set(k, v)
+	}
+	if got, expected := healthyNodes(&s, "/services/web"), []string{"node1", "node3"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("healthyNodes() = %v, want %v", got, expected)
+	}
+	if got, expected := healthyNodes(&s, "/services/web", "up"), []string{"node5"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("healthyNodes() with the up key = %v, want %v", got, expected)
+	}
+	if got := healthyNodes(&s, "/services/db"); got == nil || len(got) != 0 {
+		t.Errorf("Expected no nodes below a missing dir, got %v", got)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/kelseyhightower/confd/backends"
+	"github.com/kelseyhightower/confd/log"
 )
 
 const (
@@ -20,6 +22,7 @@ type templateTest struct {
 	tmpl        string                  // template file contents
 	expected    string                  // expected generated file contents
 	updateStore func(*TemplateResource) // function for setting values in store
+	partials    map[string]string       // other files of the templates directory
 }
 
 // templateTests is an array of templateTest structs, each representing a test of
@@ -395,6 +398,98 @@ ip: 127.0.0.1
 			tr.store.Set("/test/data/def", "child")
 		},
 	},
+
+	templateTest{
+		desc: "pongo2 getv test",
+		toml: `
+[template]
+src = "test.conf.tmpl"
+dest = "./tmp/test.conf"
+engine = "pongo2"
+keys = [
+    "/test/url",
+    "/test/user",
+]
+`,
+		tmpl: `
+url = {{ getv("/test/url") }}
+user = {{ getv("/test/user")|upper }}
+port = {{ getv("/test/port", "80") }}
+`,
+		expected: `
+url = http://www.abc.com
+user = BOB
+port = 80
+`,
+		updateStore: func(tr *TemplateResource) {
+			tr.store.Set("/test/url", "http://www.abc.com")
+			tr.store.Set("/test/user", "bob")
+		},
+	},
+
+	templateTest{
+		desc: "pongo2 gets test",
+		toml: `
+[template]
+src = "test.conf.tmpl"
+dest = "./tmp/test.conf"
+engine = "pongo2"
+keys = [
+    "/test/user",
+    "/test/pass",
+    "/nada/url",
+]
+`,
+		tmpl: `
+{% for kv in gets("/test/*") %}
+key: {{ kv.Key }}
+val: {{ kv.Value }}
+{% endfor %}
+`,
+		expected: `
+
+key: /test/pass
+val: abc
+
+key: /test/user
+val: mary
+
+`,
+		updateStore: func(tr *TemplateResource) {
+			tr.store.Set("/test/user", "mary")
+			tr.store.Set("/test/pass", "abc")
+			tr.store.Set("/nada/url", "url")
+		},
+	},
+
+	templateTest{
+		desc: "pongo2 include test",
+		toml: `
+[template]
+src = "test.conf.tmpl"
+dest = "./tmp/test.conf"
+engine = "pongo2"
+keys = [
+    "/test/upstreams",
+]
+`,
+		tmpl: `
+{% include "_partials/upstreams.j2" %}
+`,
+		partials: map[string]string{
+			"_partials/upstreams.j2": `{% for kv in gets("/test/upstreams/*") %}upstream {{ kv.Key|cut:"/test/upstreams/" }} {{ kv.Value }};
+{% endfor %}`,
+		},
+		expected: `
+upstream a 10.0.0.1;
+upstream b 10.0.0.2;
+
+`,
+		updateStore: func(tr *TemplateResource) {
+			tr.store.Set("/test/upstreams/a", "10.0.0.1")
+			tr.store.Set("/test/upstreams/b", "10.0.0.2")
+		},
+	},
 }
 
 // TestTemplates runs all tests in templateTests
@@ -404,6 +499,43 @@ func TestTemplates(t *testing.T) {
 	}
 }
 
+// TestPongo2Items renders a pongo2 template once per item of dest_pattern.
+func TestPongo2Items(t *testing.T) {
+	log.SetLevel("warn")
+	dir, err := ioutil.TempDir("", "pongo2")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("PONGO2ITEMS_WEB_PORT", "80")
+	defer os.Unsetenv("PONGO2ITEMS_WEB_PORT")
+	os.Setenv("PONGO2ITEMS_API_PORT", "8080")
+	defer os.Unsetenv("PONGO2ITEMS_API_PORT")
+	src := filepath.Join(dir, "test.j2")
+	if err := ioutil.WriteFile(src, []byte(`{{ item.Name }} = {{ getv(item.Key|add:"/port") }}`), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	tr := newTestResource(t, dir, `
+[template]
+src = "test.j2"
+engine = "pongo2"
+dest_pattern = "`+filepath.Join(dir, "{{.Name}}.conf")+`"
+items = "/pongo2items/*"
+keys = ["/pongo2items"]
+`)
+	tr.Dest = ""
+	tr.Src = src
+	defer tr.setItemDests(nil)
+	if err := tr.process(); err != nil {
+		t.Fatal(err.Error())
+	}
+	for name, expected := range map[string]string{"web": "web = 80", "api": "api = 8080"} {
+		if got := readFile(t, filepath.Join(dir, name+".conf")); got != expected {
+			t.Errorf("Expected %s.conf %s, got %s", name, expected, got)
+		}
+	}
+}
+
 // ExectureTestTemplate builds a TemplateResource based on the toml and tmpl files described
 // in the templateTest, writes a config file, and compares the result against the expectation
 // in the templateTest.
@@ -448,6 +580,15 @@ func setupDirectoriesAndFiles(tt templateTest, t *testing.T) {
 	if err := ioutil.WriteFile(tmplFilePath, []byte(tt.tmpl), os.ModePerm); err != nil {
 		t.Errorf(tt.desc + ": failed to write toml file: " + err.Error())
 	}
+	for name, contents := range tt.partials {
+		path := filepath.Join("./test/templates", name)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Errorf(tt.desc + ": failed to create partial directory: " + err.Error())
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), os.ModePerm); err != nil {
+			t.Errorf(tt.desc + ": failed to write partial file: " + err.Error())
+		}
+	}
 	// create tmp directory for output
 	if err := os.MkdirAll("./test/tmp", os.ModePerm); err != nil {
 		t.Errorf(tt.desc + ": failed to create tmp directory: " + err.Error())
@@ -465,13 +606,14 @@ func templateResource() (*TemplateResource, error) {
 
 	config := Config{
 		StoreClient: client, // not used but must be set
-		TemplateDir: "./test/templates",
 	}
 
-	tr, err := NewTemplateResource(tomlFilePath, config)
+	tr, err := NewTemplateResource(tomlFilePath, config, &Project{})
 	if err != nil {
 		return nil, err
 	}
+	tr.templateDir = "./test/templates"
+	tr.Src = filepath.Join(tr.templateDir, tr.Src)
 	tr.Dest = "./test/tmp/test.conf"
 	tr.FileMode = 0666
 	return tr, nil
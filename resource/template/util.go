@@ -8,7 +8,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
-	"syscall"
+	"strings"
 
 	"github.com/kelseyhightower/confd/log"
 )
@@ -19,6 +19,10 @@ type fileInfo struct {
 	Gid  uint32
 	Mode os.FileMode
 	Md5  string
+	// SELinux and ACL hold the raw security.selinux and
+	// system.posix_acl_access extended attributes.
+	SELinux string
+	ACL     string
 }
 
 func appendPrefix(prefix string, keys []string) []string {
@@ -46,9 +50,12 @@ func fileStat(name string) (fi fileInfo, err error) {
 		}
 		defer f.Close()
 		stats, _ := f.Stat()
-		fi.Uid = stats.Sys().(*syscall.Stat_t).Uid
-		fi.Gid = stats.Sys().(*syscall.Stat_t).Gid
+		fi.Uid, fi.Gid = fileOwner(stats)
 		fi.Mode = stats.Mode()
+		selinux, _ := getXattr(name, xattrSELinux)
+		fi.SELinux = string(selinux)
+		acl, _ := getXattr(name, xattrACL)
+		fi.ACL = string(acl)
 		h := md5.New()
 		io.Copy(h, f)
 		fi.Md5 = fmt.Sprintf("%x", h.Sum(nil))
@@ -86,7 +93,13 @@ func sameConfig(src, dest string) (bool, error) {
 	if d.Md5 != s.Md5 {
 		log.Info(fmt.Sprintf("%s has md5sum %s should be %s", dest, d.Md5, s.Md5))
 	}
-	if d.Uid != s.Uid || d.Gid != s.Gid || d.Mode != s.Mode || d.Md5 != s.Md5 {
+	if d.SELinux != s.SELinux {
+		log.Info(fmt.Sprintf("%s has SELinux context %q should be %q", dest, strings.TrimRight(d.SELinux, "\x00"), strings.TrimRight(s.SELinux, "\x00")))
+	}
+	if d.ACL != s.ACL {
+		log.Info(fmt.Sprintf("%s has a different ACL", dest))
+	}
+	if d.Uid != s.Uid || d.Gid != s.Gid || d.Mode != s.Mode || d.Md5 != s.Md5 || d.SELinux != s.SELinux || d.ACL != s.ACL {
 		return false, nil
 	}
 	return true, nil
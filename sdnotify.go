@@ -0,0 +1,39 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/kelseyhightower/confd/log"
+	"github.com/kelseyhightower/confd/resource/template"
+	"github.com/kelseyhightower/confd/systemd"
+)
+
+var sdReadyOnce sync.Once
+
+// sdHeartbeat is the Heartbeat of the processor: it tells systemd that confd
+// is ready once a processing pass succeeded, and then keeps its watchdog at
+// bay.
+func sdHeartbeat() {
+	if !template.LastSuccessfulRun().IsZero() {
+		sdReady()
+	}
+	sdWatchdog()
+}
+
+// sdReady tells systemd that confd is ready, the first time it is called.
+func sdReady() {
+	sdReadyOnce.Do(func() { sdNotify(systemd.Ready) })
+}
+
+// sdWatchdog tells the systemd watchdog that confd is alive, if enabled.
+func sdWatchdog() {
+	if systemd.WatchdogInterval() > 0 {
+		sdNotify(systemd.Watchdog)
+	}
+}
+
+func sdNotify(state string) {
+	if err := systemd.Notify(state); err != nil {
+		log.Debug("Cannot notify systemd of %s: %s", state, err.Error())
+	}
+}